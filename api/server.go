@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"tron"
+)
+
+type SendFunc func(chatID, message string) error
+type ExecuteFunc func(ctx context.Context, chatID, prompt string) (string, error)
+
+// StructuredFunc runs prompt and returns JSON matching schema, or any JSON
+// object when schema is nil.
+type StructuredFunc func(ctx context.Context, chatID, prompt string, schema map[string]interface{}) (string, error)
+
+type Server struct {
+	addr       string
+	token      string
+	memory     tron.MemoryStore
+	send       SendFunc
+	execute    ExecuteFunc
+	structured StructuredFunc
+	server     *http.Server
+}
+
+type Option func(*Server)
+
+// WithStructuredExecute lets /execute requests ask for a JSON response with
+// "json" or "schema".
+func WithStructuredExecute(fn StructuredFunc) Option {
+	return func(s *Server) {
+		s.structured = fn
+	}
+}
+
+type sendRequest struct {
+	ChatID  string `json:"chat_id"`
+	Message string `json:"message"`
+}
+
+type executeRequest struct {
+	ChatID string                 `json:"chat_id"`
+	Prompt string                 `json:"prompt"`
+	JSON   bool                   `json:"json"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+func NewServer(addr, token string, memory tron.MemoryStore, send SendFunc, execute ExecuteFunc, opts ...Option) *Server {
+	s := &Server{
+		addr:    addr,
+		token:   token,
+		memory:  memory,
+		send:    send,
+		execute: execute,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /memory/{chatID...}", s.handleGetMemory)
+	mux.HandleFunc("DELETE /memory/{chatID...}", s.handleDeleteMemory)
+	mux.HandleFunc("POST /send", s.handleSend)
+	mux.HandleFunc("POST /execute", s.handleExecute)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.authenticate(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("API server listening on %s", s.addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
+	chatID := r.PathValue("chatID")
+
+	history, err := s.memory.GetHistory(chatID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("get history: %v", err))
+		return
+	}
+	if history == nil {
+		history = []tron.Message{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"chat_id":  chatID,
+		"messages": history,
+	})
+}
+
+func (s *Server) handleDeleteMemory(w http.ResponseWriter, r *http.Request) {
+	chatID := r.PathValue("chatID")
+
+	if err := s.memory.ClearHistory(chatID); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("clear history: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cleared", "chat_id": chatID})
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	var req sendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.ChatID == "" || req.Message == "" {
+		writeError(w, http.StatusBadRequest, "chat_id and message are required")
+		return
+	}
+
+	if err := s.send(req.ChatID, req.Message); err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("send: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent", "chat_id": req.ChatID})
+}
+
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	var req executeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.ChatID == "" || req.Prompt == "" {
+		writeError(w, http.StatusBadRequest, "chat_id and prompt are required")
+		return
+	}
+
+	if req.JSON || req.Schema != nil {
+		s.handleExecuteStructured(w, r, req)
+		return
+	}
+
+	response, err := s.execute(r.Context(), req.ChatID, req.Prompt)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("execute: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"chat_id": req.ChatID, "response": response})
+}
+
+func (s *Server) handleExecuteStructured(w http.ResponseWriter, r *http.Request, req executeRequest) {
+	if s.structured == nil {
+		writeError(w, http.StatusNotImplemented, "structured responses are not supported")
+		return
+	}
+
+	response, err := s.structured(r.Context(), req.ChatID, req.Prompt, req.Schema)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("execute: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"chat_id": req.ChatID, "response": json.RawMessage(response)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecuteStructured(t *testing.T) {
+	execute := func(ctx context.Context, chatID, prompt string) (string, error) {
+		return "plain answer", nil
+	}
+	structured := func(ctx context.Context, chatID, prompt string, schema map[string]interface{}) (string, error) {
+		if schema != nil {
+			return `{"with_schema":true}`, nil
+		}
+		return `{"with_schema":false}`, nil
+	}
+
+	tests := []struct {
+		name       string
+		opts       []Option
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"text", []Option{WithStructuredExecute(structured)}, `{"chat_id":"dm:+1","prompt":"hi"}`,
+			http.StatusOK, `{"chat_id":"dm:+1","response":"plain answer"}`},
+		{"json", []Option{WithStructuredExecute(structured)}, `{"chat_id":"dm:+1","prompt":"hi","json":true}`,
+			http.StatusOK, `{"chat_id":"dm:+1","response":{"with_schema":false}}`},
+		{"schema", []Option{WithStructuredExecute(structured)}, `{"chat_id":"dm:+1","prompt":"hi","schema":{"type":"object"}}`,
+			http.StatusOK, `{"chat_id":"dm:+1","response":{"with_schema":true}}`},
+		{"not supported", nil, `{"chat_id":"dm:+1","prompt":"hi","json":true}`,
+			http.StatusNotImplemented, `{"error":"structured responses are not supported"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("", "secret", nil, nil, execute, tt.opts...)
+
+			req := httptest.NewRequest("POST", "/execute", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			s.server.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+}
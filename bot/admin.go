@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"tron"
+	"tron/memory"
+	"tron/plugins"
+	"tron/signal"
+)
+
+const profileHashKey = "signal_profile_hash"
+
+// Admin provides the operator commands and background jobs that need the
+// concrete Signal client, plugin manager and memory store.
+type Admin struct {
+	client  *signal.Client
+	plugins *plugins.Manager
+	store   *memory.Store
+}
+
+func NewAdmin(client *signal.Client, pm *plugins.Manager, store *memory.Store) *Admin {
+	return &Admin{client: client, plugins: pm, store: store}
+}
+
+// Commands returns the chat commands for listing groups and plugins and
+// looking up contacts.
+func (a *Admin) Commands() []tron.Command {
+	return []tron.Command{{
+		Name:        "/groups",
+		Description: "List the groups the bot is in, with their IDs",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			return a.listGroups()
+		},
+	}, {
+		Name:        "/plugins",
+		Description: "List tools and plugins with call counts and last errors",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			return a.listPlugins(), nil
+		},
+	}, {
+		Name:        "/whois",
+		Description: "Show the Signal name of a phone number or UUID",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			return a.whoIs(args)
+		},
+	}}
+}
+
+// WeeklyActivity lists the requests per day and chat over the last week.
+func (a *Admin) WeeklyActivity(ctx context.Context) (string, error) {
+	rows, err := a.store.GetUsage("", time.Now().AddDate(0, 0, -6))
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "No activity recorded.", nil
+	}
+
+	var sb strings.Builder
+	for _, r := range rows {
+		fmt.Fprintf(&sb, "%s %s: %d requests\n", r.Date, a.chatName(r.ChatID), r.Requests)
+	}
+	return sb.String(), nil
+}
+
+// SyncProfile sets the bot's Signal profile, unless it was already set to
+// the same name, about text and avatar.
+func (a *Admin) SyncProfile(name, about, avatarPath string) error {
+	if name == "" && about == "" && avatarPath == "" {
+		return nil
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", name, about, avatarPath)
+	if avatarPath != "" {
+		avatar, err := os.ReadFile(avatarPath)
+		if err != nil {
+			return fmt.Errorf("read avatar: %w", err)
+		}
+		h.Write(avatar)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	cached, err := a.store.GetState(profileHashKey)
+	if err != nil {
+		return fmt.Errorf("read cached profile hash: %w", err)
+	}
+	if cached == hash {
+		return nil
+	}
+
+	if err := a.client.UpdateProfile(name, about, avatarPath); err != nil {
+		return err
+	}
+	log.Printf("Signal profile updated")
+
+	return a.store.SetState(profileHashKey, hash)
+}
+
+// ReloadOnHangup reloads the plugins on every signal from hup until ctx is
+// done, and tells the operator what changed.
+func (a *Admin) ReloadOnHangup(ctx context.Context, hup <-chan os.Signal, router *Router) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		var msg string
+		result, err := a.plugins.Reload()
+		if err != nil {
+			log.Printf("Plugin reload failed: %v", err)
+			msg = "Plugin reload failed: " + err.Error()
+		} else {
+			msg = "Plugins reloaded: " + result.String()
+		}
+		if err := router.SendToChat("", msg); err != nil {
+			router.logSendError("Error reporting plugin reload", err)
+		}
+	}
+}
+
+func (a *Admin) listGroups() (string, error) {
+	groups, err := a.client.ListGroups()
+	if err != nil {
+		return "", fmt.Errorf("list groups: %w", err)
+	}
+	if len(groups) == 0 {
+		return "The bot is not a member of any groups.", nil
+	}
+
+	var sb strings.Builder
+	for _, g := range groups {
+		name := g.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		fmt.Fprintf(&sb, "%s (%d members)\n  id: %s\n", name, len(g.Members), g.ID)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func (a *Admin) listPlugins() string {
+	var sb strings.Builder
+	for _, p := range a.plugins.ListPluginInfo() {
+		sb.WriteString(p.Name)
+		if p.Version != "" {
+			sb.WriteString(" " + p.Version)
+		}
+		if p.Author != "" {
+			sb.WriteString(" by " + p.Author)
+		}
+		if p.Internal {
+			sb.WriteString(" (internal)")
+		}
+		if p.Disabled {
+			sb.WriteString(" (disabled)")
+		}
+		fmt.Fprintf(&sb, "\n  calls: %d", p.CallCount)
+		if p.LastCalledAt != nil {
+			fmt.Fprintf(&sb, ", last: %s", p.LastCalledAt.Format("2006-01-02 15:04"))
+		}
+		if p.LastError != "" {
+			line, _, _ := strings.Cut(p.LastError, "\n")
+			fmt.Fprintf(&sb, "\n  last error: %s", line)
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// chatName returns a readable name for chatID: the contact's or group's
+// name, falling back to the ID.
+func (a *Admin) chatName(chatID string) string {
+	if addr, ok := strings.CutPrefix(chatID, "dm:"); ok {
+		return a.client.DisplayName(addr)
+	}
+	if groupID, ok := strings.CutPrefix(chatID, "group:"); ok {
+		if name := a.client.GroupName(groupID); name != "" {
+			return name + " (group)"
+		}
+	}
+	return chatID
+}
+
+func (a *Admin) whoIs(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "Usage: /whois <phone number or UUID>", nil
+	}
+	info, err := a.client.GetProfile(address)
+	if err != nil {
+		return "", fmt.Errorf("get profile: %w", err)
+	}
+	if info.Name == "" && info.ProfileName == "" {
+		return fmt.Sprintf("No name known for %s.", address), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(info.DisplayName())
+	if info.ProfileName != "" && info.ProfileName != info.Name && info.Name != "" {
+		fmt.Fprintf(&sb, " (profile name: %s)", info.ProfileName)
+	}
+	for _, id := range []string{info.Number, info.UUID} {
+		if id != "" {
+			sb.WriteString("\n  " + id)
+		}
+	}
+	return sb.String(), nil
+}
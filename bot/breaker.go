@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	breakerFailures = 3
+	breakerWindow   = 60 * time.Second
+	breakerCooldown = 30 * time.Second
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// WithCircuitBreaker stops calling the LLM after repeated failures. Once 3
+// calls fail within a minute, messages are answered with fallback right away;
+// after 30 seconds one message is let through to probe whether the API is
+// back. Commands keep working while the circuit is open.
+func WithCircuitBreaker(fallback string) Option {
+	return func(h *Handler) {
+		h.breaker = &circuitBreaker{fallback: fallback}
+	}
+}
+
+type circuitBreaker struct {
+	fallback string
+
+	mu           sync.Mutex
+	state        breakerState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+}
+
+// allow reports whether a message may call the LLM. In the half-open state
+// only the first caller, the probe, is let through.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of an LLM call. Calls
+// cancelled by the caller say nothing about the API and are ignored.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		if b.state == breakerHalfOpen {
+			// Let the next message probe instead.
+			b.openedAt = time.Now().Add(-breakerCooldown)
+			b.setState(breakerOpen)
+		}
+		return
+	}
+
+	if err == nil {
+		b.failures = 0
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	now := time.Now()
+	if b.state == breakerHalfOpen {
+		b.openedAt = now
+		b.setState(breakerOpen)
+		return
+	}
+	if b.failures == 0 || now.Sub(b.firstFailure) > breakerWindow {
+		b.failures = 0
+		b.firstFailure = now
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= breakerFailures {
+		b.openedAt = now
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s breakerState) {
+	log.Printf("LLM circuit breaker: %s -> %s", b.state, s)
+	b.state = s
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"encoding/json"
+	"log"
+
+	"tron"
+)
+
+// messageOverheadTokens approximates the role and framing tokens each
+// message adds on top of its content.
+const messageOverheadTokens = 4
+
+// WithContextBudget trims the oldest history so that the system prompt,
+// history and tool definitions fit in about tokens tokens. 0 disables it.
+func WithContextBudget(tokens int) Option {
+	return func(h *Handler) {
+		h.contextBudget = tokens
+	}
+}
+
+// WithChatContextBudgets overrides the context budget for individual chat
+// IDs; 0 disables trimming in that chat.
+func WithChatContextBudgets(budgets map[string]int) Option {
+	return func(h *Handler) {
+		h.chatBudgets = budgets
+	}
+}
+
+func (h *Handler) budgetFor(chatID string) int {
+	if budget, ok := h.chatBudgets[chatID]; ok {
+		return budget
+	}
+	return h.contextBudget
+}
+
+// fitContextBudget drops history after the system prompt, oldest first,
+// until messages and tools fit the chat's budget. The last message, the
+// current request, and pinned messages are always kept, and tool results
+// are never left without their call.
+func (h *Handler) fitContextBudget(chatID string, messages []tron.Message, tools []tron.Tool) []tron.Message {
+	budget := h.budgetFor(chatID)
+	if budget <= 0 || len(messages) < 3 {
+		return messages
+	}
+
+	total := estimateToolTokens(tools)
+	for _, m := range messages {
+		total += estimateMessageTokens(m)
+	}
+	if total <= budget {
+		return messages
+	}
+
+	drop := 1
+	for drop < len(messages)-1 && (total > budget || messages[drop].Role == "tool" || messages[drop].Pinned) {
+		if !messages[drop].Pinned {
+			total -= estimateMessageTokens(messages[drop])
+		}
+		drop++
+	}
+
+	trimmed := make([]tron.Message, 0, len(messages)-drop+1)
+	trimmed = append(trimmed, messages[0])
+	for _, m := range messages[1:drop] {
+		if m.Pinned {
+			trimmed = append(trimmed, m)
+		}
+	}
+
+	log.Printf("Chat %s: dropped %d of %d history messages to fit the context budget of %d tokens (now about %d)",
+		chatID, drop-len(trimmed), len(messages)-2, budget, total)
+
+	return append(trimmed, messages[drop:]...)
+}
+
+// estimateTokens uses the rough rule of four bytes per token.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func estimateMessageTokens(m tron.Message) int {
+	n := messageOverheadTokens + estimateTokens(m.Content)
+	for _, p := range m.Parts {
+		n += estimateTokens(p.Text)
+	}
+	for _, tc := range m.ToolCalls {
+		n += estimateTokens(tc.Function.Name) + estimateTokens(tc.Function.Arguments)
+	}
+	return n
+}
+
+func estimateToolTokens(tools []tron.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return estimateTokens(string(data))
+}
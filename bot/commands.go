@@ -0,0 +1,237 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"tron"
+)
+
+type CommandFunc = tron.CommandFunc
+
+// RegisterCommand adds a command that is answered without calling the LLM.
+// Names are matched case-insensitively against the start of the message.
+// "/name" and "!name" are the same command and match either prefix.
+func (h *Handler) RegisterCommand(name string, fn CommandFunc) {
+	h.RegisterCommands(tron.Command{Name: name, Run: fn})
+}
+
+func (h *Handler) RegisterCommands(cmds ...tron.Command) {
+	for _, cmd := range cmds {
+		cmd.Name = normalizeCommand(strings.ToLower(cmd.Name))
+		h.commands[cmd.Name] = cmd
+	}
+}
+
+// normalizeCommand turns the "!" prefix into "/".
+func normalizeCommand(s string) string {
+	if strings.HasPrefix(s, "!") {
+		return "/" + s[1:]
+	}
+	return s
+}
+
+// publicCommands can be used by every role; the others are for the operator.
+var publicCommands = map[string]bool{"/help": true, "/clear": true, "/status": true, "/pin": true, "/unpin": true, "/forget": true}
+
+func (h *Handler) commandAllowed(ctx context.Context, cmd tron.Command) bool {
+	role := tron.RoleFromContext(ctx)
+	return role == "" || role == tron.RoleOperator || publicCommands[cmd.Name]
+}
+
+func (h *Handler) registerBuiltinCommands() {
+	h.RegisterCommands(
+		tron.Command{Name: "/help", Description: "List commands and tools", Run: h.help},
+		tron.Command{Name: "/clear", Description: "Forget the conversation in this chat", Run: h.clear},
+		tron.Command{Name: "/status", Description: "Show uptime and model", Run: h.status},
+		tron.Command{Name: "/pin", Description: "Keep the last message in the conversation for good", Run: h.pin},
+		tron.Command{Name: "/unpin", Description: "Let pinned messages age out again", Run: h.unpin},
+		tron.Command{Name: "/forget", Description: "Delete your last message, or every message containing the given text", Run: h.forget},
+		tron.Command{Name: "/models", Description: "List the models offered by the LLM API", Run: h.listModels},
+		tron.Command{Name: "/setprompt", Description: "Set this chat's system prompt (empty to use the default again)", Run: h.setPrompt},
+	)
+}
+
+func (h *Handler) help(ctx context.Context, chatID, args string) (string, error) {
+	names := make([]string, 0, len(h.commands))
+	for name := range h.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Commands (/ and ! both work):\n")
+	for _, name := range names {
+		if !h.commandAllowed(ctx, h.commands[name]) {
+			continue
+		}
+		if desc := h.commands[name].Description; desc != "" {
+			fmt.Fprintf(&sb, "%s - %s\n", name, desc)
+		} else {
+			sb.WriteString(name + "\n")
+		}
+	}
+
+	if tools := h.tools(ctx, chatID); len(tools) > 0 {
+		sb.WriteString("\nTools:\n")
+		for _, t := range tools {
+			fmt.Fprintf(&sb, "%s - %s\n", t.Function.Name, firstSentence(t.Function.Description))
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+func (h *Handler) clear(ctx context.Context, chatID, args string) (string, error) {
+	if err := h.memory.ClearHistory(chatID); err != nil {
+		return "", fmt.Errorf("clear history: %w", err)
+	}
+	return "Conversation cleared.", nil
+}
+
+func (h *Handler) pin(ctx context.Context, chatID, args string) (string, error) {
+	store, ok := h.memory.(tron.PinStore)
+	if !ok {
+		return "Pinning is not supported by this memory store.", nil
+	}
+	m, found, err := store.PinLastMessage(chatID)
+	if err != nil {
+		return "", fmt.Errorf("pin message: %w", err)
+	}
+	if !found {
+		return "There is no message to pin.", nil
+	}
+	return fmt.Sprintf("Pinned: %s", truncateRunes(m.Content, 200)), nil
+}
+
+func (h *Handler) unpin(ctx context.Context, chatID, args string) (string, error) {
+	store, ok := h.memory.(tron.PinStore)
+	if !ok {
+		return "Pinning is not supported by this memory store.", nil
+	}
+	n, err := store.UnpinMessages(chatID)
+	if err != nil {
+		return "", fmt.Errorf("unpin messages: %w", err)
+	}
+	return fmt.Sprintf("Unpinned %d messages.", n), nil
+}
+
+func (h *Handler) forget(ctx context.Context, chatID, args string) (string, error) {
+	store, ok := h.memory.(tron.RedactStore)
+	if !ok {
+		return "Deleting messages is not supported by this memory store.", nil
+	}
+	if text := strings.TrimSpace(args); text != "" {
+		n, err := store.DeleteByContentMatch(chatID, text)
+		if err != nil {
+			return "", fmt.Errorf("delete messages: %w", err)
+		}
+		return fmt.Sprintf("Deleted %d messages.", n), nil
+	}
+	_, found, err := store.DeleteLastUserMessage(chatID)
+	if err != nil {
+		return "", fmt.Errorf("delete message: %w", err)
+	}
+	if !found {
+		return "There is no message to delete.", nil
+	}
+	return "Deleted your last message.", nil
+}
+
+func (h *Handler) setPrompt(ctx context.Context, chatID, args string) (string, error) {
+	prompt := strings.TrimSpace(args)
+	if prompt == "" {
+		if err := h.ClearSystemPrompt(chatID); err != nil {
+			return "", fmt.Errorf("clear system prompt: %w", err)
+		}
+		return "This chat uses the default system prompt again.", nil
+	}
+	if err := h.SetSystemPrompt(chatID, prompt); err != nil {
+		return "", fmt.Errorf("set system prompt: %w", err)
+	}
+	return "System prompt for this chat updated.", nil
+}
+
+func (h *Handler) status(ctx context.Context, chatID, args string) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Uptime: %s\n", time.Since(h.started).Round(time.Second))
+	if h.model != "" {
+		fmt.Fprintf(&sb, "Model: %s\n", h.model)
+	}
+	fmt.Fprintf(&sb, "Plugins: %d\n", h.plugins.PluginCount())
+	fmt.Fprintf(&sb, "Tools: %d", len(h.tools(ctx, chatID)))
+	if history, err := h.memory.GetHistory(chatID); err == nil {
+		fmt.Fprintf(&sb, "\nMessages in memory: %d", len(history))
+	}
+	if h.dispatcher != nil {
+		queued, running := h.dispatcher.Pending()
+		fmt.Fprintf(&sb, "\nMessages in progress: %d, queued: %d", running, queued)
+	}
+	if h.statusInfo != nil {
+		if info, err := h.statusInfo(chatID); err == nil {
+			sb.WriteString("\n" + info)
+		} else {
+			log.Printf("Chat %s: status info: %v", chatID, err)
+		}
+	}
+	return sb.String(), nil
+}
+
+func (h *Handler) listModels(ctx context.Context, chatID, args string) (string, error) {
+	models, err := h.llm.Models(ctx)
+	if err != nil {
+		return "", fmt.Errorf("list models: %w", err)
+	}
+	if len(models) == 0 {
+		return "The API did not report any models.", nil
+	}
+
+	filter := strings.ToLower(args)
+	var sb strings.Builder
+	count := 0
+	for _, m := range models {
+		if filter != "" && !strings.Contains(strings.ToLower(m), filter) {
+			continue
+		}
+		sb.WriteString(m + "\n")
+		count++
+	}
+	if count == 0 {
+		return fmt.Sprintf("No models match %q.", args), nil
+	}
+
+	return fmt.Sprintf("%d models:\n%s", count, sb.String()), nil
+}
+
+// matchCommand finds the longest registered command that prefixes message.
+// Unknown commands, including unknown slash commands, go to the LLM.
+func (h *Handler) matchCommand(message string) (tron.Command, string, bool) {
+	message = strings.TrimSpace(message)
+	lower := normalizeCommand(strings.ToLower(message))
+
+	var best tron.Command
+	for name, cmd := range h.commands {
+		if lower != name && !strings.HasPrefix(lower, name+" ") {
+			continue
+		}
+		if len(name) > len(best.Name) {
+			best = cmd
+		}
+	}
+	if best.Run == nil {
+		return tron.Command{}, "", false
+	}
+
+	return best, strings.TrimSpace(message[len(best.Name):]), true
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
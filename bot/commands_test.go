@@ -0,0 +1,39 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"tron/llm/llmtest"
+)
+
+func TestMatchCommand(t *testing.T) {
+	h := NewHandler(llmtest.NewScriptedClient(), newFakePlugins(nil), newFakeMemory(), "", false)
+	noop := func(ctx context.Context, chatID, args string) (string, error) { return "", nil }
+	h.RegisterCommand("/plugin", noop)
+	h.RegisterCommand("/plugins", noop)
+
+	tests := []struct {
+		message  string
+		wantName string
+		wantArgs string
+		wantOK   bool
+	}{
+		{"/models", "/models", "", true},
+		{"!models gpt", "/models", "gpt", true},
+		{"/MODELS", "/models", "", true},
+		{"/plugins", "/plugins", "", true},
+		{"/plugin reload", "/plugin", "reload", true},
+		{"/pluginz", "", "", false},
+		{"list models", "", "", false},
+		{"/unknown", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			cmd, args, ok := h.matchCommand(tt.message)
+			if ok != tt.wantOK || cmd.Name != tt.wantName || args != tt.wantArgs {
+				t.Errorf("matchCommand(%q) = %q, %q, %v, want %q, %q, %v", tt.message, cmd.Name, args, ok, tt.wantName, tt.wantArgs, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const dispatcherIdleTimeout = 5 * time.Minute
+
+// Dispatcher runs jobs in order within a chat and concurrently across chats.
+// Each chat gets a worker goroutine with a bounded queue; idle workers exit.
+type Dispatcher struct {
+	mu           sync.Mutex
+	queues       map[string]chan dispatchJob
+	queueSize    int
+	slots        chan struct{}
+	queueTimeout time.Duration
+	pending      atomic.Int64
+	running      atomic.Int64
+	stop         chan struct{}
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+type dispatchJob struct {
+	run      func()
+	reject   func()
+	queuedAt time.Time
+}
+
+type DispatcherOption func(*Dispatcher)
+
+// WithMaxConcurrent limits how many jobs run at once across all chats.
+// 0 means no limit.
+func WithMaxConcurrent(n int) DispatcherOption {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithQueueTimeout rejects jobs that have waited longer than timeout to
+// start. 0 lets jobs wait as long as it takes.
+func WithQueueTimeout(timeout time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.queueTimeout = timeout
+	}
+}
+
+func NewDispatcher(queueSize int, opts ...DispatcherOption) *Dispatcher {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	d := &Dispatcher{
+		queues:    make(map[string]chan dispatchJob),
+		queueSize: queueSize,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Submit queues job for chatID. It returns false without queueing when the
+// chat already has queueSize jobs waiting behind the one in progress. If the
+// job then waits longer than the queue timeout, it is dropped and reject,
+// if set, is called instead.
+func (d *Dispatcher) Submit(ctx context.Context, chatID string, job func(ctx context.Context), reject func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+
+	q, ok := d.queues[chatID]
+	if !ok {
+		q = make(chan dispatchJob, d.queueSize)
+		d.queues[chatID] = q
+		d.wg.Add(1)
+		go d.work(chatID, q)
+	}
+
+	select {
+	case q <- dispatchJob{run: func() { job(ctx) }, reject: reject, queuedAt: time.Now()}:
+		d.pending.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+// Pending returns the number of jobs waiting to start and the number
+// running.
+func (d *Dispatcher) Pending() (queued, running int) {
+	return int(d.pending.Load()), int(d.running.Load())
+}
+
+// Close stops every worker after its current job, drops queued jobs and
+// waits for the workers to exit.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.stop)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) work(chatID string, q chan dispatchJob) {
+	defer d.wg.Done()
+
+	idle := time.NewTimer(dispatcherIdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case job := <-q:
+			start, stopped := d.acquire(job)
+			d.pending.Add(-1)
+			if stopped {
+				return
+			}
+			if start {
+				d.running.Add(1)
+				job.run()
+				d.running.Add(-1)
+				d.release()
+			} else if job.reject != nil {
+				job.reject()
+			}
+			idle.Reset(dispatcherIdleTimeout)
+		case <-idle.C:
+			d.mu.Lock()
+			if len(q) > 0 {
+				d.mu.Unlock()
+				idle.Reset(dispatcherIdleTimeout)
+				continue
+			}
+			delete(d.queues, chatID)
+			d.mu.Unlock()
+			return
+		}
+	}
+}
+
+// acquire waits for a free slot for job. start is false when the job waited
+// past the queue timeout; stopped is true when the dispatcher was closed.
+func (d *Dispatcher) acquire(job dispatchJob) (start, stopped bool) {
+	select {
+	case <-d.stop:
+		return false, true
+	default:
+	}
+
+	var expired <-chan time.Time
+	if d.queueTimeout > 0 {
+		wait := d.queueTimeout - time.Since(job.queuedAt)
+		if wait <= 0 {
+			return false, false
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	if d.slots == nil {
+		return true, false
+	}
+
+	select {
+	case <-d.stop:
+		return false, true
+	case d.slots <- struct{}{}:
+		return true, false
+	case <-expired:
+		return false, false
+	}
+}
+
+func (d *Dispatcher) release() {
+	if d.slots != nil {
+		<-d.slots
+	}
+}
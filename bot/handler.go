@@ -1,29 +1,238 @@
 package bot
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"tron"
 )
 
 type Handler struct {
-	llm          tron.LLMClient
-	plugins      tron.PluginManager
-	memory       tron.MemoryStore
-	systemPrompt string
-	debug        bool
+	llm                  tron.LLMClient
+	plugins              tron.PluginManager
+	memory               tron.MemoryStore
+	promptMu             sync.RWMutex
+	systemPrompt         string
+	chatPrompts          sync.Map
+	debug                bool
+	stream               bool
+	onDelta              func(chatID, delta string)
+	timeout              time.Duration
+	commands             map[string]tron.Command
+	model                string
+	started              time.Time
+	usage                tron.UsageRecorder
+	fetchImage           func(id string) ([]byte, error)
+	middleware           []Middleware
+	maxParallelTools     int
+	maxIterations        int
+	onInterim            func(ctx context.Context, chatID, text string)
+	historyToolResultMax int
+	liveToolResultMax    int
+	summarizeToolResults bool
+	keepToolOutput       func(chatID, tool, output string)
+	contextBudget        int
+	rollingSummary       bool
+	embedder             tron.Embedder
+	recallK              int
+	recallMinScore       float32
+	recallMaxTokens      int
+	chatBudgets          map[string]int
+	breaker              *circuitBreaker
+	dispatcher           *Dispatcher
+	statusInfo           func(chatID string) (string, error)
+	sources              []summarySource
+	promptSources        []promptSource
 }
 
-func NewHandler(llm tron.LLMClient, plugins tron.PluginManager, memory tron.MemoryStore, systemPrompt string, debug bool) *Handler {
-	return &Handler{
-		llm:          llm,
-		plugins:      plugins,
-		memory:       memory,
-		systemPrompt: systemPrompt,
-		debug:        debug,
+type summarySource struct {
+	name  string
+	fetch func(ctx context.Context) (string, error)
+}
+
+type promptSource struct {
+	name  string
+	fetch func(ctx context.Context, chatID string) (string, error)
+}
+
+type Option func(*Handler)
+
+const (
+	maxVisionImageBytes      = 5 * 1024 * 1024
+	defaultMaxToolIterations = 8
+	maxSummaryHistory        = 200
+	finalAnswerNudge         = "Tool call limit reached. Answer the user now using only the information you already have."
+	groupChatNote            = "This is a group chat. Each user message starts with the sender's name, e.g. \"Alice: ...\"."
+)
+
+func WithStreaming(onDelta func(chatID, delta string)) Option {
+	return func(h *Handler) {
+		h.stream = true
+		h.onDelta = onDelta
+	}
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(h *Handler) {
+		h.timeout = d
+	}
+}
+
+func WithMaxParallelTools(n int) Option {
+	return func(h *Handler) {
+		h.maxParallelTools = n
+	}
+}
+
+// WithMaxToolIterations caps the LLM calls per message. The last one is made
+// without tools so the user still gets an answer.
+func WithMaxToolIterations(n int) Option {
+	return func(h *Handler) {
+		h.maxIterations = n
+	}
+}
+
+// WithInterimMessages passes text the model writes alongside tool calls
+// ("Let me check your tasks") to send while the tools run.
+func WithInterimMessages(send func(ctx context.Context, chatID, text string)) Option {
+	return func(h *Handler) {
+		h.onInterim = send
+	}
+}
+
+// WithToolHistory keeps tool calls and their results, cut to maxResultChars,
+// in memory so later messages can refer to them. The memory store must
+// implement tron.ToolHistoryStore.
+func WithToolHistory(maxResultChars int) Option {
+	return func(h *Handler) {
+		h.historyToolResultMax = maxResultChars
+	}
+}
+
+// WithDispatcher reports the message queue of d in /status.
+func WithDispatcher(d *Dispatcher) Option {
+	return func(h *Handler) {
+		h.dispatcher = d
+	}
+}
+
+// WithStatusInfo adds the text returned by fn to /status.
+func WithStatusInfo(fn func(chatID string) (string, error)) Option {
+	return func(h *Handler) {
+		h.statusInfo = fn
+	}
+}
+
+// WithModelName sets the model name reported by /status.
+func WithModelName(model string) Option {
+	return func(h *Handler) {
+		h.model = model
+	}
+}
+
+func WithUsageRecorder(r tron.UsageRecorder) Option {
+	return func(h *Handler) {
+		h.usage = r
+	}
+}
+
+func WithVision(fetch func(id string) ([]byte, error)) Option {
+	return func(h *Handler) {
+		h.fetchImage = fetch
+	}
+}
+
+// WithSummarySource adds a named section of data to the weekly summary.
+func WithSummarySource(name string, fetch func(ctx context.Context) (string, error)) Option {
+	return func(h *Handler) {
+		h.sources = append(h.sources, summarySource{name: name, fetch: fetch})
+	}
+}
+
+// WithPromptSource adds a named section to the system prompt of every request.
+// Empty sections are left out; errors are logged and the section skipped.
+func WithPromptSource(name string, fetch func(ctx context.Context, chatID string) (string, error)) Option {
+	return func(h *Handler) {
+		h.promptSources = append(h.promptSources, promptSource{name: name, fetch: fetch})
+	}
+}
+
+func NewHandler(llm tron.LLMClient, plugins tron.PluginManager, memory tron.MemoryStore, systemPrompt string, debug bool, opts ...Option) *Handler {
+	h := &Handler{
+		llm:           llm,
+		plugins:       plugins,
+		memory:        memory,
+		systemPrompt:  systemPrompt,
+		debug:         debug,
+		commands:      make(map[string]tron.Command),
+		started:       time.Now(),
+		maxIterations: defaultMaxToolIterations,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.registerBuiltinCommands()
+	h.loadChatPrompts()
+	return h
+}
+
+func (h *Handler) UpdateSystemPrompt(prompt string) {
+	h.promptMu.Lock()
+	defer h.promptMu.Unlock()
+	h.systemPrompt = prompt
+}
+
+// SetSystemPrompt gives chatID its own system prompt instead of the global
+// one. It is saved if the memory store implements tron.ChatConfigStore.
+func (h *Handler) SetSystemPrompt(chatID, prompt string) error {
+	if store, ok := h.memory.(tron.ChatConfigStore); ok {
+		if err := store.SetChatSystemPrompt(chatID, prompt); err != nil {
+			return err
+		}
 	}
+	h.chatPrompts.Store(chatID, prompt)
+	return nil
+}
+
+// ClearSystemPrompt makes chatID use the global system prompt again.
+func (h *Handler) ClearSystemPrompt(chatID string) error {
+	if store, ok := h.memory.(tron.ChatConfigStore); ok {
+		if err := store.SetChatSystemPrompt(chatID, ""); err != nil {
+			return err
+		}
+	}
+	h.chatPrompts.Delete(chatID)
+	return nil
+}
+
+func (h *Handler) loadChatPrompts() {
+	store, ok := h.memory.(tron.ChatConfigStore)
+	if !ok {
+		return
+	}
+	prompts, err := store.ChatSystemPrompts()
+	if err != nil {
+		log.Printf("Failed to load chat system prompts: %v", err)
+		return
+	}
+	for chatID, prompt := range prompts {
+		h.chatPrompts.Store(chatID, prompt)
+	}
+}
+
+// baseSystemPrompt returns the system prompt of chatID, or the global one.
+func (h *Handler) baseSystemPrompt(chatID string) string {
+	if prompt, ok := h.chatPrompts.Load(chatID); ok {
+		return prompt.(string)
+	}
+	h.promptMu.RLock()
+	defer h.promptMu.RUnlock()
+	return h.systemPrompt
 }
 
 func (h *Handler) debugLog(format string, v ...interface{}) {
@@ -32,8 +241,36 @@ func (h *Handler) debugLog(format string, v ...interface{}) {
 	}
 }
 
-func (h *Handler) HandleMessage(chatID, userMessage string, expiresInSeconds int) (string, error) {
-	if err := h.memory.AddMessage(chatID, "user", userMessage, expiresInSeconds); err != nil {
+func (h *Handler) HandleMessage(ctx context.Context, chatID, userMessage string, expiresInSeconds int, attachments []tron.AttachmentInfo) (string, error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	ctx = tron.WithChatID(ctx, chatID)
+
+	next := func(ctx context.Context, chatID, message string) (string, error) {
+		return h.handleMessage(ctx, chatID, message, expiresInSeconds, attachments)
+	}
+	for i := len(h.middleware) - 1; i >= 0; i-- {
+		m, inner := h.middleware[i], next
+		next = func(ctx context.Context, chatID, message string) (string, error) {
+			return m(ctx, chatID, message, inner)
+		}
+	}
+	return next(ctx, chatID, userMessage)
+}
+
+func (h *Handler) handleMessage(ctx context.Context, chatID, userMessage string, expiresInSeconds int, attachments []tron.AttachmentInfo) (string, error) {
+	if cmd, args, ok := h.matchCommand(userMessage); ok && len(attachments) == 0 && h.commandAllowed(ctx, cmd) {
+		h.debugLog("Command: %s (args: %q)", userMessage, args)
+		return cmd.Run(ctx, chatID, args)
+	}
+
+	userMessage = withAttachmentNotes(userMessage, attachments)
+
+	if err := h.saveUserMessage(ctx, chatID, userMessage, expiresInSeconds); err != nil {
 		h.debugLog("Failed to save user message: %v", err)
 	}
 
@@ -42,56 +279,280 @@ func (h *Handler) HandleMessage(chatID, userMessage string, expiresInSeconds int
 		h.debugLog("Failed to get history: %v", err)
 	}
 
+	return h.respond(ctx, chatID, userMessage, history, expiresInSeconds, attachments)
+}
+
+// respond answers userMessage, which is already saved, given history, and
+// saves the answer.
+func (h *Handler) respond(ctx context.Context, chatID, userMessage string, history []tron.Message, expiresInSeconds int, attachments []tron.AttachmentInfo) (string, error) {
+	if !h.breaker.allow() {
+		h.debugLog("Circuit breaker open, sending fallback response to chat %s", chatID)
+		return h.breaker.fallback, nil
+	}
+
 	now := time.Now()
-	dynamicPrompt := fmt.Sprintf("%s\n\nCurrent time: %s", h.systemPrompt, now.Format("2006-01-02 15:04:05 MST (Monday)"))
+	dynamicPrompt := fmt.Sprintf("%s\n\nCurrent time: %s", h.baseSystemPrompt(chatID), now.Format("2006-01-02 15:04:05 MST (Monday)"))
+	if strings.HasPrefix(chatID, "group:") {
+		dynamicPrompt += "\n\n" + groupChatNote
+	}
+	for _, src := range h.promptSources {
+		text, err := src.fetch(ctx, chatID)
+		if err != nil {
+			log.Printf("Failed to get %s for chat %s: %v", src.name, chatID, err)
+			continue
+		}
+		if text != "" {
+			dynamicPrompt += fmt.Sprintf("\n\n## %s\n%s", src.name, text)
+		}
+	}
+	recalled, userVec := h.recall(ctx, chatID, userMessage, history)
+	if recalled != "" {
+		dynamicPrompt += "\n\n## Relevant past context\n" + recalled
+	}
 
 	messages := []tron.Message{
 		{Role: "system", Content: dynamicPrompt},
 	}
-	messages = append(messages, history...)
+	messages = append(messages, h.compactHistory(ctx, chatID, dynamicPrompt, history)...)
+
+	if visionMsg, ok := h.visionMessage(userMessage, attachments); ok {
+		if n := len(messages); n > 1 && messages[n-1].Role == "user" && messages[n-1].Content == userMessage {
+			if sender := messages[n-1].Sender; sender != "" {
+				visionMsg.Parts[0].Text = sender + ": " + visionMsg.Parts[0].Text
+			}
+			messages[n-1] = visionMsg
+		} else {
+			messages = append(messages, visionMsg)
+		}
+	}
+	for i := range messages {
+		messages[i].Content = attributed(messages[i])
+		messages[i].Sender = ""
+	}
 
-	tools := h.plugins.GetTools()
 	h.debugLog("User message: %s", userMessage)
 	h.debugLog("History messages: %d", len(history))
+
+	response, exchange, err := h.runToolLoop(ctx, chatID, messages)
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.saveResponse(chatID, response, exchange, expiresInSeconds); err != nil {
+		h.debugLog("Failed to save assistant message: %v", err)
+	}
+	// Disappearing messages are not archived so they do not outlive their timer.
+	if h.embedder != nil && expiresInSeconds == 0 {
+		go h.archive(chatID, userMessage, response, userVec)
+	}
+
+	return response, nil
+}
+
+// saveUserMessage stores userMessage with the sender set by tron.WithSender
+// and the time set by tron.WithSentAt, if any and the memory store can keep
+// them.
+func (h *Handler) saveUserMessage(ctx context.Context, chatID, userMessage string, expiresInSeconds int) error {
+	sender := tron.SenderFromContext(ctx)
+	sentAt := tron.SentAtFromContext(ctx)
+	store, ok := h.memory.(tron.ToolHistoryStore)
+	if (sender == "" && sentAt.IsZero()) || !ok {
+		return h.memory.AddMessage(chatID, "user", userMessage, expiresInSeconds)
+	}
+	return store.AddMessages(chatID, []tron.Message{{Role: "user", Content: userMessage, Sender: sender, Timestamp: sentAt}}, expiresInSeconds)
+}
+
+// attributed returns the content of m prefixed with its sender's name.
+func attributed(m tron.Message) string {
+	if m.Sender == "" || m.Content == "" {
+		return m.Content
+	}
+	return m.Sender + ": " + m.Content
+}
+
+// saveResponse stores the answer and, with WithToolHistory, the tool calls
+// and results that led to it.
+func (h *Handler) saveResponse(chatID, response string, exchange []tron.Message, expiresInSeconds int) error {
+	store, ok := h.memory.(tron.ToolHistoryStore)
+	if h.historyToolResultMax <= 0 || !ok || len(exchange) == 0 {
+		return h.memory.AddMessage(chatID, "assistant", response, expiresInSeconds)
+	}
+
+	messages := make([]tron.Message, 0, len(exchange)+1)
+	for _, m := range exchange {
+		if m.Role == "tool" {
+			m.Content = truncateRunes(m.Content, h.historyToolResultMax)
+		}
+		messages = append(messages, m)
+	}
+	messages = append(messages, tron.Message{Role: "assistant", Content: response})
+	return store.AddMessages(chatID, messages, expiresInSeconds)
+}
+
+// tools returns the tools of chatID that the sender's role may use.
+func (h *Handler) tools(ctx context.Context, chatID string) []tron.Tool {
+	role := tron.RoleFromContext(ctx)
+	if pm, ok := h.plugins.(tron.RolePluginManager); ok && role != "" {
+		return pm.GetToolsForRole(chatID, role)
+	}
+	return h.plugins.GetToolsForChat(chatID)
+}
+
+// runToolLoop returns the final answer and the tool calls and results
+// exchanged on the way.
+func (h *Handler) runToolLoop(ctx context.Context, chatID string, messages []tron.Message) (string, []tron.Message, error) {
+	tools := h.tools(ctx, chatID)
 	h.debugLog("Available tools: %d", len(tools))
+	messages = h.fitContextBudget(chatID, messages, tools)
 
+	var exchange []tron.Message
 	iteration := 0
 	for {
 		iteration++
 		h.debugLog("Iteration %d - sending %d messages to LLM", iteration, len(messages))
 
-		resp, err := h.llm.Chat(messages, tools)
+		var opts tron.ChatOptions
+		if iteration >= h.maxIterations && len(tools) > 0 {
+			log.Printf("WARNING: chat %s reached %d tool iterations, asking for a final answer without tools", chatID, iteration)
+			opts.ToolChoice = tron.ToolChoiceNone
+			messages = append(messages, tron.Message{Role: "system", Content: finalAnswerNudge})
+		}
+
+		resp, err := h.chat(ctx, chatID, messages, tools, opts)
 		if err != nil {
-			return "", fmt.Errorf("llm chat: %w", err)
+			return "", nil, fmt.Errorf("llm chat: %w", err)
+		}
+		h.recordUsage(chatID, resp)
+		if resp.Reasoning != "" {
+			h.debugLog("Reasoning: %s", truncate(resp.Reasoning, 500))
 		}
 
 		if len(resp.ToolCalls) == 0 {
 			h.debugLog("Final response: %s", resp.Content)
-
-			if err := h.memory.AddMessage(chatID, "assistant", resp.Content, expiresInSeconds); err != nil {
-				h.debugLog("Failed to save assistant message: %v", err)
-			}
-
-			return resp.Content, nil
+			return resp.Content, exchange, nil
 		}
 
 		h.debugLog("Got %d tool calls", len(resp.ToolCalls))
+		if iteration >= h.maxIterations {
+			return "", nil, fmt.Errorf("model kept calling tools after %d iterations", iteration)
+		}
 
-		messages = append(messages, tron.Message{
+		call := tron.Message{
 			Role:      "assistant",
+			Content:   resp.Content,
 			ToolCalls: resp.ToolCalls,
+		}
+		messages = append(messages, call)
+		exchange = append(exchange, call)
+		if h.onInterim != nil && strings.TrimSpace(resp.Content) != "" {
+			h.onInterim(ctx, chatID, resp.Content)
+		}
+
+		results := h.executeToolCalls(ctx, chatID, resp.ToolCalls)
+		for i, tc := range resp.ToolCalls {
+			result := tron.Message{
+				Role:        "tool",
+				Content:     results[i],
+				ToolCallID:  tc.ID,
+				ContentType: contentType(results[i]),
+			}
+			messages = append(messages, result)
+			exchange = append(exchange, result)
+		}
+	}
+}
+
+func (h *Handler) visionMessage(text string, attachments []tron.AttachmentInfo) (tron.Message, bool) {
+	if h.fetchImage == nil {
+		return tron.Message{}, false
+	}
+
+	parts := []tron.ContentPart{{Type: "text", Text: text}}
+	for _, a := range attachments {
+		if !strings.HasPrefix(a.ContentType, "image/") || a.Size > maxVisionImageBytes {
+			continue
+		}
+		data, err := h.fetchImage(a.ID)
+		if err != nil {
+			h.debugLog("Failed to fetch image %s: %v", a.ID, err)
+			continue
+		}
+		parts = append(parts, tron.ContentPart{
+			Type: "image_url",
+			ImageURL: &tron.ImageURL{
+				URL: "data:" + a.ContentType + ";base64," + base64.StdEncoding.EncodeToString(data),
+			},
 		})
+	}
+	if len(parts) == 1 {
+		return tron.Message{}, false
+	}
+
+	return tron.Message{Role: "user", Content: text, Parts: parts}, true
+}
+
+func withAttachmentNotes(message string, attachments []tron.AttachmentInfo) string {
+	if len(attachments) == 0 {
+		return message
+	}
+
+	var notes []string
+	for _, a := range attachments {
+		notes = append(notes, fmt.Sprintf("[user sent attachment: %s, %s]", a.ContentType, formatSize(a.Size)))
+	}
+
+	note := strings.Join(notes, "\n")
+	if message == "" {
+		return note
+	}
+	return message + "\n" + note
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%dKB", size/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+func (h *Handler) chat(ctx context.Context, chatID string, messages []tron.Message, tools []tron.Tool, opts tron.ChatOptions) (resp *tron.LLMResponse, err error) {
+	defer func() { h.breaker.record(err) }()
+
+	if !opts.IsZero() {
+		if client, ok := h.llm.(tron.OptionsLLMClient); ok {
+			return client.ChatWithOptions(ctx, messages, tools, opts)
+		}
+		if opts.ToolChoice == tron.ToolChoiceNone {
+			tools = nil
+		}
+	}
+
+	streamer, ok := h.llm.(tron.StreamingLLMClient)
+	if !h.stream || !ok {
+		return h.llm.Chat(ctx, messages, tools)
+	}
 
-		for _, tc := range resp.ToolCalls {
-			h.debugLog("Tool call: %s(%s)", tc.Function.Name, tc.Function.Arguments)
-			result := h.executeToolWithContext(tc.Function.Name, tc.Function.Arguments, chatID)
-			h.debugLog("Tool result: %s", truncate(result, 200))
-			messages = append(messages, tron.Message{
-				Role:       "tool",
-				Content:    result,
-				ToolCallID: tc.ID,
-			})
+	chunks := 0
+	defer func() { h.debugLog("Streamed %d chunks", chunks) }()
+
+	return streamer.ChatStream(ctx, messages, tools, func(delta string) {
+		chunks++
+		if h.onDelta != nil {
+			h.onDelta(chatID, delta)
 		}
+	})
+}
+
+func (h *Handler) recordUsage(chatID string, resp *tron.LLMResponse) {
+	if h.usage == nil || resp.Usage == nil {
+		return
+	}
+	if err := h.usage.RecordUsage(chatID, *resp.Usage); err != nil {
+		h.debugLog("Failed to record usage: %v", err)
 	}
 }
 
@@ -102,10 +563,44 @@ func truncate(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-func (h *Handler) executeTool(name, argsJSON string) string {
+// executeToolCalls runs the calls of one iteration with at most
+// h.maxParallelTools in flight and returns results in call order.
+func (h *Handler) executeToolCalls(ctx context.Context, chatID string, calls []tron.ToolCall) []string {
+	results := make([]string, len(calls))
+	run := func(i int) {
+		tc := calls[i]
+		h.debugLog("Tool call: %s(%s)", tc.Function.Name, tc.Function.Arguments)
+		results[i] = h.executeToolWithContext(ctx, tc.Function.Name, tc.Function.Arguments, chatID)
+		h.debugLog("Tool result: %s", truncate(results[i], 200))
+	}
+
+	if h.maxParallelTools <= 1 || len(calls) == 1 {
+		for i := range calls {
+			run(i)
+		}
+		return results
+	}
+
+	sem := make(chan struct{}, h.maxParallelTools)
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (h *Handler) executeTool(ctx context.Context, name, argsJSON string) string {
 	h.debugLog("Executing tool: %s with args: %s", name, argsJSON)
 
-	result, err := h.plugins.Execute(name, argsJSON)
+	result, err := h.plugins.Execute(ctx, name, argsJSON)
 	if err != nil {
 		return fmt.Sprintf("Error: %s", err)
 	}
@@ -113,26 +608,124 @@ func (h *Handler) executeTool(name, argsJSON string) string {
 	return result
 }
 
-func (h *Handler) executeToolWithContext(name, argsJSON, chatID string) string {
+func (h *Handler) executeToolWithContext(ctx context.Context, name, argsJSON, chatID string) string {
 	h.debugLog("Executing tool: %s with args: %s (chatID: %s)", name, argsJSON, chatID)
 
-	result, err := h.plugins.ExecuteWithContext(name, argsJSON, chatID)
+	result, err := h.plugins.ExecuteWithContext(ctx, name, argsJSON, chatID)
 	if err != nil {
 		return fmt.Sprintf("Error: %s", err)
 	}
 
-	return result
+	return h.limitToolResult(ctx, chatID, name, argsJSON, result)
+}
+
+// GenerateWeeklySummary asks the model for a review of the past week in
+// chatID, built from its tasks, its conversation and the summary sources.
+func (h *Handler) GenerateWeeklySummary(ctx context.Context, chatID string) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Write my weekly review for the past 7 days. Summarize what got done, what is still open or overdue, " +
+		"and suggest priorities for next week. Be concise. Use this data:\n")
+
+	if h.plugins.HasPlugin("task") {
+		tasks, err := h.plugins.Execute(ctx, "task", `{"action": "list"}`)
+		if err != nil {
+			tasks = fmt.Sprintf("Error getting tasks: %s", err)
+		}
+		fmt.Fprintf(&sb, "\n## Tasks\n%s\n", tasks)
+	}
+
+	conversation, err := h.conversationDigest(chatID, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		conversation = fmt.Sprintf("Error getting conversation: %s", err)
+	}
+	fmt.Fprintf(&sb, "\n## Conversation\n%s\n", conversation)
+
+	for _, src := range h.sources {
+		data, err := src.fetch(ctx)
+		if err != nil {
+			data = fmt.Sprintf("Error: %s", err)
+		}
+		fmt.Fprintf(&sb, "\n## %s\n%s\n", src.name, data)
+	}
+
+	return h.ExecuteIsolated(ctx, chatID, sb.String())
 }
 
-func (h *Handler) GenerateDailySummary() (string, error) {
-	result, err := h.plugins.Execute("task", `{"action": "list"}`)
+// ExecuteIsolated runs prompt with tools but without the chat's history, and
+// nothing is saved to memory. systemChatID is used for tool context, plugin
+// visibility and usage accounting.
+func (h *Handler) ExecuteIsolated(ctx context.Context, systemChatID, prompt string) (string, error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	ctx = tron.WithChatID(ctx, systemChatID)
+
+	messages := []tron.Message{
+		{Role: "system", Content: fmt.Sprintf("%s\n\nCurrent time: %s", h.baseSystemPrompt(systemChatID), time.Now().Format("2006-01-02 15:04:05 MST (Monday)"))},
+		{Role: "user", Content: prompt},
+	}
+	response, _, err := h.runToolLoop(ctx, systemChatID, messages)
+	return response, err
+}
+
+func (h *Handler) ExecutePrompt(ctx context.Context, chatID, prompt string) (string, error) {
+	return h.HandleMessage(ctx, chatID, prompt, 0, nil)
+}
+
+// ExecuteSummary runs prompt in chatID like ExecutePrompt, but with the
+// conversation of the past window as context instead of the usual history,
+// each message stamped with its time.
+func (h *Handler) ExecuteSummary(ctx context.Context, chatID, prompt string, window time.Duration) (string, error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	ctx = tron.WithChatID(ctx, chatID)
+
+	now := time.Now()
+	history, err := h.memory.GetHistoryRange(chatID, now.Add(-window), now, maxSummaryHistory)
 	if err != nil {
-		result = fmt.Sprintf("Error getting tasks: %s", err)
+		h.debugLog("Failed to get history: %v", err)
+	}
+	for i := range history {
+		if history[i].Role == "tool" {
+			continue
+		}
+		history[i].Content = fmt.Sprintf("[%s] %s", history[i].Timestamp.Local().Format("Mon 15:04"), attributed(history[i]))
+		history[i].Sender = ""
 	}
 
-	return fmt.Sprintf("Good morning! Here's your daily summary:\n\n**Tasks:**\n%s", result), nil
+	if err := h.memory.AddMessage(chatID, "user", prompt, 0); err != nil {
+		h.debugLog("Failed to save user message: %v", err)
+	}
+	history = append(history, tron.Message{Role: "user", Content: prompt})
+
+	return h.respond(ctx, chatID, prompt, history, 0, nil)
 }
 
-func (h *Handler) ExecutePrompt(chatID, prompt string) (string, error) {
-	return h.HandleMessage(chatID, prompt, 0)
+// conversationDigest lists the messages of chatID since from, one per line.
+func (h *Handler) conversationDigest(chatID string, from time.Time) (string, error) {
+	history, err := h.memory.GetHistoryRange(chatID, from, time.Now(), maxSummaryHistory)
+	if err != nil {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "No messages.", nil
+	}
+
+	var sb strings.Builder
+	for _, m := range history {
+		if m.Role == "tool" || m.Content == "" {
+			continue
+		}
+		who := m.Role
+		if m.Sender != "" {
+			who = m.Sender
+		}
+		fmt.Fprintf(&sb, "[%s] %s: %s\n", m.Timestamp.Local().Format("Mon Jan 2 15:04"), who, truncateRunes(m.Content, 300))
+	}
+	return sb.String(), nil
 }
@@ -0,0 +1,327 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tron"
+	"tron/llm/llmtest"
+)
+
+// fakeMemory keeps every chat's messages in order, without limits.
+type fakeMemory struct {
+	mu       sync.Mutex
+	messages map[string][]tron.Message
+}
+
+func newFakeMemory() *fakeMemory {
+	return &fakeMemory{messages: make(map[string][]tron.Message)}
+}
+
+func (m *fakeMemory) AddMessage(chatID, role, content string, expiresInSeconds int) error {
+	return m.AddMessages(chatID, []tron.Message{{Role: role, Content: content}}, expiresInSeconds)
+}
+
+func (m *fakeMemory) AddMessages(chatID string, messages []tron.Message, expiresInSeconds int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range messages {
+		msg.Timestamp = time.Now()
+		m.messages[chatID] = append(m.messages[chatID], msg)
+	}
+	return nil
+}
+
+func (m *fakeMemory) GetHistory(chatID string) ([]tron.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]tron.Message(nil), m.messages[chatID]...), nil
+}
+
+func (m *fakeMemory) GetHistoryRange(chatID string, from, to time.Time, limit int) ([]tron.Message, error) {
+	return m.GetHistory(chatID)
+}
+
+func (m *fakeMemory) ClearHistory(chatID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.messages, chatID)
+	return nil
+}
+
+func (m *fakeMemory) Close() error { return nil }
+
+// fakePlugins offers the tools in results and answers each call with the
+// tool's result, recording the calls.
+type fakePlugins struct {
+	mu      sync.Mutex
+	results map[string]string
+	calls   []string
+}
+
+func newFakePlugins(results map[string]string) *fakePlugins {
+	return &fakePlugins{results: results}
+}
+
+func (p *fakePlugins) Execute(ctx context.Context, name, argsJSON string) (string, error) {
+	return p.ExecuteWithContext(ctx, name, argsJSON, "")
+}
+
+func (p *fakePlugins) ExecuteWithContext(ctx context.Context, name, argsJSON, chatID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, name+" "+argsJSON)
+	result, ok := p.results[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	return result, nil
+}
+
+func (p *fakePlugins) GetTools() []tron.Tool {
+	var tools []tron.Tool
+	for name := range p.results {
+		tools = append(tools, tron.Tool{Type: "function", Function: tron.ToolFunction{Name: name}})
+	}
+	return tools
+}
+
+func (p *fakePlugins) GetToolsForChat(chatID string) []tron.Tool { return p.GetTools() }
+func (p *fakePlugins) HasPlugin(name string) bool                { _, ok := p.results[name]; return ok }
+func (p *fakePlugins) PluginCount() int                          { return len(p.results) }
+func (p *fakePlugins) Enable(name string) error                  { return nil }
+func (p *fakePlugins) Disable(name string) error                 { return nil }
+
+func TestHandleMessageToolLoop(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    []*tron.LLMResponse
+		want      string
+		wantTools []string
+	}{{
+		name:   "plain answer",
+		script: []*tron.LLMResponse{llmtest.Reply("Hi!")},
+		want:   "Hi!",
+	}, {
+		name: "one tool call",
+		script: []*tron.LLMResponse{
+			llmtest.CallTool("call_1", "task", `{"action":"list"}`),
+			llmtest.Reply("You have one task."),
+		},
+		want:      "You have one task.",
+		wantTools: []string{`task {"action":"list"}`},
+	}, {
+		name: "several iterations",
+		script: []*tron.LLMResponse{
+			llmtest.CallTool("call_1", "task", `{"action":"list"}`),
+			llmtest.CallTools(
+				llmtest.ToolCall("call_2", "weather", `{"city":"Oslo"}`),
+				llmtest.ToolCall("call_3", "task", `{"action":"done","id":1}`),
+			),
+			llmtest.Reply("Done, and it is sunny."),
+		},
+		want: "Done, and it is sunny.",
+		wantTools: []string{
+			`task {"action":"list"}`,
+			`weather {"city":"Oslo"}`,
+			`task {"action":"done","id":1}`,
+		},
+	}, {
+		name: "unknown tool",
+		script: []*tron.LLMResponse{
+			llmtest.CallTool("call_1", "missing", `{}`),
+			llmtest.Reply("I could not do that."),
+		},
+		want:      "I could not do that.",
+		wantTools: []string{`missing {}`},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := llmtest.NewScriptedClient(tt.script...)
+			plugins := newFakePlugins(map[string]string{"task": "1. Buy milk", "weather": "sunny"})
+			h := NewHandler(llm, plugins, newFakeMemory(), "You are a test.", false)
+
+			got, err := h.HandleMessage(context.Background(), "dm:+100", "hello", 0, nil)
+			if err != nil {
+				t.Fatalf("HandleMessage: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("answer = %q, want %q", got, tt.want)
+			}
+			if fmt.Sprint(plugins.calls) != fmt.Sprint(tt.wantTools) {
+				t.Errorf("tool calls = %v, want %v", plugins.calls, tt.wantTools)
+			}
+			if n := llm.Remaining(); n != 0 {
+				t.Errorf("%d scripted responses not used", n)
+			}
+		})
+	}
+}
+
+func TestHandleMessageSendsToolResults(t *testing.T) {
+	llm := llmtest.NewScriptedClient(
+		llmtest.CallTools(
+			llmtest.ToolCall("call_1", "task", `{}`),
+			llmtest.ToolCall("call_2", "missing", `{}`),
+		),
+		llmtest.Reply("ok"),
+	)
+	h := NewHandler(llm, newFakePlugins(map[string]string{"task": "1. Buy milk"}), newFakeMemory(), "", false)
+
+	if _, err := h.HandleMessage(context.Background(), "dm:+100", "tasks?", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := llm.Call(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.HasTool("task") {
+		t.Error("first call did not offer the task tool")
+	}
+	if last := first.Last(); last.Role != "user" || last.Content != "tasks?" {
+		t.Errorf("first call ends with %+v, want the user message", last)
+	}
+
+	second, err := llm.Call(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{
+		"call_1": "1. Buy milk",
+		"call_2": "Error: unknown tool: missing",
+	} {
+		msg, ok := second.ToolResult(id)
+		if !ok {
+			t.Errorf("second call has no result for %s", id)
+			continue
+		}
+		if msg.Content != want {
+			t.Errorf("result for %s = %q, want %q", id, msg.Content, want)
+		}
+	}
+}
+
+func TestHandleMessageLLMError(t *testing.T) {
+	llm := llmtest.NewScriptedClient(llmtest.CallTool("call_1", "task", `{}`)).ThenError(fmt.Errorf("boom"))
+	h := NewHandler(llm, newFakePlugins(map[string]string{"task": "ok"}), newFakeMemory(), "", false)
+
+	if _, err := h.HandleMessage(context.Background(), "dm:+100", "hi", 0, nil); err == nil {
+		t.Fatal("expected the LLM error to be returned")
+	}
+}
+
+func TestToolLoopCap(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxIterations int
+		final         *tron.LLMResponse
+		wantErr       bool
+	}{
+		{"answers without tools", 3, llmtest.Reply("Here is what I found."), false},
+		{"default cap", 0, llmtest.Reply("Here is what I found."), false},
+		{"model ignores tool_choice none", 3, llmtest.CallTool("call_x", "task", `{}`), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			limit := defaultMaxToolIterations
+			if tt.maxIterations > 0 {
+				opts = append(opts, WithMaxToolIterations(tt.maxIterations))
+				limit = tt.maxIterations
+			}
+
+			llm := llmtest.NewScriptedClient()
+			for i := 1; i < limit; i++ {
+				llm.Then(llmtest.CallTool(fmt.Sprintf("call_%d", i), "task", `{"action":"list"}`))
+			}
+			llm.Then(tt.final)
+			h := NewHandler(llm, newFakePlugins(map[string]string{"task": "nothing"}), newFakeMemory(), "", false, opts...)
+
+			got, err := h.HandleMessage(context.Background(), "dm:+100", "loop", 0, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got answer %q", got)
+				}
+			} else if err != nil || got != tt.final.Content {
+				t.Fatalf("HandleMessage = %q, %v, want %q", got, err, tt.final.Content)
+			}
+
+			calls := llm.Calls()
+			if len(calls) != limit {
+				t.Fatalf("made %d LLM calls, want %d", len(calls), limit)
+			}
+			for i, c := range calls[:limit-1] {
+				if c.Options.ToolChoice != nil {
+					t.Errorf("call %d has tool_choice %v", i, c.Options.ToolChoice)
+				}
+			}
+			last := calls[limit-1]
+			if last.Options.ToolChoice != tron.ToolChoiceNone {
+				t.Errorf("last call tool_choice = %v, want none", last.Options.ToolChoice)
+			}
+			if msg := last.Last(); msg.Role != "system" || msg.Content != finalAnswerNudge {
+				t.Errorf("last call ends with %+v, want the final answer nudge", msg)
+			}
+		})
+	}
+}
+
+func TestContentWithToolCalls(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantInterim []string
+	}{
+		{"with content", "Let me check your tasks", []string{"Let me check your tasks"}},
+		{"blank content", "  ", nil},
+		{"no content", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := llmtest.CallTool("call_1", "task", `{}`)
+			first.Content = tt.content
+			llm := llmtest.NewScriptedClient(first, llmtest.Reply("One task."))
+
+			var interim []string
+			mem := newFakeMemory()
+			h := NewHandler(llm, newFakePlugins(map[string]string{"task": "1. Buy milk"}), mem, "", false,
+				WithToolHistory(1000),
+				WithInterimMessages(func(ctx context.Context, chatID, text string) {
+					interim = append(interim, text)
+				}))
+
+			if _, err := h.HandleMessage(context.Background(), "dm:+100", "tasks?", 0, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if fmt.Sprint(interim) != fmt.Sprint(tt.wantInterim) {
+				t.Errorf("interim messages = %q, want %q", interim, tt.wantInterim)
+			}
+
+			second, err := llm.Call(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			call := second.Messages[len(second.Messages)-2]
+			if call.Role != "assistant" || len(call.ToolCalls) != 1 || call.Content != tt.content {
+				t.Errorf("second call has %+v before the tool result, want the assistant message with content %q", call, tt.content)
+			}
+
+			history, _ := mem.GetHistory("dm:+100")
+			var saved *tron.Message
+			for i := range history {
+				if len(history[i].ToolCalls) > 0 {
+					saved = &history[i]
+				}
+			}
+			if saved == nil || saved.Content != tt.content {
+				t.Errorf("saved tool call message = %+v, want content %q", saved, tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type NextFunc func(ctx context.Context, chatID, message string) (string, error)
+
+// Middleware wraps message handling. It may change the message, short-circuit
+// by not calling next, or change the response.
+type Middleware func(ctx context.Context, chatID, message string, next NextFunc) (string, error)
+
+// RegisterMiddleware adds m to the pipeline. Middleware runs in registration
+// order, so the first registered sees the message first and the response last.
+func (h *Handler) RegisterMiddleware(m Middleware) {
+	h.middleware = append(h.middleware, m)
+}
+
+func TruncateInput(maxChars int) Middleware {
+	return func(ctx context.Context, chatID, message string, next NextFunc) (string, error) {
+		return next(ctx, chatID, truncateRunes(message, maxChars))
+	}
+}
+
+func CapResponse(maxChars int) Middleware {
+	return func(ctx context.Context, chatID, message string, next NextFunc) (string, error) {
+		resp, err := next(ctx, chatID, message)
+		return truncateRunes(resp, maxChars), err
+	}
+}
+
+// FilterWords masks whole-word, case-insensitive matches of words in both the
+// message and the response.
+func FilterWords(words []string) Middleware {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return func(ctx context.Context, chatID, message string, next NextFunc) (string, error) {
+			return next(ctx, chatID, message)
+		}
+	}
+
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	mask := func(s string) string {
+		return pattern.ReplaceAllStringFunc(s, func(m string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(m))
+		})
+	}
+
+	return func(ctx context.Context, chatID, message string, next NextFunc) (string, error) {
+		resp, err := next(ctx, chatID, mask(message))
+		return mask(resp), err
+	}
+}
+
+type AuditRecorder interface {
+	RecordAudit(chatID string, messageLen, responseLen int, duration time.Duration, errMsg string) error
+}
+
+// AuditLog records the size, duration and outcome of every message. Message
+// text is not stored so disappearing messages are not retained.
+func AuditLog(r AuditRecorder) Middleware {
+	return func(ctx context.Context, chatID, message string, next NextFunc) (string, error) {
+		start := time.Now()
+		resp, err := next(ctx, chatID, message)
+
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if recErr := r.RecordAudit(chatID, len(message), len(resp), time.Since(start), errMsg); recErr != nil {
+			log.Printf("Failed to record audit entry: %v", recErr)
+		}
+		return resp, err
+	}
+}
+
+func truncateRunes(s string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(s) <= maxChars {
+		return s
+	}
+	return string([]rune(s)[:maxChars])
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"tron"
+)
+
+// archiveTimeout bounds embedding the answered exchange after replying.
+const archiveTimeout = 30 * time.Second
+
+// WithSemanticRecall archives every exchange with its embedding and, before
+// answering, adds up to topK archived messages with a cosine similarity of
+// at least minScore to the system prompt, cut to about maxTokens. The memory
+// store must implement tron.VectorStore.
+func WithSemanticRecall(embedder tron.Embedder, topK int, minScore float32, maxTokens int) Option {
+	return func(h *Handler) {
+		h.embedder = embedder
+		h.recallK = topK
+		h.recallMinScore = minScore
+		h.recallMaxTokens = maxTokens
+	}
+}
+
+// recall embeds userMessage and returns the archived messages similar to it
+// that are not already in history, formatted for the system prompt, along
+// with the embedding for archiving.
+func (h *Handler) recall(ctx context.Context, chatID, userMessage string, history []tron.Message) (string, []float32) {
+	store, ok := h.memory.(tron.VectorStore)
+	if h.embedder == nil || !ok || strings.TrimSpace(userMessage) == "" {
+		return "", nil
+	}
+
+	vecs, err := h.embedder.Embed(ctx, []string{userMessage})
+	if err != nil || len(vecs) != 1 {
+		log.Printf("Chat %s: failed to embed message for recall: %v", chatID, err)
+		return "", nil
+	}
+
+	inHistory := make(map[string]bool, len(history))
+	for _, m := range history {
+		inHistory[m.Content] = true
+	}
+
+	// Ask for extra matches since some may still be in the history.
+	snippets, err := store.SearchVectors(chatID, vecs[0], h.recallK+len(history), h.recallMinScore)
+	if err != nil {
+		log.Printf("Chat %s: failed to search archived messages: %v", chatID, err)
+		return "", vecs[0]
+	}
+
+	var sb strings.Builder
+	used, found := 0, 0
+	for _, sn := range snippets {
+		if found == h.recallK {
+			break
+		}
+		if inHistory[sn.Content] {
+			continue
+		}
+		line := fmt.Sprintf("- [%s] %s: %s\n", sn.Timestamp.Local().Format("2006-01-02"), sn.Role, sn.Content)
+		if h.recallMaxTokens > 0 && used+estimateTokens(line) > h.recallMaxTokens {
+			remaining := (h.recallMaxTokens - used) * 4
+			if remaining < 80 {
+				break
+			}
+			line = truncateRunes(line, remaining) + "...\n"
+		}
+		sb.WriteString(line)
+		used += estimateTokens(line)
+		found++
+	}
+	if found > 0 {
+		h.debugLog("Recalled %d archived messages", found)
+	}
+
+	return strings.TrimRight(sb.String(), "\n"), vecs[0]
+}
+
+// archive stores the user message and the answer for later recall.
+// userVec is the user message's embedding from recall, if any.
+func (h *Handler) archive(chatID, userMessage, response string, userVec []float32) {
+	store, ok := h.memory.(tron.VectorStore)
+	if h.embedder == nil || !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(tron.WithChatID(context.Background(), chatID), archiveTimeout)
+	defer cancel()
+
+	texts := []string{response}
+	if userVec == nil {
+		texts = append(texts, userMessage)
+	}
+	vecs, err := h.embedder.Embed(ctx, texts)
+	if err != nil || len(vecs) != len(texts) {
+		log.Printf("Chat %s: failed to embed messages for archive: %v", chatID, err)
+		return
+	}
+	if userVec == nil {
+		userVec = vecs[1]
+	}
+
+	if err := store.AddVector(chatID, "user", userMessage, userVec); err != nil {
+		log.Printf("Chat %s: failed to archive message: %v", chatID, err)
+		return
+	}
+	if err := store.AddVector(chatID, "assistant", response, vecs[0]); err != nil {
+		log.Printf("Chat %s: failed to archive message: %v", chatID, err)
+	}
+}
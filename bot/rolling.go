@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"tron"
+)
+
+const rollingSummaryPrompt = "You maintain a running summary of a chat between a user and an assistant. " +
+	"Merge the earlier summary and the new messages into one updated summary. Keep facts, decisions, open questions " +
+	"and anything the user asked to remember; drop small talk. Write plain prose, at most %d words. Reply with the summary only."
+
+// WithRollingSummary makes the handler summarize history that does not fit
+// the context budget instead of dropping it. The summary is kept in memory,
+// updated as more history is compacted, and sent with every request. The
+// memory store must implement tron.SummaryStore; requires WithContextBudget.
+func WithRollingSummary() Option {
+	return func(h *Handler) {
+		h.rollingSummary = true
+	}
+}
+
+// compactHistory replaces history already covered by the chat's rolling
+// summary with the summary. When the rest is over budget, the oldest part
+// is folded into the summary first. On errors the history is returned
+// uncompacted and fitContextBudget drops what does not fit.
+func (h *Handler) compactHistory(ctx context.Context, chatID, systemPrompt string, history []tron.Message) []tron.Message {
+	store, ok := h.memory.(tron.SummaryStore)
+	if !h.rollingSummary || !ok {
+		return history
+	}
+
+	summary, throughID, err := store.GetSummary(chatID)
+	if err != nil {
+		log.Printf("Chat %s: failed to load conversation summary: %v", chatID, err)
+		return history
+	}
+
+	// Pinned messages are never summarized away.
+	var pinned []tron.Message
+	for len(history) > 0 && history[0].Pinned {
+		pinned = append(pinned, history[0])
+		history = history[1:]
+	}
+
+	if summary != "" {
+		kept := history[:0:0]
+		for _, m := range history {
+			if m.ID == 0 || m.ID > throughID {
+				kept = append(kept, m)
+			}
+		}
+		history = kept
+	}
+
+	budget := h.budgetFor(chatID)
+	if budget > 0 && len(history) > 1 {
+		total := estimateTokens(systemPrompt) + estimateToolTokens(h.tools(ctx, chatID)) + estimateTokens(summary)
+		for _, m := range pinned {
+			total += estimateMessageTokens(m)
+		}
+		for _, m := range history {
+			total += estimateMessageTokens(m)
+		}
+		if total > budget {
+			summary, history = h.foldOldest(ctx, store, chatID, summary, history, total, budget)
+		}
+	}
+
+	history = append(pinned, history...)
+	if summary == "" {
+		return history
+	}
+	return append([]tron.Message{{Role: "system", Content: "Conversation summary so far:\n" + summary}}, history...)
+}
+
+// foldOldest summarizes the oldest history until the rest fits in three
+// quarters of the budget, so a summary is not needed on every message.
+func (h *Handler) foldOldest(ctx context.Context, store tron.SummaryStore, chatID, summary string, history []tron.Message, total, budget int) (string, []tron.Message) {
+	target := budget * 3 / 4
+	n := 0
+	for n < len(history)-1 && (total > target || history[n].Role == "tool") {
+		total -= estimateMessageTokens(history[n])
+		n++
+	}
+	// Only stored messages can be marked as covered.
+	for n > 0 && history[n-1].ID == 0 {
+		n--
+	}
+	if n == 0 {
+		return summary, history
+	}
+
+	updated, err := h.summarizeHistory(ctx, chatID, summary, history[:n], max(budget/8, 50))
+	if err != nil {
+		log.Printf("Chat %s: failed to summarize history: %v", chatID, err)
+		return summary, history
+	}
+	if err := store.SetSummary(chatID, updated, history[n-1].ID); err != nil {
+		log.Printf("Chat %s: failed to save conversation summary: %v", chatID, err)
+		return summary, history
+	}
+
+	log.Printf("Chat %s: summarized %d old messages", chatID, n)
+	return updated, history[n:]
+}
+
+func (h *Handler) summarizeHistory(ctx context.Context, chatID, summary string, messages []tron.Message, maxWords int) (string, error) {
+	var sb strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&sb, "Earlier summary:\n%s\n\n", summary)
+	}
+	sb.WriteString("New messages:\n")
+	for _, m := range messages {
+		if m.Content == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, truncateRunes(attributed(m), 2000))
+	}
+
+	resp, err := h.llm.Chat(ctx, []tron.Message{
+		{Role: "system", Content: fmt.Sprintf(rollingSummaryPrompt, maxWords)},
+		{Role: "user", Content: sb.String()},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	h.recordUsage(chatID, resp)
+
+	updated := strings.TrimSpace(resp.Content)
+	if updated == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return updated, nil
+}
@@ -0,0 +1,468 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"tron"
+	"tron/util"
+)
+
+const (
+	typingRefreshInterval = 8 * time.Second
+	queueFullReply        = "I'm still working on your last message. Please try again in a moment."
+	queueTimeoutReply     = "I'm busy with other conversations right now. Please send your message again in a minute."
+)
+
+// Router reads messages from Signal, decides which chat each belongs to and
+// whether to answer it, and sends the handler's replies back.
+type Router struct {
+	client           tron.SignalClient
+	handler          *Handler
+	dispatcher       *Dispatcher
+	operator         string
+	users            map[string]string
+	ackReaction      string
+	maxMessageLength int
+	logSendError     func(prefix string, err error)
+
+	trigger atomic.Value
+	// operatorAddress is the address the operator's first direct message
+	// came from; their chat ID is built from it.
+	operatorAddress atomic.Value
+}
+
+type RouterOption func(*Router)
+
+// WithUsers answers the senders in users, a map of phone number or UUID to
+// role, besides the operator.
+func WithUsers(users map[string]string) RouterOption {
+	return func(r *Router) {
+		r.users = users
+	}
+}
+
+// WithAckReaction reacts with emoji to each message while it is handled.
+func WithAckReaction(emoji string) RouterOption {
+	return func(r *Router) {
+		r.ackReaction = emoji
+	}
+}
+
+// WithMaxMessageLength splits replies longer than n bytes into numbered
+// parts. 0 sends them whole.
+func WithMaxMessageLength(n int) RouterOption {
+	return func(r *Router) {
+		r.maxMessageLength = n
+	}
+}
+
+// WithSendErrorLog replaces how failed sends are logged.
+func WithSendErrorLog(fn func(prefix string, err error)) RouterOption {
+	return func(r *Router) {
+		r.logSendError = fn
+	}
+}
+
+// NewRouter answers messages from operator, a phone number or UUID, and
+// group messages that start with trigger.
+func NewRouter(client tron.SignalClient, handler *Handler, dispatcher *Dispatcher, operator, trigger string, opts ...RouterOption) *Router {
+	r := &Router{
+		client:     client,
+		handler:    handler,
+		dispatcher: dispatcher,
+		operator:   operator,
+		logSendError: func(prefix string, err error) {
+			log.Printf("%s: %v", prefix, err)
+		},
+	}
+	r.trigger.Store(trigger)
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// SetTriggerKeyword changes the keyword group messages must start with.
+func (r *Router) SetTriggerKeyword(trigger string) {
+	r.trigger.Store(trigger)
+}
+
+func (r *Router) triggerKeyword() string {
+	trigger, _ := r.trigger.Load().(string)
+	return trigger
+}
+
+// Run handles incoming messages until ctx is done or the subscription ends,
+// then closes the dispatcher.
+func (r *Router) Run(ctx context.Context) {
+	messages := r.client.SubscribeMessages(ctx)
+	defer r.dispatcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case msg, ok := <-messages:
+			if !ok {
+				log.Println("Message channel closed")
+				return
+			}
+			r.dispatch(ctx, msg)
+		}
+	}
+}
+
+// dispatch routes msg to its chat's worker so chats are handled concurrently
+// while messages within a chat stay in order.
+func (r *Router) dispatch(ctx context.Context, msg tron.IncomingMessage) {
+	chatID, userMessage, ok := r.route(msg)
+	if !ok {
+		return
+	}
+
+	queued := r.dispatcher.Submit(ctx, chatID, func(ctx context.Context) {
+		r.handleMessage(ctx, msg, chatID, userMessage)
+	}, func() {
+		waiting, running := r.dispatcher.Pending()
+		log.Printf("Message for chat=%s waited too long (%d queued, %d running), dropping it", chatID, waiting, running)
+		if err := r.reply(msg, queueTimeoutReply); err != nil {
+			r.logSendError("Error sending busy reply", err)
+		}
+	})
+	if !queued {
+		log.Printf("Queue full for chat=%s, dropping message", chatID)
+		if err := r.reply(msg, queueFullReply); err != nil {
+			r.logSendError("Error sending busy reply", err)
+		}
+	}
+}
+
+// route checks who sent msg and returns the chat it belongs to and the text
+// to hand to the bot.
+func (r *Router) route(msg tron.IncomingMessage) (chatID, userMessage string, ok bool) {
+	group := "-"
+	if msg.IsGroup {
+		group = msg.GroupID
+		if name := r.groupName(msg.GroupID); name != "" {
+			group = name
+		}
+	}
+	log.Printf("Message from: source=%s uuid=%s number=%s name=%s group=%s",
+		msg.Source, msg.SourceUUID, msg.SourceNumber, r.senderName(msg), group)
+
+	role := r.roleOf(msg)
+	if role == tron.RoleIgnored {
+		log.Printf("Ignoring message from unknown sender")
+		return "", "", false
+	}
+
+	userMessage = msg.Message
+
+	if msg.IsGroup {
+		trigger := r.triggerKeyword()
+		if !strings.HasPrefix(userMessage, trigger+" ") {
+			log.Printf("Ignoring group message without trigger keyword")
+			return "", "", false
+		}
+		userMessage = strings.TrimPrefix(userMessage, trigger+" ")
+		chatID = "group:" + msg.GroupID
+	} else if role == tron.RoleOperator {
+		if r.operatorAddress.CompareAndSwap(nil, resolveAddress(msg)) {
+			log.Printf("Operator address set to: %s", r.Operator())
+		}
+		chatID = "dm:" + r.Operator()
+	} else {
+		chatID = "dm:" + resolveAddress(msg)
+	}
+
+	if msg.ReplyToTimestamp != 0 && msg.ReplyToText != "" {
+		userMessage = fmt.Sprintf("[replying to: %q]\n%s", msg.ReplyToText, userMessage)
+	}
+
+	return chatID, userMessage, true
+}
+
+func (r *Router) handleMessage(ctx context.Context, msg tron.IncomingMessage, chatID, userMessage string) {
+	log.Printf("Received message (chat=%s, expires=%ds, attachments=%d): %s", chatID, msg.ExpiresInSeconds, len(msg.Attachments), userMessage)
+
+	r.acknowledge(msg, false)
+	defer r.acknowledge(msg, true)
+
+	stopTyping := r.startTyping(ctx, msg)
+
+	ctx = context.WithValue(ctx, incomingKey{}, msg)
+	ctx = tron.WithRole(ctx, r.roleOf(msg))
+	if msg.Timestamp > 0 {
+		ctx = tron.WithSentAt(ctx, time.UnixMilli(msg.Timestamp))
+	}
+	if msg.IsGroup {
+		ctx = tron.WithSender(ctx, r.senderName(msg))
+	}
+	response, err := r.handler.HandleMessage(ctx, chatID, userMessage, msg.ExpiresInSeconds, msg.Attachments)
+	if err != nil {
+		log.Printf("Error handling message: %v", err)
+		response = "Sorry, I encountered an error processing your request."
+	}
+	stopTyping()
+
+	if err := r.reply(msg, response); err != nil {
+		r.logSendError("Error sending response", err)
+	}
+}
+
+type incomingKey struct{}
+
+// SendInterim sends text the model wrote while calling tools. Replies to a
+// message keep its disappearing timer.
+func (r *Router) SendInterim(ctx context.Context, chatID, text string) {
+	var err error
+	if msg, ok := ctx.Value(incomingKey{}).(tron.IncomingMessage); ok {
+		err = r.reply(msg, text)
+	} else {
+		err = r.SendToChat(chatID, text)
+	}
+	if err != nil {
+		r.logSendError("Error sending interim message", err)
+	}
+}
+
+// SendToChat sends message to chatID, split into parts if it is too long.
+// An empty chatID sends to the operator.
+func (r *Router) SendToChat(chatID, message string) error {
+	var send func(string) error
+	switch {
+	case chatID == "":
+		recipient := r.OperatorRecipient()
+		send = func(m string) error { return r.client.SendMessage(recipient, m) }
+	case strings.HasPrefix(chatID, "group:"):
+		groupID := strings.TrimPrefix(chatID, "group:")
+		send = func(m string) error { return r.client.SendGroupMessage(groupID, m) }
+	case strings.HasPrefix(chatID, "dm:"):
+		recipient := formatRecipient(strings.TrimPrefix(chatID, "dm:"))
+		send = func(m string) error { return r.client.SendMessage(recipient, m) }
+	default:
+		return fmt.Errorf("invalid chat id: %s", chatID)
+	}
+
+	for _, chunk := range r.chunks(message) {
+		if err := send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Router) chunks(message string) []string {
+	const counterReserve = len("(99/99) ")
+
+	if r.maxMessageLength <= 0 || len(message) <= r.maxMessageLength {
+		return []string{message}
+	}
+
+	chunks := util.SplitMessage(message, r.maxMessageLength-counterReserve)
+	if len(chunks) > 1 {
+		for i := range chunks {
+			chunks[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(chunks), chunks[i])
+		}
+	}
+	return chunks
+}
+
+func (r *Router) reply(msg tron.IncomingMessage, response string) error {
+	author := resolveAddress(msg)
+	for i, chunk := range r.chunks(response) {
+		var err error
+		switch {
+		// Disappearing messages are not quoted so the user's text does not
+		// outlive their own message inside the bot's reply.
+		case msg.IsGroup && msg.ExpiresInSeconds > 0:
+			err = r.client.SendGroupMessageWithExpiry(msg.GroupID, chunk, msg.ExpiresInSeconds)
+		case msg.ExpiresInSeconds > 0:
+			err = r.client.SendMessageWithExpiry(r.dmRecipient(msg), chunk, msg.ExpiresInSeconds)
+		case msg.IsGroup && i == 0:
+			err = r.client.SendGroupReply(msg.GroupID, msg.Timestamp, author, msg.Message, chunk)
+		case msg.IsGroup:
+			err = r.client.SendGroupMessage(msg.GroupID, chunk)
+		case i == 0:
+			err = r.client.SendReply(r.dmRecipient(msg), msg.Timestamp, author, msg.Message, chunk)
+		default:
+			err = r.client.SendMessage(r.dmRecipient(msg), chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Router) acknowledge(msg tron.IncomingMessage, remove bool) {
+	emoji := r.ackReaction
+	if emoji == "" || msg.Timestamp == 0 {
+		return
+	}
+
+	author := resolveAddress(msg)
+	var err error
+	switch {
+	case msg.IsGroup && remove:
+		err = r.client.RemoveGroupReaction(msg.GroupID, author, msg.Timestamp, emoji)
+	case msg.IsGroup:
+		err = r.client.SendGroupReaction(msg.GroupID, author, msg.Timestamp, emoji)
+	case remove:
+		err = r.client.RemoveReaction(formatRecipient(author), author, msg.Timestamp, emoji)
+	default:
+		err = r.client.SendReaction(formatRecipient(author), author, msg.Timestamp, emoji)
+	}
+	if err != nil {
+		log.Printf("Error updating acknowledgement reaction: %v", err)
+	}
+}
+
+func (r *Router) startTyping(ctx context.Context, msg tron.IncomingMessage) func() {
+	send := func(stop bool) {
+		var err error
+		if msg.IsGroup {
+			err = r.client.SendGroupTyping(msg.GroupID, stop)
+		} else {
+			err = r.client.SendTyping(r.dmRecipient(msg), stop)
+		}
+		if err != nil {
+			log.Printf("Error sending typing indicator: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(typingRefreshInterval)
+		defer ticker.Stop()
+
+		send(false)
+		for {
+			select {
+			case <-ctx.Done():
+				send(true)
+				return
+			case <-ticker.C:
+				send(false)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Operator returns the operator's address once it has been learned from
+// their first direct message.
+func (r *Router) Operator() string {
+	addr, _ := r.operatorAddress.Load().(string)
+	return addr
+}
+
+// OperatorRecipient returns the address to send the operator messages at.
+func (r *Router) OperatorRecipient() string {
+	if addr := r.Operator(); addr != "" {
+		return addr
+	}
+	return formatRecipient(r.operator)
+}
+
+// OperatorChatID returns the chat ID of the operator's direct messages.
+func (r *Router) OperatorChatID() string {
+	addr := r.Operator()
+	if addr == "" {
+		addr = strings.TrimPrefix(r.operator, "u:")
+	}
+	return "dm:" + addr
+}
+
+// dmRecipient returns the address to answer a direct message at.
+func (r *Router) dmRecipient(msg tron.IncomingMessage) string {
+	if isSender(msg, r.operator) {
+		return r.OperatorRecipient()
+	}
+	return formatRecipient(resolveAddress(msg))
+}
+
+// roleOf returns the role of msg's sender: operator for the operator, the
+// role given in users, or ignored.
+func (r *Router) roleOf(msg tron.IncomingMessage) string {
+	if isSender(msg, r.operator) {
+		return tron.RoleOperator
+	}
+	for user, role := range r.users {
+		if isSender(msg, user) {
+			return role
+		}
+	}
+	return tron.RoleIgnored
+}
+
+// senderName is the sender's Signal profile name, or the name the client
+// knows for their address, or the address itself.
+func (r *Router) senderName(msg tron.IncomingMessage) string {
+	if msg.SourceName != "" {
+		return msg.SourceName
+	}
+	if c, ok := r.client.(interface{ DisplayName(address string) string }); ok {
+		return c.DisplayName(resolveAddress(msg))
+	}
+	return resolveAddress(msg)
+}
+
+func (r *Router) groupName(groupID string) string {
+	if c, ok := r.client.(interface{ GroupName(groupID string) string }); ok {
+		return c.GroupName(groupID)
+	}
+	return ""
+}
+
+func resolveAddress(msg tron.IncomingMessage) string {
+	if msg.SourceUUID != "" {
+		return msg.SourceUUID
+	}
+	if msg.SourceNumber != "" {
+		return msg.SourceNumber
+	}
+	return msg.Source
+}
+
+func formatRecipient(account string) string {
+	if strings.HasPrefix(account, "+") || strings.HasPrefix(account, "u:") {
+		return account
+	}
+	return "u:" + account
+}
+
+// isSender reports whether msg was sent by addr, a phone number or UUID.
+// The profile name is never compared: the sender picks it and could set it
+// to someone else's number.
+func isSender(msg tron.IncomingMessage, addr string) bool {
+	addr = normalizeAddress(addr)
+	if addr == "" {
+		return false
+	}
+	for _, c := range []string{msg.Source, msg.SourceUUID, msg.SourceNumber} {
+		if normalizeAddress(c) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeAddress(addr string) string {
+	addr = strings.TrimPrefix(addr, "+")
+	addr = strings.TrimPrefix(addr, "u:")
+	return strings.ToLower(addr)
+}
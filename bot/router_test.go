@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"testing"
+
+	"tron"
+)
+
+func TestRoleOf(t *testing.T) {
+	r := NewRouter(nil, nil, nil, "+100", "tron", WithUsers(map[string]string{"+200": "family"}))
+
+	tests := []struct {
+		name string
+		msg  tron.IncomingMessage
+		want string
+	}{
+		{"operator by number", tron.IncomingMessage{Source: "+100"}, tron.RoleOperator},
+		{"operator by source number", tron.IncomingMessage{Source: "u:abc", SourceNumber: "+100"}, tron.RoleOperator},
+		{"configured user", tron.IncomingMessage{Source: "+200"}, "family"},
+		{"unknown sender", tron.IncomingMessage{Source: "+300"}, tron.RoleIgnored},
+		{"profile name spoofing operator", tron.IncomingMessage{Source: "+300", SourceName: "+100"}, tron.RoleIgnored},
+		{"profile name spoofing user", tron.IncomingMessage{Source: "+300", SourceUUID: "def", SourceName: "200"}, tron.RoleIgnored},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.roleOf(tt.msg); got != tt.want {
+				t.Errorf("roleOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         tron.IncomingMessage
+		wantChatID  string
+		wantMessage string
+		wantOK      bool
+	}{
+		{"operator DM", tron.IncomingMessage{Source: "+100", Message: "hi"}, "dm:+100", "hi", true},
+		{"user DM", tron.IncomingMessage{Source: "+200", SourceUUID: "def", Message: "hi"}, "dm:def", "hi", true},
+		{"unknown sender DM", tron.IncomingMessage{Source: "+300", Message: "hi"}, "", "", false},
+		{"group with trigger", tron.IncomingMessage{Source: "+200", GroupID: "g1", IsGroup: true, Message: "tron what time is it"},
+			"group:g1", "what time is it", true},
+		{"group without trigger", tron.IncomingMessage{Source: "+200", GroupID: "g1", IsGroup: true, Message: "what time is it"}, "", "", false},
+		{"group with trigger inside a word", tron.IncomingMessage{Source: "+100", GroupID: "g1", IsGroup: true, Message: "tronic"}, "", "", false},
+		{"group trigger alone", tron.IncomingMessage{Source: "+100", GroupID: "g1", IsGroup: true, Message: "tron"}, "", "", false},
+		{"group from unknown sender", tron.IncomingMessage{Source: "+300", GroupID: "g1", IsGroup: true, Message: "tron hi"}, "", "", false},
+		{"reply quote", tron.IncomingMessage{Source: "+200", Message: "and tomorrow?", ReplyToTimestamp: 1, ReplyToText: "Sunny"},
+			"dm:+200", "[replying to: \"Sunny\"]\nand tomorrow?", true},
+		{"reply without quoted text", tron.IncomingMessage{Source: "+200", Message: "ok", ReplyToTimestamp: 1}, "dm:+200", "ok", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(nil, nil, nil, "+100", "tron", WithUsers(map[string]string{"+200": "family"}))
+
+			chatID, message, ok := r.route(tt.msg)
+			if ok != tt.wantOK || chatID != tt.wantChatID || message != tt.wantMessage {
+				t.Errorf("route() = %q, %q, %v, want %q, %q, %v", chatID, message, ok, tt.wantChatID, tt.wantMessage, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRouteOperatorAddress(t *testing.T) {
+	tests := []struct {
+		name         string
+		msgs         []tron.IncomingMessage
+		wantChatIDs  []string
+		wantOperator string
+	}{{
+		name:         "first DM sets the address",
+		msgs:         []tron.IncomingMessage{{Source: "+100", SourceUUID: "abc"}},
+		wantChatIDs:  []string{"dm:abc"},
+		wantOperator: "abc",
+	}, {
+		name: "later DMs keep the first address",
+		msgs: []tron.IncomingMessage{
+			{Source: "+100", SourceUUID: "abc"},
+			{Source: "+100"},
+			{Source: "u:ABC", SourceNumber: "+100"},
+		},
+		wantChatIDs:  []string{"dm:abc", "dm:abc", "dm:abc"},
+		wantOperator: "abc",
+	}, {
+		name: "group messages do not set it",
+		msgs: []tron.IncomingMessage{
+			{Source: "+100", SourceUUID: "abc", GroupID: "g1", IsGroup: true},
+			{Source: "+100"},
+		},
+		wantChatIDs:  []string{"group:g1", "dm:+100"},
+		wantOperator: "+100",
+	}, {
+		name: "other senders do not set it",
+		msgs: []tron.IncomingMessage{
+			{Source: "+200"},
+			{Source: "+300"},
+		},
+		wantChatIDs: []string{"dm:+200", ""},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter(nil, nil, nil, "+100", "tron", WithUsers(map[string]string{"+200": "family"}))
+
+			for i, msg := range tt.msgs {
+				msg.Message = "tron hi"
+				chatID, _, _ := r.route(msg)
+				if chatID != tt.wantChatIDs[i] {
+					t.Errorf("message %d: chat ID = %q, want %q", i, chatID, tt.wantChatIDs[i])
+				}
+			}
+			if got := r.Operator(); got != tt.wantOperator {
+				t.Errorf("Operator() = %q, want %q", got, tt.wantOperator)
+			}
+			if tt.wantOperator != "" {
+				if got, want := r.OperatorChatID(), "dm:"+tt.wantOperator; got != want {
+					t.Errorf("OperatorChatID() = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetTriggerKeyword(t *testing.T) {
+	r := NewRouter(nil, nil, nil, "+100", "tron")
+	msg := tron.IncomingMessage{Source: "+100", GroupID: "g1", IsGroup: true, Message: "bot hi"}
+
+	if _, _, ok := r.route(msg); ok {
+		t.Fatal("routed a message with the new keyword before the change")
+	}
+	r.SetTriggerKeyword("bot")
+	if _, message, ok := r.route(msg); !ok || message != "hi" {
+		t.Errorf("route() = %q, %v after the change, want %q, true", message, ok, "hi")
+	}
+}
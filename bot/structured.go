@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tron"
+	"tron/util"
+)
+
+// ExecutePromptStructured runs prompt in chatID without tools or history and
+// returns the model's answer as JSON. With a schema the answer must match it,
+// otherwise any JSON value will do. An invalid answer is sent back to the
+// model once with the error before giving up.
+func (h *Handler) ExecutePromptStructured(ctx context.Context, chatID, prompt string, schema map[string]interface{}) (string, error) {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	ctx = tron.WithChatID(ctx, chatID)
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("marshal schema: %w", err)
+	}
+
+	opts := tron.ChatOptions{ResponseFormat: &tron.ResponseFormat{Type: "json_object"}}
+	if schema != nil {
+		opts.ResponseFormat = &tron.ResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &tron.JSONSchemaFormat{Name: "response", Schema: schema},
+		}
+	}
+
+	systemPrompt := fmt.Sprintf("%s\n\nCurrent time: %s\n\nRespond with a single JSON value only, no prose or code fences.",
+		h.baseSystemPrompt(chatID), time.Now().Format("2006-01-02 15:04:05 MST (Monday)"))
+	if schema != nil {
+		systemPrompt += fmt.Sprintf(" It must match this JSON schema:\n%s", schemaJSON)
+	}
+
+	messages := []tron.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		resp, err := h.chat(ctx, chatID, messages, nil, opts)
+		if err != nil {
+			return "", fmt.Errorf("llm chat: %w", err)
+		}
+		h.recordUsage(chatID, resp)
+
+		output := stripCodeFence(resp.Content)
+		if lastErr = validateJSON(output, schema); lastErr == nil {
+			return output, nil
+		}
+		h.debugLog("Structured output invalid (attempt %d): %v", attempt+1, lastErr)
+
+		messages = append(messages,
+			tron.Message{Role: "assistant", Content: resp.Content},
+			tron.Message{Role: "user", Content: fmt.Sprintf("That response was invalid: %v. Reply again with only the corrected JSON.", lastErr)},
+		)
+	}
+
+	return "", fmt.Errorf("invalid structured output: %w", lastErr)
+}
+
+func validateJSON(output string, schema map[string]interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	return util.ValidateSchema(value, schema)
+}
+
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
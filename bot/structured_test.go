@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"tron/llm/llmtest"
+)
+
+func TestExecutePromptStructured(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"done"},
+		"properties": map[string]interface{}{
+			"done": map[string]interface{}{"type": "integer"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		schema    map[string]interface{}
+		replies   []string
+		want      string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"valid", schema, []string{`{"done": 3}`}, `{"done": 3}`, false, 1},
+		{"code fence", schema, []string{"```json\n{\"done\": 3}\n```"}, `{"done": 3}`, false, 1},
+		{"any JSON without schema", nil, []string{`[1, 2]`}, `[1, 2]`, false, 1},
+		{"retried once", schema, []string{`{"done": "three"}`, `{"done": 3}`}, `{"done": 3}`, false, 2},
+		{"not JSON twice", schema, []string{"three", "still three"}, "", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := llmtest.NewScriptedClient()
+			for _, r := range tt.replies {
+				llm.Then(llmtest.Reply(r))
+			}
+			h := NewHandler(llm, newFakePlugins(nil), newFakeMemory(), "", false)
+
+			got, err := h.ExecutePromptStructured(context.Background(), "dm:+100", "How many tasks are done?", tt.schema)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ExecutePromptStructured() = %q, %v, want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+
+			calls := llm.Calls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("made %d LLM calls, want %d", len(calls), tt.wantCalls)
+			}
+			format := calls[0].Options.ResponseFormat
+			wantType := "json_object"
+			if tt.schema != nil {
+				wantType = "json_schema"
+			}
+			if format == nil || format.Type != wantType {
+				t.Errorf("response format = %+v, want %s", format, wantType)
+			}
+			if tt.wantCalls > 1 {
+				if last := calls[1].Last(); last.Role != "user" || !strings.Contains(last.Content, "invalid") {
+					t.Errorf("retry ends with %+v, want the validation error", last)
+				}
+			}
+		})
+	}
+}
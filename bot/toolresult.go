@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"tron"
+)
+
+// maxSummarizedToolResult caps how much of an oversized result is handed to
+// the summarizing call, which has a context window of its own.
+const maxSummarizedToolResult = 60000
+
+const toolResultSummaryPrompt = "You condense tool output for another assistant. " +
+	"Keep every detail relevant to the tool call's arguments (names, IDs, numbers, dates, errors) and drop the rest. " +
+	"Reply with the condensed output only."
+
+// WithMaxToolResult cuts tool results longer than maxChars characters before
+// they are sent to the LLM. 0 disables the limit.
+func WithMaxToolResult(maxChars int) Option {
+	return func(h *Handler) {
+		h.liveToolResultMax = maxChars
+	}
+}
+
+// WithToolResultSummaries condenses oversized tool results with a separate
+// LLM call instead of cutting them. Requires WithMaxToolResult.
+func WithToolResultSummaries() Option {
+	return func(h *Handler) {
+		h.summarizeToolResults = true
+	}
+}
+
+// WithFullToolOutput passes the complete output of every oversized tool
+// result to keep, so it can be looked at later.
+func WithFullToolOutput(keep func(chatID, tool, output string)) Option {
+	return func(h *Handler) {
+		h.keepToolOutput = keep
+	}
+}
+
+func (h *Handler) limitToolResult(ctx context.Context, chatID, name, argsJSON, result string) string {
+	size := utf8.RuneCountInString(result)
+	if h.liveToolResultMax <= 0 || size <= h.liveToolResultMax {
+		return result
+	}
+
+	log.Printf("Tool %s returned %d characters (%d bytes), limit is %d", name, size, len(result), h.liveToolResultMax)
+	if h.keepToolOutput != nil {
+		h.keepToolOutput(chatID, name, result)
+	}
+
+	var sb strings.Builder
+	if h.summarizeToolResults {
+		summary, err := h.summarizeToolResult(ctx, chatID, name, argsJSON, result)
+		if err == nil {
+			sb.WriteString(truncateRunes(summary, h.liveToolResultMax))
+			fmt.Fprintf(&sb, "\n[condensed from %d characters]", size)
+		} else {
+			log.Printf("Failed to summarize result of tool %s: %v", name, err)
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString(truncateRunes(result, h.liveToolResultMax))
+		if contentType(result) == tron.ContentTypeJSON {
+			fmt.Fprintf(&sb, "\n[truncated JSON, not valid as shown: showing %d of %d characters]", h.liveToolResultMax, size)
+		} else {
+			fmt.Fprintf(&sb, "\n[truncated: showing %d of %d characters]", h.liveToolResultMax, size)
+		}
+	}
+	if h.keepToolOutput != nil {
+		sb.WriteString("\n[the full output can be read with the debug tool's tool_output option]")
+	}
+	return sb.String()
+}
+
+// contentType tells JSON tool output from plain text.
+func contentType(result string) string {
+	s := strings.TrimSpace(result)
+	if (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) && json.Valid([]byte(s)) {
+		return tron.ContentTypeJSON
+	}
+	return tron.ContentTypeText
+}
+
+func (h *Handler) summarizeToolResult(ctx context.Context, chatID, name, argsJSON, result string) (string, error) {
+	messages := []tron.Message{
+		{Role: "system", Content: toolResultSummaryPrompt},
+		{Role: "user", Content: fmt.Sprintf("Tool: %s\nArguments: %s\n\nOutput:\n%s",
+			name, argsJSON, truncateRunes(result, maxSummarizedToolResult))},
+	}
+
+	resp, err := h.llm.Chat(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	h.recordUsage(chatID, resp)
+
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
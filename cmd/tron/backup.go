@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"tron/config"
+	"tron/memory"
+)
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("output", "", "Path to write the backup to (required)")
+	configPath := fs.String("config", "", "Path to YAML config file (for db_path)")
+	dbPath := fs.String("db", "", "Database to back up (overrides db_path)")
+	fs.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("-output is required")
+	}
+
+	src := *dbPath
+	if src == "" {
+		cfg, err := config.Load(*configPath, false)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		if cfg.DBDriver != "sqlite3" {
+			return fmt.Errorf("backup is only supported for sqlite3, not %s", cfg.DBDriver)
+		}
+		src = cfg.DBPath
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	start := time.Now()
+	last := -1
+	err := memory.Backup(ctx, src, *output, func(copied, total int) {
+		if total == 0 {
+			return
+		}
+		if pct := copied * 100 / total; pct != last {
+			last = pct
+			fmt.Printf("\rCopied %d/%d pages (%d%%)", copied, total, pct)
+		}
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %s to %s in %s\n", src, *output, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func (a *app) autoBackup(ctx context.Context) {
+	interval := time.Duration(a.cfg.AutoBackupIntervalHours) * time.Hour
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := a.memoryStore.Backup(ctx, a.cfg.AutoBackupPath, nil); err != nil {
+				log.Printf("Automatic backup failed: %v", err)
+				continue
+			}
+			log.Printf("Backed up database to %s in %s", a.cfg.AutoBackupPath, time.Since(start).Round(time.Millisecond))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"tron/config"
+	"tron/memory"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("output", "-", "File to write the export to (- for stdout)")
+	configPath := fs.String("config", "", "Path to YAML config file (for db_path)")
+	dbPath := fs.String("db", "", "SQLite database to export (overrides db_path)")
+	fs.Parse(args)
+
+	store, err := openStore(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := store.Export(w); err != nil {
+		return err
+	}
+	if *output != "-" {
+		fmt.Fprintf(os.Stderr, "Exported to %s\n", *output)
+	}
+	return nil
+}
+
+// runMigrateEncrypt encrypts the plaintext left in a database from before
+// db_encryption_key was set.
+func runMigrateEncrypt(args []string) error {
+	fs := flag.NewFlagSet("migrate-encrypt", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to YAML config file (for db_path and db_encryption_key)")
+	dbPath := fs.String("db", "", "SQLite database to encrypt (overrides db_path)")
+	fs.Parse(args)
+
+	store, err := openStore(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	result, err := store.EncryptExisting()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d messages, %d tool calls, %d archived messages and %d summaries\n",
+		result.Messages, result.ToolCalls, result.Archived, result.Summaries)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	input := fs.String("input", "-", "Export file to read (- for stdin)")
+	configPath := fs.String("config", "", "Path to YAML config file (for db_path)")
+	dbPath := fs.String("db", "", "SQLite database to import into (overrides db_path)")
+	merge := fs.Bool("merge", false, "Import into a database that already has data, skipping duplicates")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	store, err := openStore(*configPath, *dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	result, err := store.Import(r, *merge)
+	if errors.Is(err, memory.ErrNotEmpty) {
+		return fmt.Errorf("%w; use -merge to import anyway", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d messages and %d facts (%d duplicates skipped)\n", result.Messages, result.Facts, result.Skipped)
+	return nil
+}
+
+// openStore opens the database given by -db, or by the config's db_path and
+// db_driver.
+func openStore(configPath, dbPath string) (*memory.Store, error) {
+	cfg, err := config.Load(configPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	driver := cfg.DBDriver
+	if dbPath == "" {
+		dbPath = cfg.DBPath
+	} else {
+		driver = "sqlite3"
+	}
+
+	key, err := cfg.EncryptionKey()
+	if err != nil {
+		return nil, fmt.Errorf("db_encryption_key: %w", err)
+	}
+
+	store, err := memory.NewStore(dbPath, cfg.MemoryMaxMessages, cfg.MemoryMaxMinutes,
+		memory.WithDriver(driver), memory.WithEncryptionKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	return store, nil
+}
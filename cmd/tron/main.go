@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"tron"
+	"tron/api"
 	"tron/bot"
 	"tron/config"
 	"tron/llm"
@@ -17,18 +23,55 @@ import (
 	"tron/plugins"
 	"tron/scheduler"
 	signalcli "tron/signal"
+	"tron/timezone"
 )
 
 type app struct {
-	cfg             *config.Config
-	signalClient    *signalcli.Client
-	handler         *bot.Handler
-	memoryStore     *memory.Store
-	sched           *scheduler.Scheduler
-	operatorAddress string
+	cfg           *config.Config
+	signalClient  *signalcli.Client
+	handler       *bot.Handler
+	router        *bot.Router
+	memoryStore   *memory.Store
+	pluginManager *plugins.Manager
+	sched         *scheduler.Scheduler
+	admin         *bot.Admin
+
+	reloadMu  sync.Mutex
+	reloadCfg *config.Config
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "register" {
+		if err := runRegister(os.Args[2:]); err != nil {
+			log.Fatalf("Registration failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		if err := runBackup(os.Args[2:]); err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-encrypt" {
+		if err := runMigrateEncrypt(os.Args[2:]); err != nil {
+			log.Fatalf("Encryption failed: %v", err)
+		}
+		return
+	}
+
 	debug := flag.Bool("debug", false, "Enable debug logging")
 	configPath := flag.String("config", "", "Path to YAML config file")
 	flag.Parse()
@@ -47,52 +90,308 @@ func main() {
 	}
 	defer cleanup()
 
+	if err := a.admin.SyncProfile(cfg.SignalProfileName, cfg.SignalProfileAbout, cfg.SignalProfileAvatar); err != nil {
+		log.Printf("Failed to update Signal profile: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	go a.sched.Start(ctx)
+	go func() {
+		if err := a.pluginManager.WatchDir(ctx); err != nil {
+			log.Printf("Plugin auto-loading disabled: %v", err)
+		}
+	}()
+	if cfg.AutoBackupPath != "" {
+		go a.autoBackup(ctx)
+	}
+
+	if *configPath != "" {
+		stopWatch, err := config.Watch(*configPath, a.applyConfig)
+		if err != nil {
+			log.Printf("Config reload disabled: %v", err)
+		} else {
+			defer stopWatch()
+		}
+	}
+
+	if cfg.APIAddr != "" {
+		server := api.NewServer(cfg.APIAddr, cfg.APIToken, a.memoryStore, a.router.SendToChat, a.handler.ExecutePrompt,
+			api.WithStructuredExecute(a.handler.ExecutePromptStructured))
+		go func() {
+			if err := server.Start(ctx); err != nil {
+				log.Printf("API server error: %v", err)
+			}
+		}()
+	}
 
 	a.run(ctx, cancel)
 }
 
+// applyConfig applies the settings that can change without a restart.
+func (a *app) applyConfig(cfg *config.Config) {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	prev := a.reloadCfg
+	if prev == nil {
+		prev = a.cfg
+	}
+	cfg.Debug = a.cfg.Debug
+	changed := config.Diff(prev, cfg)
+	if len(changed) == 0 {
+		return
+	}
+	a.reloadCfg = cfg
+	log.Printf("Config changed: %s", strings.Join(changed, ", "))
+
+	a.handler.UpdateSystemPrompt(cfg.LLMSystemPrompt)
+	a.router.SetTriggerKeyword(cfg.TriggerKeyword)
+
+	for _, name := range changed {
+		if name != "llm_system_prompt" && name != "trigger_keyword" {
+			log.Printf("Some changes need a restart to take effect (applied: llm_system_prompt, trigger_keyword)")
+			break
+		}
+	}
+}
+
 func logConfig(cfg *config.Config) {
 	log.Printf("Starting Signal bot...")
 	log.Printf("  Bot account: %s", cfg.SignalBotAccount)
 	log.Printf("  Operator: %s", cfg.SignalOperator)
-	log.Printf("  LLM: %s @ %s", cfg.LLMModel, cfg.LLMAPIURL)
+	log.Printf("  LLM: %s %s @ %s (temperature=%s max_tokens=%s top_p=%s)", cfg.LLMProvider, cfg.LLMModel, cfg.LLMAPIURL,
+		formatOptional(cfg.LLMTemperature), formatOptional(cfg.LLMMaxTokens), formatOptional(cfg.LLMTopP))
 	log.Printf("  Plugin dir: %s", cfg.PluginDir)
-	log.Printf("  Database: %s", cfg.DBPath)
+	if cfg.DBDriver == "sqlite3" {
+		log.Printf("  Database: %s", cfg.DBPath)
+	} else {
+		log.Printf("  Database: %s", cfg.DBDriver)
+	}
 	log.Printf("  Trigger keyword: %s", cfg.TriggerKeyword)
+	if cfg.APIAddr != "" {
+		log.Printf("  API: %s", cfg.APIAddr)
+	}
 	log.Printf("  Memory: %d messages, %d minutes", cfg.MemoryMaxMessages, cfg.MemoryMaxMinutes)
-	log.Printf("  Daily summary: %02d:00 PDT", cfg.DailySummaryHour)
+	for _, sc := range cfg.Summaries {
+		recipient := sc.Recipient
+		if recipient == "" {
+			recipient = "operator"
+		}
+		log.Printf("  Summary %s: %02d:%02d %s -> %s", sc.Name, sc.Hour, sc.Minute, sc.Timezone, recipient)
+	}
 }
 
-func newApp(cfg *config.Config) (*app, func(), error) {
-	signalClient := signalcli.NewClient(cfg.SignalCLIURL, cfg.SignalBotAccount)
-	llmClient := llm.NewClient(cfg.LLMAPIURL, cfg.LLMAPIKey, cfg.LLMModel)
+func formatOptional[T any](v *T) string {
+	if v == nil {
+		return "default"
+	}
+	return fmt.Sprint(*v)
+}
+
+func pluginCacheDir(cfg *config.Config) string {
+	if cfg.PluginCacheDir != "" {
+		return cfg.PluginCacheDir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "tron", "plugins")
+	}
+	return filepath.Join(cfg.PluginDir, ".registry")
+}
+
+func newLLMClient(cfg *config.Config, usage tron.UsageRecorder, recorder *llm.Recorder) tron.LLMClient {
+	opts := []llm.Option{
+		llm.WithMaxRetries(cfg.LLMMaxRetries),
+		llm.WithEmbeddingModel(cfg.LLMEmbeddingModel),
+		llm.WithUsageRecorder(usage),
+		llm.WithRecorder(recorder),
+		llm.WithExtraHeaders(cfg.LLMExtraHeaders),
+		llm.WithOrganization(cfg.LLMOrganization),
+		llm.WithTimeout(time.Duration(cfg.LLMTimeout) * time.Second),
+		llm.WithChatOptions(tron.ChatOptions{
+			Temperature:      cfg.LLMTemperature,
+			MaxTokens:        cfg.LLMMaxTokens,
+			TopP:             cfg.LLMTopP,
+			Stop:             cfg.LLMStop,
+			FrequencyPenalty: cfg.LLMFrequencyPenalty,
+			PresencePenalty:  cfg.LLMPresencePenalty,
+		}),
+	}
 
-	memoryStore, err := memory.NewStore(cfg.DBPath, cfg.MemoryMaxMessages, cfg.MemoryMaxMinutes)
+	if cfg.LLMContentArrayMode {
+		opts = append(opts, llm.WithContentArrays())
+	}
+
+	switch cfg.LLMProvider {
+	case "anthropic":
+		return llm.NewAnthropicClient(cfg.LLMAPIURL, cfg.LLMAPIKey, cfg.LLMModel, opts...)
+	case "ollama":
+		opts = append(opts, llm.WithOllamaCompat(cfg.LLMKeepAlive))
+		return llm.NewClient(cfg.LLMAPIURL, cfg.LLMAPIKey, cfg.LLMModel, opts...)
+	case "azure":
+		opts = append(opts, llm.WithAzure(cfg.LLMAzureDeployment, cfg.LLMAzureAPIVersion))
+		return llm.NewClient(cfg.LLMAPIURL, cfg.LLMAPIKey, cfg.LLMModel, opts...)
+	default:
+		return llm.NewClient(cfg.LLMAPIURL, cfg.LLMAPIKey, cfg.LLMModel, opts...)
+	}
+}
+
+func newApp(cfg *config.Config) (*app, func(), error) {
+	signalClient := signalcli.NewClient(cfg.SignalCLIURL, cfg.SignalBotAccount,
+		signalcli.WithLinkPreviews(cfg.SignalLinkPreview),
+		signalcli.WithSelfMessages(cfg.AllowSelfMessages),
+		signalcli.WithDedupWindowSize(cfg.SignalDedupWindow),
+	)
+	chatLimits := make(map[string]memory.Limits, len(cfg.PerChatMemory))
+	for chatID, l := range cfg.PerChatMemory {
+		chatLimits[chatID] = memory.Limits{MaxMessages: l.MaxMessages, MaxMinutes: l.MaxMinutes}
+	}
+	key, err := cfg.EncryptionKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("db_encryption_key: %w", err)
+	}
+	memoryStore, err := memory.NewStore(cfg.DBPath, cfg.MemoryMaxMessages, cfg.MemoryMaxMinutes,
+		memory.WithDriver(cfg.DBDriver), memory.WithChatLimits(chatLimits),
+		memory.WithVacuumInterval(time.Duration(cfg.VacuumIntervalHours)*time.Hour),
+		memory.WithEncryptionKey(key))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	pluginManager, err := plugins.NewManager(cfg.PluginDir, cfg.Debug)
+	var recorder *llm.Recorder
+	if cfg.LLMLogDir != "" || cfg.Debug {
+		recorder, err = llm.NewRecorder(cfg.LLMLogDir, cfg.LLMLogMax)
+		if err != nil {
+			memoryStore.Close()
+			return nil, nil, err
+		}
+	}
+
+	llmClient := newLLMClient(cfg, memoryStore, recorder)
+	if v, ok := llmClient.(interface {
+		ValidateModel(ctx context.Context, name string) error
+	}); ok && cfg.LLMProvider != "azure" {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if err := v.ValidateModel(ctx, cfg.LLMModel); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+		cancel()
+	}
+
+	pluginManager, err := plugins.NewManager(cfg.PluginDir, cfg.Debug,
+		plugins.WithAllowlist(cfg.PluginAllowlist),
+		plugins.WithPerChatPlugins(cfg.PerChatPlugins),
+		plugins.WithRoles(cfg.Roles),
+		plugins.WithRegistry(cfg.PluginRegistryURL, pluginCacheDir(cfg)),
+		plugins.WithStateStore(memoryStore),
+	)
 	if err != nil {
 		memoryStore.Close()
 		return nil, nil, err
 	}
+	pluginManager.RegisterTool("signal_admin", signalcli.NewAdminTool(signalClient))
+	pluginManager.RegisterTool("usage", memory.NewUsageTool(memoryStore, cfg.LLMPriceInputPerMillion, cfg.LLMPriceOutputPerMillion))
+	pluginManager.RegisterTool("timezones", timezone.NewTool())
+	pluginManager.RegisterTool("memory", memory.NewFactsTool(memoryStore))
+	pluginManager.RegisterTool("memory_settings", memory.NewLimitsTool(memoryStore))
+	pluginManager.RegisterTool("plugin_admin", plugins.NewAdminTool(pluginManager))
+	if recorder != nil {
+		pluginManager.RegisterTool("debug", llm.NewDebugTool(recorder))
+	}
 	log.Printf("  Plugins loaded: %d", pluginManager.PluginCount())
 
-	handler := bot.NewHandler(llmClient, pluginManager, memoryStore, cfg.LLMSystemPrompt, cfg.Debug)
+	dispatcher := bot.NewDispatcher(cfg.ChatQueueSize,
+		bot.WithMaxConcurrent(cfg.MaxConcurrentMessages),
+		bot.WithQueueTimeout(time.Duration(cfg.QueueTimeout)*time.Second))
+
+	admin := bot.NewAdmin(signalClient, pluginManager, memoryStore)
+	var router *bot.Router
+	handlerOpts := []bot.Option{
+		bot.WithDispatcher(dispatcher),
+		bot.WithTimeout(time.Duration(cfg.MessageTimeout) * time.Second),
+		bot.WithUsageRecorder(memoryStore),
+		bot.WithMaxParallelTools(cfg.MaxParallelTools),
+		bot.WithMaxToolIterations(cfg.MaxToolIterations),
+		bot.WithMaxToolResult(cfg.ToolResultMaxChars),
+		bot.WithContextBudget(cfg.LLMContextBudget),
+		bot.WithChatContextBudgets(cfg.PerChatContextBudget),
+		bot.WithModelName(cfg.LLMModel),
+		bot.WithSummarySource("Bot activity per day", admin.WeeklyActivity),
+		bot.WithPromptSource("Remembered facts (memory tool)", memoryStore.FactsPrompt),
+	}
+	if cfg.LLMVision {
+		handlerOpts = append(handlerOpts, bot.WithVision(signalClient.DownloadAttachment))
+	}
+	if cfg.LLMStream {
+		handlerOpts = append(handlerOpts, bot.WithStreaming(nil))
+	}
+	if embedder, ok := llmClient.(tron.Embedder); ok && cfg.LLMEmbeddingModel != "" && cfg.RecallTopK > 0 {
+		handlerOpts = append(handlerOpts, bot.WithSemanticRecall(embedder, cfg.RecallTopK, float32(cfg.RecallMinSimilarity), cfg.RecallMaxTokens))
+	}
+	if cfg.RollingSummary {
+		handlerOpts = append(handlerOpts, bot.WithRollingSummary())
+	}
+	if cfg.FallbackResponse != "" {
+		handlerOpts = append(handlerOpts, bot.WithCircuitBreaker(cfg.FallbackResponse))
+	}
+	if cfg.ToolResultSummarize {
+		handlerOpts = append(handlerOpts, bot.WithToolResultSummaries())
+	}
+	if recorder != nil {
+		handlerOpts = append(handlerOpts, bot.WithFullToolOutput(recorder.AddToolOutput))
+	}
+	if cfg.MemoryToolCalls {
+		handlerOpts = append(handlerOpts, bot.WithToolHistory(cfg.MemoryToolResultMaxChars))
+	}
+	handlerOpts = append(handlerOpts, bot.WithStatusInfo(func(chatID string) (string, error) {
+		st, err := memoryStore.Stats()
+		if err != nil {
+			return "", err
+		}
+		return st.Summary(chatID), nil
+	}))
+	if cfg.InterimMessages {
+		handlerOpts = append(handlerOpts, bot.WithInterimMessages(func(ctx context.Context, chatID, text string) {
+			router.SendInterim(ctx, chatID, text)
+		}))
+	}
+
+	handler := bot.NewHandler(llmClient, pluginManager, memoryStore, cfg.LLMSystemPrompt, cfg.Debug, handlerOpts...)
+	if cfg.AuditLog {
+		handler.RegisterMiddleware(bot.AuditLog(memoryStore))
+	}
+	if len(cfg.BlockedWords) > 0 {
+		handler.RegisterMiddleware(bot.FilterWords(cfg.BlockedWords))
+	}
+	if cfg.MaxInputLength > 0 {
+		handler.RegisterMiddleware(bot.TruncateInput(cfg.MaxInputLength))
+	}
+	if cfg.MaxResponseLength > 0 {
+		handler.RegisterMiddleware(bot.CapResponse(cfg.MaxResponseLength))
+	}
+	handler.RegisterCommands(admin.Commands()...)
+	handler.RegisterCommands(pluginManager.Commands()...)
+
+	router = bot.NewRouter(signalClient, handler, dispatcher, cfg.SignalOperator, cfg.TriggerKeyword,
+		bot.WithUsers(cfg.Users),
+		bot.WithAckReaction(cfg.AckReaction),
+		bot.WithMaxMessageLength(cfg.MaxMessageLength),
+		bot.WithSendErrorLog(logSendError))
 
 	a := &app{
-		cfg:          cfg,
-		signalClient: signalClient,
-		handler:      handler,
-		memoryStore:  memoryStore,
+		cfg:           cfg,
+		signalClient:  signalClient,
+		handler:       handler,
+		router:        router,
+		memoryStore:   memoryStore,
+		pluginManager: pluginManager,
+		admin:         admin,
 	}
 
-	sched, err := scheduler.NewScheduler(cfg.DailySummaryHour, handler.GenerateDailySummary, a.sendToOperator)
+	sched, err := scheduler.NewScheduler(cfg.Summaries, a.executeSummary, router.SendToChat,
+		scheduler.WithWeeklySummary(a.executeWeeklySummary),
+		scheduler.WithStateStore(memoryStore))
 	if err != nil {
 		memoryStore.Close()
 		return nil, nil, err
@@ -103,113 +402,46 @@ func newApp(cfg *config.Config) (*app, func(), error) {
 	return a, cleanup, nil
 }
 
-func (a *app) sendToOperator(message string) error {
-	addr := a.operatorAddress
-	if addr == "" {
-		addr = formatRecipient(a.cfg.SignalOperator)
+func (a *app) executeSummary(ctx context.Context, chatID, prompt string) (string, error) {
+	if chatID == "" {
+		chatID = a.router.OperatorChatID()
 	}
-	return a.signalClient.SendMessage(addr, message)
+	return a.handler.ExecuteSummary(ctx, chatID, prompt, 24*time.Hour)
 }
 
-func (a *app) run(ctx context.Context, cancel context.CancelFunc) {
-	messages := a.signalClient.SubscribeMessages(ctx)
+func (a *app) executeWeeklySummary(ctx context.Context, chatID string) (string, error) {
+	if chatID == "" {
+		chatID = a.router.OperatorChatID()
+	}
+	return a.handler.GenerateWeeklySummary(ctx, chatID)
+}
 
+func (a *app) run(ctx context.Context, cancel context.CancelFunc) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("Bot is running. Waiting for messages...")
-
-	for {
+	go func() {
 		select {
 		case <-sigChan:
 			log.Println("Shutting down...")
 			cancel()
-			return
-
-		case msg, ok := <-messages:
-			if !ok {
-				log.Println("Message channel closed")
-				return
-			}
-			a.handleMessage(msg)
+		case <-ctx.Done():
 		}
-	}
-}
-
-func (a *app) handleMessage(msg tron.IncomingMessage) {
-	log.Printf("Message from: source=%s uuid=%s number=%s name=%s group=%v",
-		msg.Source, msg.SourceUUID, msg.SourceNumber, msg.SourceName, msg.IsGroup)
-
-	if !isOperator(msg, a.cfg.SignalOperator) {
-		log.Printf("Ignoring message from non-operator")
-		return
-	}
-
-	userMessage := msg.Message
-	var chatID string
-
-	if msg.IsGroup {
-		if !strings.HasPrefix(userMessage, a.cfg.TriggerKeyword+" ") {
-			log.Printf("Ignoring group message without trigger keyword")
-			return
-		}
-		userMessage = strings.TrimPrefix(userMessage, a.cfg.TriggerKeyword+" ")
-		chatID = "group:" + msg.GroupID
-	} else {
-		if a.operatorAddress == "" {
-			a.operatorAddress = resolveAddress(msg)
-			log.Printf("Operator address set to: %s", a.operatorAddress)
-		}
-		chatID = "dm:" + a.operatorAddress
-	}
-
-	log.Printf("Received message (chat=%s, expires=%ds): %s", chatID, msg.ExpiresInSeconds, userMessage)
-
-	response, err := a.handler.HandleMessage(chatID, userMessage, msg.ExpiresInSeconds)
-	if err != nil {
-		log.Printf("Error handling message: %v", err)
-		response = "Sorry, I encountered an error processing your request."
-	}
+	}()
 
-	if msg.IsGroup {
-		if err := a.signalClient.SendGroupMessage(msg.GroupID, response); err != nil {
-			log.Printf("Error sending group response: %v", err)
-		}
-	} else {
-		if err := a.sendToOperator(response); err != nil {
-			log.Printf("Error sending response: %v", err)
-		}
-	}
-}
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go a.admin.ReloadOnHangup(ctx, hupChan, a.router)
 
-func resolveAddress(msg tron.IncomingMessage) string {
-	if msg.SourceUUID != "" {
-		return msg.SourceUUID
-	}
-	if msg.SourceNumber != "" {
-		return msg.SourceNumber
-	}
-	return msg.Source
-}
-
-func formatRecipient(account string) string {
-	if strings.HasPrefix(account, "+") || strings.HasPrefix(account, "u:") {
-		return account
-	}
-	return "u:" + account
+	log.Println("Bot is running. Waiting for messages...")
+	a.router.Run(ctx)
 }
 
-func isOperator(msg tron.IncomingMessage, operator string) bool {
-	operator = strings.TrimPrefix(operator, "+")
-	operator = strings.TrimPrefix(operator, "u:")
-	operator = strings.ToLower(operator)
-
-	for _, c := range []string{msg.Source, msg.SourceUUID, msg.SourceNumber, msg.SourceName} {
-		c = strings.TrimPrefix(c, "+")
-		c = strings.TrimPrefix(c, "u:")
-		if strings.ToLower(c) == operator {
-			return true
-		}
+func logSendError(prefix string, err error) {
+	var idErr *signalcli.IdentityFailureError
+	if errors.As(err, &idErr) {
+		log.Printf("%s: %v (ask the bot to trust the new safety number for %s)", prefix, err, idErr.Recipient())
+		return
 	}
-	return false
+	log.Printf("%s: %v", prefix, err)
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	signalcli "tron/signal"
+)
+
+func runRegister(args []string) error {
+	fs := flag.NewFlagSet("register", flag.ExitOnError)
+	url := fs.String("url", envOr("SIGNAL_CLI_URL", "http://localhost:8080"), "signal-cli JSON-RPC base URL")
+	link := fs.Bool("link", false, "Link as a secondary device instead of registering a new number")
+	deviceName := fs.String("device-name", "tron", "Device name to use when linking")
+	fs.Parse(args)
+
+	in := bufio.NewReader(os.Stdin)
+
+	if *link {
+		client := signalcli.NewClient(*url, "")
+		uri, err := client.Link(*deviceName)
+		if err != nil {
+			return fmt.Errorf("start link: %w", err)
+		}
+
+		qr, err := renderQR(uri)
+		if err != nil {
+			return fmt.Errorf("render qr code: %w", err)
+		}
+		fmt.Println("Scan this QR code from Signal on your phone (Settings > Linked devices):")
+		fmt.Println(qr)
+		fmt.Println(uri)
+		fmt.Println("Waiting for link confirmation...")
+
+		number, err := client.FinishLink(uri, *deviceName)
+		if err != nil {
+			return fmt.Errorf("finish link: %w", err)
+		}
+		fmt.Printf("Linked %s. Set signal_bot_account to this number.\n", number)
+		return nil
+	}
+
+	number := prompt(in, "Phone number (e.g. +15550001111): ")
+	if number == "" {
+		return fmt.Errorf("phone number is required")
+	}
+	client := signalcli.NewClient(*url, number)
+
+	captcha := prompt(in, "Captcha token (optional, from https://signalcaptchas.org/registration/generate.html): ")
+	if err := client.Register(number, captcha); err != nil {
+		return fmt.Errorf("register: %w", err)
+	}
+
+	code := prompt(in, "Verification code from SMS: ")
+	if err := client.Verify(number, strings.ReplaceAll(code, "-", "")); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	fmt.Printf("Registered %s. Set signal_bot_account to this number.\n", number)
+	return nil
+}
+
+func prompt(in *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func renderQR(content string) (string, error) {
+	qr, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, row := range qr.Bitmap() {
+		for _, dark := range row {
+			if dark {
+				sb.WriteString("  ")
+			} else {
+				sb.WriteString("██")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String(), nil
+}
@@ -1,47 +1,189 @@
 package config
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	SignalCLIURL      string `yaml:"signal_cli_url"`
-	SignalBotAccount  string `yaml:"signal_bot_account"`
-	SignalOperator    string `yaml:"signal_operator"`
-	LLMAPIURL         string `yaml:"llm_api_url"`
-	LLMAPIKey         string `yaml:"llm_api_key"`
-	LLMModel          string `yaml:"llm_model"`
-	LLMSystemPrompt   string `yaml:"llm_system_prompt"`
-	PluginDir         string `yaml:"plugin_dir"`
-	DBPath            string `yaml:"db_path"`
-	TriggerKeyword    string `yaml:"trigger_keyword"`
-	MemoryMaxMessages int    `yaml:"memory_max_messages"`
-	MemoryMaxMinutes  int    `yaml:"memory_max_minutes"`
-	DailySummaryHour  int    `yaml:"daily_summary_hour"`
-	Debug             bool   `yaml:"-"`
+	SignalCLIURL             string                  `yaml:"signal_cli_url"`
+	SignalBotAccount         string                  `yaml:"signal_bot_account"`
+	SignalOperator           string                  `yaml:"signal_operator"`
+	SignalLinkPreview        bool                    `yaml:"signal_link_preview"`
+	AllowSelfMessages        bool                    `yaml:"allow_self_messages"`
+	SignalDedupWindow        int                     `yaml:"signal_dedup_window"`
+	SignalProfileName        string                  `yaml:"signal_profile_name"`
+	SignalProfileAbout       string                  `yaml:"signal_profile_about"`
+	SignalProfileAvatar      string                  `yaml:"signal_profile_avatar"`
+	LLMProvider              string                  `yaml:"llm_provider"`
+	LLMAPIURL                string                  `yaml:"llm_api_url"`
+	LLMAPIKey                string                  `yaml:"llm_api_key"`
+	LLMModel                 string                  `yaml:"llm_model"`
+	LLMEmbeddingModel        string                  `yaml:"llm_embedding_model"`
+	LLMContextBudget         int                     `yaml:"llm_context_budget"`
+	PerChatContextBudget     map[string]int          `yaml:"per_chat_context_budget"`
+	RollingSummary           bool                    `yaml:"rolling_summary"`
+	RecallTopK               int                     `yaml:"recall_top_k"`
+	RecallMinSimilarity      float64                 `yaml:"recall_min_similarity"`
+	RecallMaxTokens          int                     `yaml:"recall_max_tokens"`
+	LLMSystemPrompt          string                  `yaml:"llm_system_prompt"`
+	LLMTemperature           *float64                `yaml:"llm_temperature"`
+	LLMMaxTokens             *int                    `yaml:"llm_max_tokens"`
+	LLMTopP                  *float64                `yaml:"llm_top_p"`
+	LLMStop                  []string                `yaml:"llm_stop"`
+	LLMFrequencyPenalty      *float64                `yaml:"llm_frequency_penalty"`
+	LLMPresencePenalty       *float64                `yaml:"llm_presence_penalty"`
+	LLMStream                bool                    `yaml:"llm_stream"`
+	InterimMessages          bool                    `yaml:"interim_messages"`
+	LLMVision                bool                    `yaml:"llm_vision"`
+	LLMContentArrayMode      bool                    `yaml:"llm_content_array_mode"`
+	LLMKeepAlive             string                  `yaml:"llm_keep_alive"`
+	LLMAzureDeployment       string                  `yaml:"llm_azure_deployment"`
+	LLMAzureAPIVersion       string                  `yaml:"llm_azure_api_version"`
+	LLMExtraHeaders          map[string]string       `yaml:"llm_extra_headers"`
+	LLMOrganization          string                  `yaml:"llm_organization"`
+	LLMMaxRetries            int                     `yaml:"llm_max_retries"`
+	LLMTimeout               int                     `yaml:"llm_timeout_seconds"`
+	LLMLogDir                string                  `yaml:"llm_log_dir"`
+	LLMLogMax                int                     `yaml:"llm_log_max"`
+	LLMPriceInputPerMillion  float64                 `yaml:"llm_price_input_per_million"`
+	LLMPriceOutputPerMillion float64                 `yaml:"llm_price_output_per_million"`
+	MessageTimeout           int                     `yaml:"message_timeout_seconds"`
+	FallbackResponse         string                  `yaml:"fallback_response"`
+	MaxParallelTools         int                     `yaml:"max_parallel_tools"`
+	MaxToolIterations        int                     `yaml:"max_tool_iterations"`
+	ToolResultMaxChars       int                     `yaml:"tool_result_max_chars"`
+	ToolResultSummarize      bool                    `yaml:"tool_result_summarize"`
+	ChatQueueSize            int                     `yaml:"chat_queue_size"`
+	MaxConcurrentMessages    int                     `yaml:"max_concurrent_messages"`
+	QueueTimeout             int                     `yaml:"queue_timeout_seconds"`
+	PluginDir                string                  `yaml:"plugin_dir"`
+	PluginRegistryURL        string                  `yaml:"plugin_registry_url"`
+	PluginCacheDir           string                  `yaml:"plugin_cache_dir"`
+	PluginAllowlist          map[string]string       `yaml:"plugin_allowlist"`
+	PerChatPlugins           map[string][]string     `yaml:"per_chat_plugins"`
+	Users                    map[string]string       `yaml:"users"`
+	Roles                    map[string][]string     `yaml:"roles"`
+	DBPath                   string                  `yaml:"db_path"`
+	DBDriver                 string                  `yaml:"db_driver"`
+	DBEncryptionKey          string                  `yaml:"db_encryption_key"`
+	AutoBackupPath           string                  `yaml:"auto_backup_path"`
+	AutoBackupIntervalHours  int                     `yaml:"auto_backup_interval_hours"`
+	VacuumIntervalHours      int                     `yaml:"vacuum_interval_hours"`
+	TriggerKeyword           string                  `yaml:"trigger_keyword"`
+	AckReaction              string                  `yaml:"ack_reaction"`
+	MaxMessageLength         int                     `yaml:"max_message_length"`
+	MaxInputLength           int                     `yaml:"max_input_length"`
+	MaxResponseLength        int                     `yaml:"max_response_length"`
+	BlockedWords             []string                `yaml:"blocked_words"`
+	AuditLog                 bool                    `yaml:"audit_log"`
+	MemoryMaxMessages        int                     `yaml:"memory_max_messages"`
+	MemoryMaxMinutes         int                     `yaml:"memory_max_minutes"`
+	PerChatMemory            map[string]MemoryLimits `yaml:"per_chat_memory"`
+	MemoryToolCalls          bool                    `yaml:"memory_tool_calls"`
+	MemoryToolResultMaxChars int                     `yaml:"memory_tool_result_max_chars"`
+	DailySummaryHour         int                     `yaml:"daily_summary_hour"`
+	WeeklySummaryDay         int                     `yaml:"weekly_summary_day"`
+	WeeklySummaryHour        int                     `yaml:"weekly_summary_hour"`
+	Summaries                []SummaryConfig         `yaml:"summaries"`
+	APIAddr                  string                  `yaml:"api_addr"`
+	APIToken                 string                  `yaml:"api_token"`
+	Debug                    bool                    `yaml:"-"`
+}
+
+type SummaryConfig struct {
+	Name      string `yaml:"name"`
+	Hour      int    `yaml:"hour"`
+	Minute    int    `yaml:"minute"`
+	Timezone  string `yaml:"timezone"`
+	Recipient string `yaml:"recipient"`
+	Prompt    string `yaml:"prompt"`
+	Weekday   *int   `yaml:"weekday"`
+	Kind      string `yaml:"kind"`
+}
+
+// MemoryLimits overrides memory_max_messages and memory_max_minutes for a
+// chat; zero keeps the global value.
+type MemoryLimits struct {
+	MaxMessages int `yaml:"max_messages"`
+	MaxMinutes  int `yaml:"max_minutes"`
 }
 
 const defaultSystemPrompt = `You are a helpful personal assistant bot on Signal. You can manage tasks and answer general questions.
 
 Be concise - responses go to a mobile chat. Use the available tools to help the user. Never use emojis.`
 
+const (
+	defaultOpenAIURL    = "https://api.deepinfra.com/v1/openai"
+	defaultAnthropicURL = "https://api.anthropic.com/v1"
+	defaultOllamaURL    = "http://localhost:11434/v1"
+)
+
+const defaultAzureAPIVersion = "2024-10-21"
+
+const defaultFallbackResponse = "I can't reach the language model right now. Please try again in a minute."
+
+// minMessageLength leaves room for the "(n/m) " counter on split replies
+// and for more than a few words per part.
+const minMessageLength = 100
+
+const (
+	SummaryKindPrompt = "prompt"
+	SummaryKindWeekly = "weekly"
+)
+
+const defaultSummaryTimezone = "America/Los_Angeles"
+
+const defaultSummaryPrompt = `Give me my daily summary. List my pending tasks and point out anything due soon. Start with a short good morning greeting.`
+
 func Load(configPath string, debug bool) (*Config, error) {
 	cfg := &Config{
-		SignalCLIURL:      "http://localhost:8080",
-		LLMAPIURL:         "https://api.deepinfra.com/v1/openai",
-		LLMModel:          "deepseek-ai/DeepSeek-V3.1",
-		LLMSystemPrompt:   defaultSystemPrompt,
-		PluginDir:         "plugins.d",
-		DBPath:            "tron.db",
-		TriggerKeyword:    "T",
-		MemoryMaxMessages: 50,
-		MemoryMaxMinutes:  60,
-		DailySummaryHour:  7,
-		Debug:             debug,
+		SignalCLIURL:             "http://localhost:8080",
+		LLMProvider:              "openai",
+		LLMAPIURL:                defaultOpenAIURL,
+		LLMModel:                 "deepseek-ai/DeepSeek-V3.1",
+		LLMSystemPrompt:          defaultSystemPrompt,
+		LLMAzureAPIVersion:       defaultAzureAPIVersion,
+		LLMMaxRetries:            3,
+		LLMTimeout:               120,
+		LLMLogMax:                50,
+		MessageTimeout:           180,
+		FallbackResponse:         defaultFallbackResponse,
+		MaxParallelTools:         4,
+		MaxToolIterations:        8,
+		ToolResultMaxChars:       16000,
+		RecallTopK:               3,
+		RecallMinSimilarity:      0.75,
+		RecallMaxTokens:          400,
+		ChatQueueSize:            3,
+		MaxConcurrentMessages:    3,
+		QueueTimeout:             120,
+		PluginDir:                "plugins.d",
+		DBPath:                   "tron.db",
+		DBDriver:                 "sqlite3",
+		AutoBackupIntervalHours:  24,
+		VacuumIntervalHours:      168,
+		TriggerKeyword:           "T",
+		AckReaction:              "👍",
+		MaxMessageLength:         1500,
+		SignalDedupWindow:        100,
+		MemoryMaxMessages:        50,
+		MemoryMaxMinutes:         60,
+		MemoryToolResultMaxChars: 2000,
+		DailySummaryHour:         7,
+		WeeklySummaryDay:         -1,
+		WeeklySummaryHour:        18,
+		Debug:                    debug,
 	}
 
 	if configPath != "" {
@@ -51,26 +193,270 @@ func Load(configPath string, debug bool) (*Config, error) {
 	}
 
 	cfg.applyEnvOverrides()
+	cfg.applySummaryDefaults()
 
-	if cfg.SignalBotAccount == "" {
-		return nil, fmt.Errorf("signal_bot_account is required (set via config file or SIGNAL_BOT_ACCOUNT env var)")
-	}
-	if cfg.SignalOperator == "" {
-		return nil, fmt.Errorf("signal_operator is required (set via config file or SIGNAL_OPERATOR env var)")
+	switch cfg.LLMProvider {
+	case "openai":
+	case "anthropic":
+		if cfg.LLMAPIURL == defaultOpenAIURL {
+			cfg.LLMAPIURL = defaultAnthropicURL
+		}
+	case "ollama":
+		if cfg.LLMAPIURL == defaultOpenAIURL {
+			cfg.LLMAPIURL = defaultOllamaURL
+		}
 	}
-	if cfg.LLMAPIKey == "" {
-		return nil, fmt.Errorf("llm_api_key is required (set via config file or LLM_API_KEY env var)")
+
+	if errs := cfg.Validate(); len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
 	return cfg, nil
 }
 
+func (c *Config) Validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.LLMProvider {
+	case "openai", "anthropic", "ollama":
+	case "azure":
+		if c.LLMAzureDeployment == "" {
+			add("llm_azure_deployment is required when llm_provider is azure")
+		}
+		if c.LLMAPIURL == defaultOpenAIURL {
+			add("llm_api_url must be set to your Azure resource endpoint when llm_provider is azure")
+		}
+	default:
+		add("llm_provider must be one of: openai, anthropic, ollama, azure (got %q)", c.LLMProvider)
+	}
+
+	if c.SignalBotAccount == "" {
+		add("signal_bot_account is required (set via config file or SIGNAL_BOT_ACCOUNT env var)")
+	}
+	if c.SignalOperator == "" {
+		add("signal_operator is required (set via config file or SIGNAL_OPERATOR env var)")
+	}
+	if err := validateURL(c.SignalCLIURL); err != nil {
+		add("signal_cli_url: %v", err)
+	}
+	if c.SignalDedupWindow < 0 {
+		add("signal_dedup_window must not be negative (got %d)", c.SignalDedupWindow)
+	}
+	if err := validateURL(c.LLMAPIURL); err != nil {
+		add("llm_api_url: %v", err)
+	}
+	if c.PluginRegistryURL != "" {
+		if err := validateURL(c.PluginRegistryURL); err != nil {
+			add("plugin_registry_url: %v", err)
+		}
+	}
+
+	if c.LLMAPIKey == "" && c.LLMProvider != "ollama" {
+		add("llm_api_key is required (set via config file or LLM_API_KEY env var)")
+	} else if isPlaceholder(c.LLMAPIKey) {
+		add("llm_api_key is still the example placeholder %q", c.LLMAPIKey)
+	}
+
+	for _, penalty := range []struct {
+		name string
+		p    *float64
+	}{
+		{"llm_frequency_penalty", c.LLMFrequencyPenalty},
+		{"llm_presence_penalty", c.LLMPresencePenalty},
+	} {
+		name, p := penalty.name, penalty.p
+		switch {
+		case p == nil:
+		case c.LLMProvider == "anthropic":
+			add("%s is not supported by the anthropic provider", name)
+		case *p < -2 || *p > 2:
+			add("%s must be between -2 and 2 (got %g)", name, *p)
+		}
+	}
+	if len(c.LLMStop) > 4 && c.LLMProvider != "anthropic" {
+		add("llm_stop accepts at most 4 sequences (got %d)", len(c.LLMStop))
+	}
+
+	if c.MaxToolIterations < 1 || c.MaxToolIterations > 50 {
+		add("max_tool_iterations must be between 1 and 50 (got %d)", c.MaxToolIterations)
+	}
+	if c.LLMContextBudget < 0 {
+		add("llm_context_budget must not be negative (got %d)", c.LLMContextBudget)
+	}
+	for _, chatID := range sortedKeys(c.PerChatContextBudget) {
+		if c.PerChatContextBudget[chatID] < 0 {
+			add("per_chat_context_budget: %s must not be negative", chatID)
+		}
+	}
+	if c.RollingSummary && c.LLMContextBudget == 0 && len(c.PerChatContextBudget) == 0 {
+		add("rolling_summary requires llm_context_budget")
+	}
+	if c.RecallTopK < 0 || c.RecallTopK > 20 {
+		add("recall_top_k must be between 0 and 20 (got %d)", c.RecallTopK)
+	}
+	if c.RecallMinSimilarity < 0 || c.RecallMinSimilarity > 1 {
+		add("recall_min_similarity must be between 0 and 1 (got %g)", c.RecallMinSimilarity)
+	}
+	if c.RecallMaxTokens < 0 {
+		add("recall_max_tokens must not be negative (got %d)", c.RecallMaxTokens)
+	}
+	if c.ToolResultMaxChars < 0 {
+		add("tool_result_max_chars must not be negative (got %d)", c.ToolResultMaxChars)
+	}
+	if c.ToolResultSummarize && c.ToolResultMaxChars == 0 {
+		add("tool_result_summarize requires tool_result_max_chars")
+	}
+
+	if c.ChatQueueSize < 1 || c.ChatQueueSize > 100 {
+		add("chat_queue_size must be between 1 and 100 (got %d)", c.ChatQueueSize)
+	}
+	if c.MaxConcurrentMessages < 0 {
+		add("max_concurrent_messages must not be negative (got %d)", c.MaxConcurrentMessages)
+	}
+	if c.QueueTimeout < 0 {
+		add("queue_timeout_seconds must not be negative (got %d)", c.QueueTimeout)
+	}
+	if c.MaxMessageLength != 0 && c.MaxMessageLength < minMessageLength {
+		add("max_message_length must be 0 or at least %d (got %d)", minMessageLength, c.MaxMessageLength)
+	}
+
+	for _, role := range sortedKeys(c.Roles) {
+		if role == "operator" || role == "ignored" {
+			add("roles: %s is built in and cannot be redefined", role)
+		}
+	}
+	for _, user := range sortedKeys(c.Users) {
+		role := c.Users[user]
+		if _, ok := c.Roles[role]; !ok && role != "operator" && role != "ignored" {
+			add("users: %s has unknown role %q", user, role)
+		}
+	}
+
+	switch c.DBDriver {
+	case "sqlite3":
+	case "postgres":
+		if !postgresSupported {
+			add("db_driver postgres needs a build with -tags postgres")
+		}
+	default:
+		add("db_driver must be sqlite3 or postgres (got %q)", c.DBDriver)
+	}
+	if c.DBEncryptionKey != "" {
+		if _, err := c.EncryptionKey(); err != nil {
+			add("db_encryption_key: %v", err)
+		}
+	}
+	if c.AutoBackupPath != "" {
+		if c.DBDriver != "sqlite3" {
+			add("auto_backup_path is only supported with db_driver sqlite3")
+		}
+		if c.AutoBackupIntervalHours < 1 {
+			add("auto_backup_interval_hours must be at least 1 (got %d)", c.AutoBackupIntervalHours)
+		}
+	}
+
+	if c.VacuumIntervalHours < 0 {
+		add("vacuum_interval_hours must not be negative (got %d)", c.VacuumIntervalHours)
+	}
+
+	if c.MemoryMaxMessages < 1 || c.MemoryMaxMessages > 10000 {
+		add("memory_max_messages must be between 1 and 10000 (got %d)", c.MemoryMaxMessages)
+	}
+	if c.MemoryMaxMinutes < 1 || c.MemoryMaxMinutes > 10080 {
+		add("memory_max_minutes must be between 1 and 10080 (got %d)", c.MemoryMaxMinutes)
+	}
+	for _, chatID := range sortedKeys(c.PerChatMemory) {
+		l := c.PerChatMemory[chatID]
+		if l.MaxMessages < 0 || l.MaxMessages > 10000 {
+			add("per_chat_memory: %s: max_messages must be between 0 and 10000 (got %d)", chatID, l.MaxMessages)
+		}
+		if l.MaxMinutes < 0 || l.MaxMinutes > 10080 {
+			add("per_chat_memory: %s: max_minutes must be between 0 and 10080 (got %d)", chatID, l.MaxMinutes)
+		}
+	}
+	if c.MemoryToolCalls && (c.MemoryToolResultMaxChars < 1 || c.MemoryToolResultMaxChars > 100000) {
+		add("memory_tool_result_max_chars must be between 1 and 100000 (got %d)", c.MemoryToolResultMaxChars)
+	}
+	if c.DailySummaryHour < 0 || c.DailySummaryHour > 23 {
+		add("daily_summary_hour must be between 0 and 23 (got %d)", c.DailySummaryHour)
+	}
+	if c.WeeklySummaryDay < -1 || c.WeeklySummaryDay > 6 {
+		add("weekly_summary_day must be between 0 (Sunday) and 6 (Saturday), or -1 to disable (got %d)", c.WeeklySummaryDay)
+	}
+	if c.WeeklySummaryHour < 0 || c.WeeklySummaryHour > 23 {
+		add("weekly_summary_hour must be between 0 and 23 (got %d)", c.WeeklySummaryHour)
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range c.Summaries {
+		if seen[s.Name] {
+			add("summaries[%s]: name is used more than once", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Hour < 0 || s.Hour > 23 {
+			add("summaries[%s].hour must be between 0 and 23 (got %d)", s.Name, s.Hour)
+		}
+		if s.Minute < 0 || s.Minute > 59 {
+			add("summaries[%s].minute must be between 0 and 59 (got %d)", s.Name, s.Minute)
+		}
+		if s.Weekday != nil && (*s.Weekday < 0 || *s.Weekday > 6) {
+			add("summaries[%s].weekday must be between 0 (Sunday) and 6 (Saturday) (got %d)", s.Name, *s.Weekday)
+		}
+		if s.Kind != SummaryKindPrompt && s.Kind != SummaryKindWeekly {
+			add("summaries[%s].kind must be %q or %q (got %q)", s.Name, SummaryKindPrompt, SummaryKindWeekly, s.Kind)
+		}
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			add("summaries[%s].timezone: unknown timezone %q", s.Name, s.Timezone)
+		}
+	}
+
+	if c.APIAddr != "" && c.APIToken == "" {
+		add("api_token is required when api_addr is set (set via config file or API_TOKEN env var)")
+	}
+
+	return errs
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must be an http or https URL", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+func isPlaceholder(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "your-key-here", "your-api-key-here", "your-api-key", "changeme", "xxx", "<api-key>":
+		return true
+	}
+	return false
+}
+
 func (c *Config) loadFromYAML(path string) error {
-	data, err := os.ReadFile(path)
+	var files []string
+	doc, err := loadYAMLTree(path, make(map[string]bool), &files)
 	if err != nil {
 		return err
 	}
-	return yaml.Unmarshal(data, c)
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	expandEnvFields(reflect.ValueOf(c))
+	return nil
 }
 
 func (c *Config) applyEnvOverrides() {
@@ -83,6 +469,33 @@ func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("SIGNAL_OPERATOR"); v != "" {
 		c.SignalOperator = v
 	}
+	if v := os.Getenv("SIGNAL_LINK_PREVIEW"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.SignalLinkPreview = b
+		}
+	}
+	if v := os.Getenv("ALLOW_SELF_MESSAGES"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.AllowSelfMessages = b
+		}
+	}
+	if v := os.Getenv("SIGNAL_DEDUP_WINDOW"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.SignalDedupWindow = n
+		}
+	}
+	if v := os.Getenv("SIGNAL_PROFILE_NAME"); v != "" {
+		c.SignalProfileName = v
+	}
+	if v := os.Getenv("SIGNAL_PROFILE_ABOUT"); v != "" {
+		c.SignalProfileAbout = v
+	}
+	if v := os.Getenv("SIGNAL_PROFILE_AVATAR"); v != "" {
+		c.SignalProfileAvatar = v
+	}
+	if v := os.Getenv("LLM_PROVIDER"); v != "" {
+		c.LLMProvider = v
+	}
 	if v := os.Getenv("LLM_API_URL"); v != "" {
 		c.LLMAPIURL = v
 	}
@@ -92,18 +505,218 @@ func (c *Config) applyEnvOverrides() {
 	if v := os.Getenv("LLM_MODEL"); v != "" {
 		c.LLMModel = v
 	}
+	if v := os.Getenv("LLM_EMBEDDING_MODEL"); v != "" {
+		c.LLMEmbeddingModel = v
+	}
+	if v := os.Getenv("LLM_CONTEXT_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.LLMContextBudget = n
+		}
+	}
+	if v := os.Getenv("RECALL_TOP_K"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.RecallTopK = n
+		}
+	}
+	if v := os.Getenv("ROLLING_SUMMARY"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.RollingSummary = b
+		}
+	}
 	if v := os.Getenv("LLM_SYSTEM_PROMPT"); v != "" {
 		c.LLMSystemPrompt = v
 	}
+	if v := os.Getenv("LLM_TEMPERATURE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMTemperature = &f
+		}
+	}
+	if v := os.Getenv("LLM_MAX_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.LLMMaxTokens = &n
+		}
+	}
+	if v := os.Getenv("LLM_TOP_P"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMTopP = &f
+		}
+	}
+	if v := os.Getenv("LLM_FREQUENCY_PENALTY"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMFrequencyPenalty = &f
+		}
+	}
+	if v := os.Getenv("LLM_PRESENCE_PENALTY"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMPresencePenalty = &f
+		}
+	}
+	if v := os.Getenv("LLM_KEEP_ALIVE"); v != "" {
+		c.LLMKeepAlive = v
+	}
+	if v := os.Getenv("LLM_AZURE_DEPLOYMENT"); v != "" {
+		c.LLMAzureDeployment = v
+	}
+	if v := os.Getenv("LLM_AZURE_API_VERSION"); v != "" {
+		c.LLMAzureAPIVersion = v
+	}
+	if v := os.Getenv("LLM_ORGANIZATION"); v != "" {
+		c.LLMOrganization = v
+	}
+	if v := os.Getenv("LLM_VISION"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.LLMVision = b
+		}
+	}
+	if v := os.Getenv("LLM_CONTENT_ARRAY_MODE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.LLMContentArrayMode = b
+		}
+	}
+	if v := os.Getenv("LLM_STREAM"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.LLMStream = b
+		}
+	}
+	if v := os.Getenv("INTERIM_MESSAGES"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.InterimMessages = b
+		}
+	}
+	if v := os.Getenv("LLM_MAX_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.LLMMaxRetries = n
+		}
+	}
+	if v := os.Getenv("LLM_TIMEOUT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.LLMTimeout = n
+		}
+	}
+	if v := os.Getenv("LLM_LOG_DIR"); v != "" {
+		c.LLMLogDir = v
+	}
+	if v := os.Getenv("LLM_LOG_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.LLMLogMax = n
+		}
+	}
+	if v := os.Getenv("LLM_PRICE_INPUT_PER_MILLION"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMPriceInputPerMillion = f
+		}
+	}
+	if v := os.Getenv("LLM_PRICE_OUTPUT_PER_MILLION"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			c.LLMPriceOutputPerMillion = f
+		}
+	}
+	if v := os.Getenv("MESSAGE_TIMEOUT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MessageTimeout = n
+		}
+	}
+	if v, ok := os.LookupEnv("FALLBACK_RESPONSE"); ok {
+		c.FallbackResponse = v
+	}
+	if v := os.Getenv("MAX_PARALLEL_TOOLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxParallelTools = n
+		}
+	}
+	if v := os.Getenv("MAX_TOOL_ITERATIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxToolIterations = n
+		}
+	}
+	if v := os.Getenv("TOOL_RESULT_MAX_CHARS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.ToolResultMaxChars = n
+		}
+	}
+	if v := os.Getenv("TOOL_RESULT_SUMMARIZE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.ToolResultSummarize = b
+		}
+	}
+	if v := os.Getenv("CHAT_QUEUE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.ChatQueueSize = n
+		}
+	}
+	if v := os.Getenv("MAX_CONCURRENT_MESSAGES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxConcurrentMessages = n
+		}
+	}
+	if v := os.Getenv("QUEUE_TIMEOUT_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.QueueTimeout = n
+		}
+	}
 	if v := os.Getenv("PLUGIN_DIR"); v != "" {
 		c.PluginDir = v
 	}
+	if v := os.Getenv("PLUGIN_REGISTRY_URL"); v != "" {
+		c.PluginRegistryURL = v
+	}
+	if v := os.Getenv("PLUGIN_CACHE_DIR"); v != "" {
+		c.PluginCacheDir = v
+	}
 	if v := os.Getenv("DB_PATH"); v != "" {
 		c.DBPath = v
 	}
+	if v := os.Getenv("DB_DRIVER"); v != "" {
+		c.DBDriver = v
+	}
+	if v := os.Getenv("DB_ENCRYPTION_KEY"); v != "" {
+		c.DBEncryptionKey = v
+	}
+	if v := os.Getenv("AUTO_BACKUP_PATH"); v != "" {
+		c.AutoBackupPath = v
+	}
+	if v := os.Getenv("AUTO_BACKUP_INTERVAL_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.AutoBackupIntervalHours = n
+		}
+	}
+	if v := os.Getenv("VACUUM_INTERVAL_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.VacuumIntervalHours = n
+		}
+	}
 	if v := os.Getenv("TRIGGER_KEYWORD"); v != "" {
 		c.TriggerKeyword = v
 	}
+	if v, ok := os.LookupEnv("ACK_REACTION"); ok {
+		c.AckReaction = v
+	}
+	if v := os.Getenv("MAX_MESSAGE_LENGTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxMessageLength = n
+		}
+	}
+	if v := os.Getenv("MAX_INPUT_LENGTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxInputLength = n
+		}
+	}
+	if v := os.Getenv("MAX_RESPONSE_LENGTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MaxResponseLength = n
+		}
+	}
+	if v := os.Getenv("AUDIT_LOG"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.AuditLog = b
+		}
+	}
+	if v := os.Getenv("API_ADDR"); v != "" {
+		c.APIAddr = v
+	}
+	if v := os.Getenv("API_TOKEN"); v != "" {
+		c.APIToken = v
+	}
 	if v := os.Getenv("MEMORY_MAX_MESSAGES"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.MemoryMaxMessages = n
@@ -114,9 +727,97 @@ func (c *Config) applyEnvOverrides() {
 			c.MemoryMaxMinutes = n
 		}
 	}
+	if v := os.Getenv("MEMORY_TOOL_CALLS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			c.MemoryToolCalls = b
+		}
+	}
+	if v := os.Getenv("MEMORY_TOOL_RESULT_MAX_CHARS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.MemoryToolResultMaxChars = n
+		}
+	}
 	if v := os.Getenv("DAILY_SUMMARY_HOUR"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil {
 			c.DailySummaryHour = n
 		}
 	}
+	if v := os.Getenv("WEEKLY_SUMMARY_DAY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.WeeklySummaryDay = n
+		}
+	}
+	if v := os.Getenv("WEEKLY_SUMMARY_HOUR"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			c.WeeklySummaryHour = n
+		}
+	}
+}
+
+// EncryptionKey decodes db_encryption_key, a base64-encoded 32-byte key.
+// It returns nil if no key is set.
+func (c *Config) EncryptionKey() ([]byte, error) {
+	if c.DBEncryptionKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.DBEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("must decode to 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+func (c *Config) hasWeeklySummary() bool {
+	for _, s := range c.Summaries {
+		if s.Kind == SummaryKindWeekly {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) applySummaryDefaults() {
+	if len(c.Summaries) == 0 {
+		c.Summaries = []SummaryConfig{{
+			Name: "daily",
+			Hour: c.DailySummaryHour,
+		}}
+	}
+	if c.WeeklySummaryDay >= 0 && !c.hasWeeklySummary() {
+		day := c.WeeklySummaryDay
+		c.Summaries = append(c.Summaries, SummaryConfig{
+			Name:    "weekly",
+			Hour:    c.WeeklySummaryHour,
+			Weekday: &day,
+			Kind:    SummaryKindWeekly,
+		})
+	}
+
+	for i := range c.Summaries {
+		s := &c.Summaries[i]
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("summary-%d", i+1)
+		}
+		if s.Timezone == "" {
+			s.Timezone = defaultSummaryTimezone
+		}
+		if s.Kind == "" {
+			s.Kind = SummaryKindPrompt
+		}
+		if s.Prompt == "" && s.Kind == SummaryKindPrompt {
+			s.Prompt = defaultSummaryPrompt
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateMaxMessageLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    int
+		wantErr bool
+	}{
+		{"default", "", 1500, false},
+		{"disabled", "max_message_length: 0", 0, false},
+		{"minimum", "max_message_length: 100", 100, false},
+		{"too short", "max_message_length: 8", 0, true},
+		{"just below minimum", "max_message_length: 99", 0, true},
+		{"negative", "max_message_length: -1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			yaml := "signal_bot_account: \"+10000000000\"\nsignal_operator: \"+100\"\nllm_api_key: sk-test\n" + tt.yaml + "\n"
+			if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path, false)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "max_message_length") {
+					t.Fatalf("Load() error = %v, want a max_message_length error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxMessageLength != tt.want {
+				t.Errorf("MaxMessageLength = %d, want %d", cfg.MaxMessageLength, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignalDedupWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{"default", "", "", 100, false},
+		{"from file", "signal_dedup_window: 500", "", 500, false},
+		{"disabled", "signal_dedup_window: 0", "", 0, false},
+		{"env overrides file", "signal_dedup_window: 500", "20", 20, false},
+		{"negative", "signal_dedup_window: -1", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SIGNAL_DEDUP_WINDOW", tt.env)
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			yaml := "signal_bot_account: \"+10000000000\"\nsignal_operator: \"+100\"\nllm_api_key: sk-test\n" + tt.yaml + "\n"
+			if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path, false)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "signal_dedup_window") {
+					t.Fatalf("Load() error = %v, want a signal_dedup_window error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.SignalDedupWindow != tt.want {
+				t.Errorf("SignalDedupWindow = %d, want %d", cfg.SignalDedupWindow, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDBDriver(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  string
+		wantErr string
+	}{
+		{"sqlite", "sqlite3", ""},
+		{"unknown", "mysql", "db_driver must be sqlite3 or postgres"},
+		{"postgres", "postgres", ""},
+	}
+	if !postgresSupported {
+		tests[2].wantErr = "-tags postgres"
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			yaml := "signal_bot_account: \"+10000000000\"\nsignal_operator: \"+100\"\nllm_api_key: sk-test\ndb_driver: " + tt.driver + "\n"
+			if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(path, false)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
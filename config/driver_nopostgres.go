@@ -0,0 +1,5 @@
+//go:build !postgres
+
+package config
+
+const postgresSupported = false
@@ -0,0 +1,7 @@
+//go:build postgres
+
+package config
+
+// postgresSupported reports whether this binary includes the PostgreSQL
+// driver.
+const postgresSupported = true
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// expandEnvFields expands $VAR, ${VAR} and ${VAR:-default} in every string
+// reachable from v, including slices, map values and nested structs.
+func expandEnvFields(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			expandEnvFields(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				expandEnvFields(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			expandEnvFields(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			expandEnvFields(elem)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandEnv(v.String()))
+		}
+	}
+}
+
+func expandEnv(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end := matchingBrace(s, i+1)
+			if end < 0 {
+				b.WriteString(s[i:])
+				break
+			}
+			b.WriteString(expandBraced(s[i+2 : end]))
+			i = end
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && isNameChar(s[j], j == i+1) {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte('$')
+			continue
+		}
+		b.WriteString(os.Getenv(s[i+1 : j]))
+		i = j - 1
+	}
+	return b.String()
+}
+
+func expandBraced(expr string) string {
+	name, def, hasDefault := strings.Cut(expr, ":-")
+	if v := os.Getenv(name); v != "" || !hasDefault {
+		return v
+	}
+	return expandEnv(def)
+}
+
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TRON_KEY", "secret")
+	t.Setenv("TRON_HOST", "example.com")
+	t.Setenv("TRON_EMPTY", "")
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${TRON_KEY}", "secret"},
+		{"$TRON_KEY", "secret"},
+		{"key=$TRON_KEY;", "key=secret;"},
+		{"${TRON_UNSET}", ""},
+		{"${TRON_UNSET:-fallback}", "fallback"},
+		{"${TRON_EMPTY:-fallback}", "fallback"},
+		{"${TRON_KEY:-fallback}", "secret"},
+		{"https://${TRON_UNSET:-${TRON_HOST}}/v1", "https://example.com/v1"},
+		{"${TRON_UNSET:-${TRON_ALSO_UNSET:-deep}}", "deep"},
+		{"${TRON_UNSET:-a:-b}", "a:-b"},
+		{"price: 5$", "price: 5$"},
+		{"$1 and $", "$1 and $"},
+		{"${TRON_KEY", "${TRON_KEY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := expandEnv(tt.in); got != tt.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExpandsEnv(t *testing.T) {
+	t.Setenv("TRON_TEST_LLM_KEY", "sk-test")
+	t.Setenv("TRON_TEST_TZ", "Europe/Oslo")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+signal_bot_account: "+10000000000"
+signal_operator: "+100"
+llm_api_key: ${TRON_TEST_LLM_KEY}
+llm_model: ${TRON_TEST_MODEL:-test-model}
+llm_extra_headers:
+  X-Key: $TRON_TEST_LLM_KEY
+llm_stop: ["${TRON_TEST_STOP:-END}"]
+summaries:
+  - name: morning
+    hour: 7
+    timezone: ${TRON_TEST_UNSET_TZ:-${TRON_TEST_TZ}}
+    recipient: "+100"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []interface{}{cfg.LLMAPIKey, cfg.LLMModel, cfg.LLMExtraHeaders["X-Key"], cfg.LLMStop, cfg.Summaries[0].Timezone}
+	want := []interface{}{"sk-test", "test-model", "sk-test", []string{"END"}, "Europe/Oslo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expanded values = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadYAMLTree reads path and resolves its includes. Included files are merged
+// in order, then the including file is merged on top, so later values win.
+// The absolute path of every file it tries to read is appended to files.
+func loadYAMLTree(path string, loading map[string]bool, files *[]string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if loading[abs] {
+		return nil, fmt.Errorf("circular include of %s", abs)
+	}
+	loading[abs] = true
+	defer delete(loading, abs)
+	*files = append(*files, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+
+	includes, err := includePaths(doc["includes"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", abs, err)
+	}
+	delete(doc, "includes")
+
+	merged := make(map[string]interface{})
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(abs), inc)
+		}
+		included, err := loadYAMLTree(inc, loading, files)
+		if err != nil {
+			return nil, err
+		}
+		mergeMaps(merged, included)
+	}
+	mergeMaps(merged, doc)
+
+	return merged, nil
+}
+
+// configFiles returns path and the files it includes, directly or not. Files
+// that fail to load are listed too, so fixing them can trigger a reload.
+func configFiles(path string) []string {
+	var files []string
+	loadYAMLTree(path, make(map[string]bool), &files)
+	return files
+}
+
+func includePaths(v interface{}) ([]string, error) {
+	switch inc := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{inc}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(inc))
+		for _, p := range inc {
+			s, ok := p.(string)
+			if !ok {
+				return nil, fmt.Errorf("includes must be a list of paths")
+			}
+			paths = append(paths, s)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("includes must be a list of paths")
+	}
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
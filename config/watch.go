@@ -0,0 +1,179 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	watchDebounce     = 250 * time.Millisecond
+	watchPollInterval = 2 * time.Second
+)
+
+// Watch calls onChange with the reloaded config whenever the file at path or
+// one of the files it includes changes. Invalid configs are logged and
+// skipped. Without inotify support it falls back to polling the files'
+// modification times.
+func Watch(path string, onChange func(*Config)) (stop func(), err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	files := configFiles(abs)
+	watchedFiles := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return files
+	}
+
+	// watchDirs watches the directory of every file rather than the files
+	// so editors that replace a file via rename keep triggering events.
+	var watcher *fsnotify.Watcher
+	watchDirs := func(files []string) error {
+		for _, f := range files {
+			if err := watcher.Add(filepath.Dir(f)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	reload := func() {
+		updated := configFiles(abs)
+		mu.Lock()
+		files = updated
+		mu.Unlock()
+		if watcher != nil {
+			if err := watchDirs(updated); err != nil {
+				log.Printf("[config] watch error: %v", err)
+			}
+		}
+
+		cfg, err := Load(abs, false)
+		if err != nil {
+			log.Printf("[config] reload of %s failed: %v", abs, err)
+			return
+		}
+		onChange(cfg)
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	stop = func() { once.Do(func() { close(done) }) }
+
+	watcher, err = fsnotify.NewWatcher()
+	if err == nil {
+		err = watchDirs(files)
+		if err != nil {
+			watcher.Close()
+			watcher = nil
+		}
+	}
+	if err != nil {
+		log.Printf("[config] file notifications unavailable (%v), polling every %s", err, watchPollInterval)
+		go pollFiles(watchedFiles, done, reload)
+		return stop, nil
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var timer *time.Timer
+		for {
+			select {
+			case <-done:
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !slices.Contains(watchedFiles(), filepath.Clean(event.Name)) || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, reload)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[config] watch error: %v", err)
+			}
+		}
+	}()
+
+	return stop, nil
+}
+
+// pollFiles calls reload when the modification time of any of the files
+// changes, or one of them appears or disappears. Files newly listed by files
+// were just loaded and only start being compared on the next tick.
+func pollFiles(files func() []string, done <-chan struct{}, reload func()) {
+	modTimes := make(map[string]time.Time)
+	for _, f := range files() {
+		modTimes[f] = modTime(f)
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			changed := false
+			for _, f := range files() {
+				t := modTime(f)
+				last, ok := modTimes[f]
+				modTimes[f] = t
+				if ok && !t.Equal(last) {
+					changed = true
+				}
+			}
+			if changed {
+				reload()
+			}
+		}
+	}
+}
+
+// modTime returns the modification time of path, or the zero time if it
+// cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Diff returns the YAML names of the fields that differ between old and new.
+func Diff(old, new *Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("yaml")
+		if name == "" || name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const watchTestBase = "signal_bot_account: \"+10000000000\"\nsignal_operator: \"+100\"\nllm_api_key: sk-test\n"
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	writeFile(t, main, "includes: [conf.d/llm.yaml, missing.yaml]\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "llm.yaml"), "includes: secrets.yaml\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "secrets.yaml"), "llm_api_key: sk-test\n")
+
+	got := configFiles(main)
+	want := []string{
+		main,
+		filepath.Join(dir, "conf.d", "llm.yaml"),
+		filepath.Join(dir, "conf.d", "secrets.yaml"),
+		filepath.Join(dir, "missing.yaml"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configFiles() = %q, want %q", got, want)
+	}
+}
+
+func TestWatchIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    string
+		content string
+	}{
+		{"top-level file", "config.yaml", watchTestBase + "includes: conf.d/behavior.yaml\ntrigger_keyword: new\n"},
+		{"included file", "conf.d/behavior.yaml", "includes: trigger.yaml\ntrigger_keyword: new\n"},
+		{"nested include", "conf.d/trigger.yaml", "trigger_keyword: new\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			main := filepath.Join(dir, "config.yaml")
+			writeFile(t, main, watchTestBase+"includes: conf.d/behavior.yaml\n")
+			writeFile(t, filepath.Join(dir, "conf.d", "behavior.yaml"), "includes: trigger.yaml\n")
+			writeFile(t, filepath.Join(dir, "conf.d", "trigger.yaml"), "trigger_keyword: old\n")
+
+			changes := make(chan *Config, 10)
+			stop, err := Watch(main, func(cfg *Config) { changes <- cfg })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stop()
+
+			writeFile(t, filepath.Join(dir, tt.edit), tt.content)
+			waitForTrigger(t, changes, "new")
+		})
+	}
+}
+
+func TestWatchNewInclude(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "config.yaml")
+	writeFile(t, main, watchTestBase)
+
+	changes := make(chan *Config, 10)
+	stop, err := Watch(main, func(cfg *Config) { changes <- cfg })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	extra := filepath.Join(dir, "extra", "trigger.yaml")
+	writeFile(t, extra, "trigger_keyword: first\n")
+	writeFile(t, main, watchTestBase+"includes: extra/trigger.yaml\n")
+	waitForTrigger(t, changes, "first")
+
+	writeFile(t, extra, "trigger_keyword: second\n")
+	waitForTrigger(t, changes, "second")
+}
+
+func waitForTrigger(t *testing.T, changes <-chan *Config, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case cfg := <-changes:
+			if cfg.TriggerKeyword == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no reload with trigger_keyword %q", want)
+		}
+	}
+}
@@ -3,7 +3,14 @@ module tron
 go 1.25.3
 
 require (
-	github.com/mattn/go-sqlite3 v1.14.33 // indirect
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/lib/pq v1.12.3
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/robfig/cron/v3 v3.0.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,301 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"tron"
+)
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 1024
+)
+
+type AnthropicClient struct {
+	base *Client
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature *float64             `json:"temperature,omitempty"`
+	TopP        *float64             `json:"top_p,omitempty"`
+	StopSeqs    []string             `json:"stop_sequences,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string           `json:"type"`
+	Text      string           `json:"text,omitempty"`
+	ID        string           `json:"id,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Input     json.RawMessage  `json:"input,omitempty"`
+	ToolUseID string           `json:"tool_use_id,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	Source    *anthropicSource `json:"source,omitempty"`
+}
+
+type anthropicSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func NewAnthropicClient(apiURL, apiKey, model string, opts ...Option) *AnthropicClient {
+	return &AnthropicClient{base: NewClient(apiURL, apiKey, model, opts...)}
+}
+
+func (c *AnthropicClient) Chat(ctx context.Context, messages []tron.Message, tools []tron.Tool) (*tron.LLMResponse, error) {
+	return c.ChatWithOptions(ctx, messages, tools, tron.ChatOptions{})
+}
+
+func (c *AnthropicClient) Models(ctx context.Context) ([]string, error) {
+	return c.base.listModels(ctx, c.base.apiURL+"/models?limit=1000", c.setHeaders)
+}
+
+func (c *AnthropicClient) ValidateModel(ctx context.Context, name string) error {
+	return validateModel(ctx, c, name)
+}
+
+func (c *AnthropicClient) setHeaders(req *http.Request) {
+	c.base.setExtraHeaders(req)
+	req.Header.Set("x-api-key", c.base.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// ChatWithOptions ignores ResponseFormat and the penalties; the Messages API
+// has neither, so callers that need structured output must ask for it in the
+// prompt.
+func (c *AnthropicClient) ChatWithOptions(ctx context.Context, messages []tron.Message, tools []tron.Tool, opts tron.ChatOptions) (*tron.LLMResponse, error) {
+	req, err := buildAnthropicRequest(c.base.model, messages, tools, mergeOptions(c.base.options, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := c.base.do(ctx, func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.base.apiURL+"/messages", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		c.setHeaders(httpReq)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msgResp anthropicResponse
+	if err := decodeResponse(resp, &msgResp); err != nil {
+		return nil, err
+	}
+
+	if msgResp.Error != nil {
+		return nil, fmt.Errorf("api error: %s", msgResp.Error.Message)
+	}
+
+	return parseAnthropicResponse(msgResp)
+}
+
+func buildAnthropicRequest(model string, messages []tron.Message, tools []tron.Tool, opts tron.ChatOptions) (*anthropicRequest, error) {
+	req := &anthropicRequest{
+		Model:       model,
+		MaxTokens:   anthropicDefaultMaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		StopSeqs:    opts.Stop,
+	}
+	if opts.MaxTokens != nil {
+		req.MaxTokens = *opts.MaxTokens
+	}
+
+	var system []string
+	for _, m := range messages {
+		var (
+			role   string
+			blocks []anthropicBlock
+		)
+
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+			continue
+
+		case "user":
+			role = "user"
+			if len(m.Parts) == 0 {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, p := range m.Parts {
+				switch {
+				case p.Type == "text":
+					blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+				case p.Type == "image_url" && p.ImageURL != nil:
+					if src, ok := parseDataURL(p.ImageURL.URL); ok {
+						blocks = append(blocks, anthropicBlock{Type: "image", Source: src})
+					}
+				}
+			}
+
+		case "assistant":
+			role = "assistant"
+			if m.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if strings.TrimSpace(tc.Function.Arguments) == "" {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return nil, fmt.Errorf("tool call %s has invalid arguments", tc.ID)
+				}
+				blocks = append(blocks, anthropicBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Function.Name,
+					Input: input,
+				})
+			}
+
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   m.Content,
+			})
+
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", m.Role)
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	for _, t := range tools {
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		req.Tools = append(req.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	if tc := opts.ToolChoice; tc != nil && len(req.Tools) > 0 {
+		switch {
+		case tc.Function != "":
+			req.ToolChoice = &anthropicToolChoice{Type: "tool", Name: tc.Function}
+		case tc.Mode == "required":
+			req.ToolChoice = &anthropicToolChoice{Type: "any"}
+		case tc.Mode == "auto", tc.Mode == "none":
+			req.ToolChoice = &anthropicToolChoice{Type: tc.Mode}
+		}
+	}
+
+	return req, nil
+}
+
+func parseDataURL(u string) (*anthropicSource, bool) {
+	rest, ok := strings.CutPrefix(u, "data:")
+	if !ok {
+		return nil, false
+	}
+	mediaType, data, ok := strings.Cut(rest, ";base64,")
+	if !ok {
+		return nil, false
+	}
+	return &anthropicSource{Type: "base64", MediaType: mediaType, Data: data}, true
+}
+
+func parseAnthropicResponse(resp anthropicResponse) (*tron.LLMResponse, error) {
+	result := &tron.LLMResponse{
+		Usage: &tron.Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+		},
+	}
+
+	var text []string
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text = append(text, block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			result.ToolCalls = append(result.ToolCalls, tron.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: tron.ToolCallFunction{
+					Name:      block.Name,
+					Arguments: args,
+				},
+			})
+		}
+	}
+	result.Content = strings.Join(text, "")
+
+	if result.Content == "" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("empty response (stop reason: %s)", resp.StopReason)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"tron"
+)
+
+func TestBuildAnthropicRequest(t *testing.T) {
+	messages := []tron.Message{
+		{Role: "system", Content: "You are a bot."},
+		{Role: "system", Content: "Current time: noon"},
+		{Role: "user", Content: "Tasks and weather?"},
+		{Role: "assistant", Content: "Let me check.", ToolCalls: []tron.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: tron.ToolCallFunction{Name: "task", Arguments: `{"action":"list"}`}},
+			{ID: "toolu_2", Type: "function", Function: tron.ToolCallFunction{Name: "weather", Arguments: ""}},
+		}},
+		{Role: "tool", ToolCallID: "toolu_1", Content: "1. Buy milk"},
+		{Role: "tool", ToolCallID: "toolu_2", Content: "sunny"},
+		{Role: "assistant", Content: "Buy milk; it is sunny."},
+		{Role: "user", Content: "Thanks"},
+	}
+	tools := []tron.Tool{{Type: "function", Function: tron.ToolFunction{
+		Name:        "task",
+		Description: "Manage tasks",
+		Parameters:  map[string]interface{}{"type": "object", "properties": map[string]interface{}{"action": map[string]interface{}{"type": "string"}}},
+	}}, {Type: "function", Function: tron.ToolFunction{Name: "weather"}}}
+
+	req, err := buildAnthropicRequest("claude", messages, tools, tron.ChatOptions{ToolChoice: tron.ToolChoiceRequired})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.System != "You are a bot.\n\nCurrent time: noon" {
+		t.Errorf("system = %q", req.System)
+	}
+	want := []anthropicMessage{
+		{Role: "user", Content: []anthropicBlock{{Type: "text", Text: "Tasks and weather?"}}},
+		{Role: "assistant", Content: []anthropicBlock{
+			{Type: "text", Text: "Let me check."},
+			{Type: "tool_use", ID: "toolu_1", Name: "task", Input: json.RawMessage(`{"action":"list"}`)},
+			{Type: "tool_use", ID: "toolu_2", Name: "weather", Input: json.RawMessage(`{}`)},
+		}},
+		// Both results go back in one user turn, as the API requires.
+		{Role: "user", Content: []anthropicBlock{
+			{Type: "tool_result", ToolUseID: "toolu_1", Content: "1. Buy milk"},
+			{Type: "tool_result", ToolUseID: "toolu_2", Content: "sunny"},
+		}},
+		{Role: "assistant", Content: []anthropicBlock{{Type: "text", Text: "Buy milk; it is sunny."}}},
+		{Role: "user", Content: []anthropicBlock{{Type: "text", Text: "Thanks"}}},
+	}
+	if !reflect.DeepEqual(req.Messages, want) {
+		got, _ := json.MarshalIndent(req.Messages, "", "  ")
+		t.Errorf("messages =\n%s", got)
+	}
+
+	if len(req.Tools) != 2 || req.Tools[0].InputSchema["type"] != "object" || req.Tools[1].InputSchema["type"] != "object" {
+		t.Errorf("tools = %+v", req.Tools)
+	}
+	if req.ToolChoice == nil || req.ToolChoice.Type != "any" {
+		t.Errorf("tool_choice = %+v, want any", req.ToolChoice)
+	}
+}
+
+func TestBuildAnthropicRequestInvalidArguments(t *testing.T) {
+	messages := []tron.Message{{Role: "assistant", ToolCalls: []tron.ToolCall{
+		{ID: "toolu_1", Function: tron.ToolCallFunction{Name: "task", Arguments: `{"action":`}},
+	}}}
+	if _, err := buildAnthropicRequest("claude", messages, nil, tron.ChatOptions{}); err == nil {
+		t.Error("expected an error for invalid tool arguments")
+	}
+}
+
+func TestParseAnthropicResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		resp      string
+		content   string
+		toolCalls []tron.ToolCall
+		wantErr   bool
+	}{{
+		name:    "text",
+		resp:    `{"content":[{"type":"text","text":"Hello"}],"stop_reason":"end_turn"}`,
+		content: "Hello",
+	}, {
+		name:    "text and tool use",
+		resp:    `{"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"toolu_1","name":"task","input":{"action":"list"}}],"stop_reason":"tool_use"}`,
+		content: "Checking.",
+		toolCalls: []tron.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: tron.ToolCallFunction{Name: "task", Arguments: `{"action":"list"}`}},
+		},
+	}, {
+		name: "tool use without input",
+		resp: `{"content":[{"type":"tool_use","id":"toolu_1","name":"weather"}],"stop_reason":"tool_use"}`,
+		toolCalls: []tron.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: tron.ToolCallFunction{Name: "weather", Arguments: "{}"}},
+		},
+	}, {
+		name:    "empty",
+		resp:    `{"content":[],"stop_reason":"max_tokens"}`,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp anthropicResponse
+			if err := json.Unmarshal([]byte(tt.resp), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseAnthropicResponse(resp)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Content != tt.content || !reflect.DeepEqual(got.ToolCalls, tt.toolCalls) {
+				t.Errorf("got %q %+v, want %q %+v", got.Content, got.ToolCalls, tt.content, tt.toolCalls)
+			}
+		})
+	}
+}
+
+func TestAnthropicToolRoundTrip(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []anthropicRequest
+	)
+	responses := []string{
+		`{"content":[{"type":"tool_use","id":"toolu_1","name":"task","input":{"action":"list"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"content":[{"type":"text","text":"You need to buy milk."}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":6}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			http.Error(w, "bad request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		n := len(requests)
+		requests = append(requests, req)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[n]))
+	}))
+	defer srv.Close()
+
+	c := NewAnthropicClient(srv.URL, "key", "claude")
+	tools := []tron.Tool{{Type: "function", Function: tron.ToolFunction{Name: "task"}}}
+	messages := []tron.Message{
+		{Role: "system", Content: "You are a bot."},
+		{Role: "user", Content: "What are my tasks?"},
+	}
+
+	first, err := c.Chat(context.Background(), messages, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.ToolCalls) != 1 || first.ToolCalls[0].ID != "toolu_1" || first.Usage.PromptTokens != 10 {
+		t.Fatalf("first response = %+v", first)
+	}
+
+	messages = append(messages,
+		tron.Message{Role: "assistant", Content: first.Content, ToolCalls: first.ToolCalls},
+		tron.Message{Role: "tool", ToolCallID: first.ToolCalls[0].ID, Content: "1. Buy milk"},
+	)
+	second, err := c.Chat(context.Background(), messages, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Content != "You need to buy milk." {
+		t.Errorf("second response = %+v", second)
+	}
+
+	sent := requests[1].Messages
+	if len(sent) != 3 {
+		t.Fatalf("second request has %d messages, want 3", len(sent))
+	}
+	use, result := sent[1].Content[0], sent[2].Content[0]
+	if use.Type != "tool_use" || use.ID != "toolu_1" || string(use.Input) != `{"action":"list"}` {
+		t.Errorf("tool_use block = %+v", use)
+	}
+	if sent[2].Role != "user" || result.Type != "tool_result" || result.ToolUseID != "toolu_1" || result.Content != "1. Buy milk" {
+		t.Errorf("tool_result message = %+v", sent[2])
+	}
+}
@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// WithAzure switches the client to Azure OpenAI: requests go to
+// /openai/deployments/{deployment}/... with an api-version query parameter
+// and authenticate with the api-key header. Embeddings use the deployment
+// named by the embedding model.
+func WithAzure(deployment, apiVersion string) Option {
+	return func(c *Client) {
+		c.azureDeployment = deployment
+		c.azureAPIVersion = apiVersion
+	}
+}
+
+func (c *Client) endpoint(deployment, path string) string {
+	if c.azureDeployment == "" {
+		return c.apiURL + path
+	}
+	return c.apiURL + "/openai/deployments/" + url.PathEscape(deployment) + path +
+		"?api-version=" + url.QueryEscape(c.azureAPIVersion)
+}
+
+func (c *Client) setAuth(req *http.Request) {
+	c.setExtraHeaders(req)
+	if c.organization != "" {
+		req.Header.Set("OpenAI-Organization", c.organization)
+	}
+	if c.azureDeployment != "" {
+		req.Header.Set("api-key", c.apiKey)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+}
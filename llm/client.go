@@ -2,10 +2,12 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"tron"
 )
@@ -15,67 +17,180 @@ type Client struct {
 	apiKey     string
 	model      string
 	httpClient *http.Client
+	maxRetries int
+	options    tron.ChatOptions
+
+	ollamaCompat bool
+	keepAlive    string
+
+	embeddingModel string
+	usage          tron.UsageRecorder
+	recorder       *Recorder
+
+	azureDeployment string
+	azureAPIVersion string
+
+	extraHeaders map[string]string
+	organization string
+
+	contentArrays bool
+}
+
+type Option func(*Client)
+
+func WithChatOptions(opts tron.ChatOptions) Option {
+	return func(c *Client) {
+		c.options = opts
+	}
+}
+
+func WithOllamaCompat(keepAlive string) Option {
+	return func(c *Client) {
+		c.ollamaCompat = true
+		c.keepAlive = keepAlive
+	}
+}
+
+// WithHTTPClient sends requests with client, e.g. one with a proxy or a
+// test transport. A later WithTimeout sets the timeout on a copy of it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Timeout = d
+		c.httpClient = &hc
+	}
+}
+
+func WithExtraHeaders(headers map[string]string) Option {
+	return func(c *Client) {
+		c.extraHeaders = headers
+	}
+}
+
+func WithOrganization(org string) Option {
+	return func(c *Client) {
+		c.organization = org
+	}
+}
+
+// WithContentArrays sends message content in the content-array format,
+// [{"type": "text", "text": ...}], instead of as a plain string. Some
+// OpenAI-compatible servers only accept one of the two.
+func WithContentArrays() Option {
+	return func(c *Client) {
+		c.contentArrays = true
+	}
+}
+
+// requestMessages returns messages as they are sent: with WithContentArrays,
+// plain content becomes a single text part.
+func (c *Client) requestMessages(messages []tron.Message) []tron.Message {
+	if !c.contentArrays {
+		return messages
+	}
+	out := make([]tron.Message, len(messages))
+	for i, m := range messages {
+		if len(m.Parts) == 0 && m.Content != "" {
+			m.Parts = []tron.ContentPart{{Type: "text", Text: m.Content}}
+		}
+		out[i] = m
+	}
+	return out
+}
+
+func (c *Client) setExtraHeaders(req *http.Request) {
+	for name, value := range c.extraHeaders {
+		req.Header.Set(name, value)
+	}
+}
+
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
 }
 
 type chatRequest struct {
-	Model    string         `json:"model"`
-	Messages []tron.Message `json:"messages"`
-	Tools    []tron.Tool    `json:"tools,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []tron.Message `json:"messages"`
+	Tools         []tron.Tool    `json:"tools,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	KeepAlive     string         `json:"keep_alive,omitempty"`
+	tron.ChatOptions
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type chatResponse struct {
 	Choices []struct {
 		Message struct {
-			Role      string          `json:"role"`
-			Content   string          `json:"content"`
-			ToolCalls []tron.ToolCall `json:"tool_calls,omitempty"`
+			Role             string          `json:"role"`
+			Content          string          `json:"content"`
+			ReasoningContent string          `json:"reasoning_content,omitempty"`
+			ToolCalls        []tron.ToolCall `json:"tool_calls,omitempty"`
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *tron.Usage `json:"usage,omitempty"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
-func NewClient(apiURL, apiKey, model string) *Client {
-	return &Client{
+func NewClient(apiURL, apiKey, model string, opts ...Option) *Client {
+	c := &Client{
 		apiURL:     strings.TrimSuffix(apiURL, "/"),
 		apiKey:     apiKey,
 		model:      model,
-		httpClient: &http.Client{},
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func (c *Client) Chat(messages []tron.Message, tools []tron.Tool) (*tron.LLMResponse, error) {
+func (c *Client) Chat(ctx context.Context, messages []tron.Message, tools []tron.Tool) (*tron.LLMResponse, error) {
+	return c.ChatWithOptions(ctx, messages, tools, tron.ChatOptions{})
+}
+
+func (c *Client) ChatWithOptions(ctx context.Context, messages []tron.Message, tools []tron.Tool, opts tron.ChatOptions) (*tron.LLMResponse, error) {
 	req := chatRequest{
-		Model:    c.model,
-		Messages: messages,
+		Model:       c.model,
+		Messages:    c.requestMessages(messages),
+		ChatOptions: mergeOptions(c.options, opts),
+		KeepAlive:   c.keepAlive,
+	}
+	// With tool_choice "none" the tools are left out entirely: it saves
+	// prompt tokens and not every compatible server honors "none".
+	if tc := req.ToolChoice; tc != nil && tc.Function == "" && tc.Mode == "none" {
+		tools = nil
 	}
 	if len(tools) > 0 {
 		req.Tools = tools
+	} else {
+		req.ToolChoice = nil
 	}
 
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequest("POST", c.apiURL+"/chat/completions", bytes.NewReader(body))
+	resp, err := c.post(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	var chatResp chatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	if err := decodeResponse(resp, &chatResp); err != nil {
+		return nil, err
 	}
 
 	if chatResp.Error != nil {
@@ -87,8 +202,63 @@ func (c *Client) Chat(messages []tron.Message, tools []tron.Tool) (*tron.LLMResp
 	}
 
 	choice := chatResp.Choices[0]
-	return &tron.LLMResponse{
+	result := &tron.LLMResponse{
 		Content:   choice.Message.Content,
 		ToolCalls: choice.Message.ToolCalls,
-	}, nil
+		Usage:     chatResp.Usage,
+	}
+	if err := applyReasoning(result, choice.Message.ReasoningContent); err != nil {
+		return nil, err
+	}
+	c.normalizeResponse(result, tools)
+
+	return result, nil
+}
+
+func mergeOptions(base, override tron.ChatOptions) tron.ChatOptions {
+	if override.Temperature != nil {
+		base.Temperature = override.Temperature
+	}
+	if override.MaxTokens != nil {
+		base.MaxTokens = override.MaxTokens
+	}
+	if override.TopP != nil {
+		base.TopP = override.TopP
+	}
+	if override.Stop != nil {
+		base.Stop = override.Stop
+	}
+	if override.FrequencyPenalty != nil {
+		base.FrequencyPenalty = override.FrequencyPenalty
+	}
+	if override.PresencePenalty != nil {
+		base.PresencePenalty = override.PresencePenalty
+	}
+	if override.ResponseFormat != nil {
+		base.ResponseFormat = override.ResponseFormat
+	}
+	if override.ToolChoice != nil {
+		base.ToolChoice = override.ToolChoice
+	}
+	return base
+}
+
+func (c *Client) post(ctx context.Context, req chatRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	return c.do(ctx, func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(c.azureDeployment, "/chat/completions"), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		c.setAuth(httpReq)
+		if req.Stream {
+			httpReq.Header.Set("Accept", "text/event-stream")
+		}
+		return httpReq, nil
+	})
 }
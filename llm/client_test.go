@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tron"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestChatResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  []int
+		body    []string
+		want    string
+		wantErr string
+	}{{
+		name:   "ok",
+		status: []int{200},
+		body:   []string{`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`},
+		want:   "Hi",
+	}, {
+		name:   "retried server error",
+		status: []int{503, 200},
+		body:   []string{`overloaded`, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`},
+		want:   "Hi",
+	}, {
+		name:    "client error",
+		status:  []int{401},
+		body:    []string{`{"error":{"message":"bad key"}}`},
+		wantErr: "status 401",
+	}, {
+		name:    "error in body",
+		status:  []int{200},
+		body:    []string{`{"error":{"message":"model not found"}}`},
+		wantErr: "model not found",
+	}, {
+		name:    "no choices",
+		status:  []int{200},
+		body:    []string{`{"choices":[]}`},
+		wantErr: "no choices",
+	}, {
+		name:    "not JSON",
+		status:  []int{200},
+		body:    []string{`<html>proxy login</html>`},
+		wantErr: "decode response",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(n.Add(1)) - 1
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status[i])
+				fmt.Fprint(w, tt.body[i])
+			}))
+			defer srv.Close()
+
+			resp, err := NewClient(srv.URL, "key", "model").Chat(context.Background(), []tron.Message{{Role: "user", Content: "hi"}}, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("content = %q, want %q", resp.Content, tt.want)
+			}
+			if int(n.Load()) != len(tt.status) {
+				t.Errorf("server got %d requests, want %d", n.Load(), len(tt.status))
+			}
+		})
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":%q}}]}`, req.Model+" via "+r.Header.Get("X-Test-Transport"))
+	}))
+	defer srv.Close()
+
+	var used atomic.Bool
+	hc := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		used.Store(true)
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			return nil, fmt.Errorf("authorization = %q", got)
+		}
+		r.Header.Set("X-Test-Transport", "custom")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	c := NewClient(srv.URL, "key", "model", WithHTTPClient(hc))
+	resp, err := c.Chat(context.Background(), []tron.Message{{Role: "user", Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !used.Load() || resp.Content != "model via custom" {
+		t.Errorf("request did not go through the injected client: %q", resp.Content)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	hc := &http.Client{}
+	c := NewClient(srv.URL, "key", "model", WithHTTPClient(hc), WithTimeout(50*time.Millisecond), WithMaxRetries(0))
+
+	start := time.Now()
+	if _, err := c.Chat(context.Background(), []tron.Message{{Role: "user", Content: "hi"}}, nil); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("request took %s, want the 50ms timeout", elapsed)
+	}
+	if hc.Timeout != 0 {
+		t.Errorf("WithTimeout changed the injected client: timeout %s", hc.Timeout)
+	}
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"tron"
+)
+
+const maxDebugFieldChars = 3000
+
+type DebugTool struct {
+	recorder *Recorder
+
+	mu     sync.Mutex
+	chatID string
+}
+
+type debugArgs struct {
+	Back       *int `json:"back"`
+	ToolOutput bool `json:"tool_output"`
+	Offset     int  `json:"offset"`
+}
+
+func NewDebugTool(recorder *Recorder) *DebugTool {
+	return &DebugTool{recorder: recorder}
+}
+
+func (t *DebugTool) SetContext(chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chatID = chatID
+}
+
+func (t *DebugTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name: "debug",
+			Description: "Show a raw LLM API request and response recorded for this chat. " +
+				"Use when asked what was actually sent to or returned by the model, e.g. to diagnose malformed tool calls. " +
+				"With tool_output, show the full output of a tool result that was truncated or condensed instead.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"back": map[string]interface{}{
+						"type":        "integer",
+						"description": "How many exchanges to go back. 0 is the request that produced this tool call; default 1 is the one before it",
+					},
+					"tool_output": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Show a truncated tool output instead of an LLM exchange. back counts tool outputs here, default 0 is the latest",
+					},
+					"offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "With tool_output, the character to start from, to page through long output",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (t *DebugTool) Execute(argsJSON string) (string, error) {
+	var args debugArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("parse args: %w", err)
+		}
+	}
+	t.mu.Lock()
+	chatID := t.chatID
+	t.mu.Unlock()
+
+	if args.ToolOutput {
+		back := 0
+		if args.Back != nil && *args.Back >= 0 {
+			back = *args.Back
+		}
+		return t.toolOutput(chatID, back, args.Offset), nil
+	}
+
+	back := 1
+	if args.Back != nil && *args.Back >= 0 {
+		back = *args.Back
+	}
+
+	ex, ok := t.recorder.Recent(chatID, back)
+	if !ok {
+		return "No recorded exchange found for this chat.", nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Time: %s\nURL: %s\n", ex.Time.Format("2006-01-02 15:04:05"), ex.URL)
+	if ex.Status != 0 {
+		fmt.Fprintf(&sb, "Status: %d\n", ex.Status)
+	}
+	if ex.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n", ex.Error)
+	}
+	fmt.Fprintf(&sb, "\nRequest:\n%s\n\nResponse:\n%s", clip(string(ex.Request)), clip(ex.Response))
+
+	return sb.String(), nil
+}
+
+func (t *DebugTool) toolOutput(chatID string, back, offset int) string {
+	out, ok := t.recorder.RecentToolOutput(chatID, back)
+	if !ok {
+		return "No truncated tool output found for this chat."
+	}
+
+	runes := []rune(out.Output)
+	if offset < 0 || offset >= len(runes) {
+		return fmt.Sprintf("Offset %d is outside the output of %s (%d characters).", offset, out.Tool, len(runes))
+	}
+	end := min(offset+maxDebugFieldChars, len(runes))
+
+	return fmt.Sprintf("Tool: %s\nTime: %s\nCharacters %d-%d of %d:\n\n%s",
+		out.Tool, out.Time.Format("2006-01-02 15:04:05"), offset, end, len(runes), string(runes[offset:end]))
+}
+
+func clip(s string) string {
+	if len(s) <= maxDebugFieldChars {
+		return s
+	}
+	return "..." + s[len(s)-maxDebugFieldChars:]
+}
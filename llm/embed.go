@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"tron"
+)
+
+const (
+	embeddingBatchSize    = 64
+	embeddingsUsageChatID = "embeddings"
+)
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *tron.Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func WithEmbeddingModel(model string) Option {
+	return func(c *Client) {
+		c.embeddingModel = model
+	}
+}
+
+// WithUsageRecorder records token usage of calls that are not tied to a chat,
+// such as embeddings.
+func WithUsageRecorder(r tron.UsageRecorder) Option {
+	return func(c *Client) {
+		c.usage = r
+	}
+}
+
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.embeddingModel == "" {
+		return nil, fmt.Errorf("no embedding model configured")
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		batch, err := c.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	return vectors, nil
+}
+
+func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: c.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(c.embeddingModel, "/embeddings"), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		c.setAuth(httpReq)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var embResp embeddingResponse
+	if err := decodeResponse(resp, &embResp); err != nil {
+		return nil, err
+	}
+	if embResp.Error != nil {
+		return nil, fmt.Errorf("api error: %s", embResp.Error.Message)
+	}
+	if len(embResp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embResp.Data))
+	}
+
+	if c.usage != nil && embResp.Usage != nil {
+		if err := c.usage.RecordUsage(embeddingsUsageChatID, *embResp.Usage); err != nil {
+			log.Printf("[llm] failed to record embedding usage: %v", err)
+		}
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range embResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vectors[d.Index] != nil {
+			return nil, fmt.Errorf("invalid embedding index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+
+	return vectors, nil
+}
@@ -0,0 +1,178 @@
+// Package llmtest provides a scripted tron.LLMClient for exercising bot code
+// without a model.
+package llmtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"tron"
+)
+
+// Call is one request received by a ScriptedClient.
+type Call struct {
+	Messages []tron.Message
+	Tools    []tron.Tool
+	Options  tron.ChatOptions
+}
+
+// ToolResult returns the tool message answering the call with the given ID.
+func (c Call) ToolResult(id string) (tron.Message, bool) {
+	for _, m := range c.Messages {
+		if m.Role == "tool" && m.ToolCallID == id {
+			return m, true
+		}
+	}
+	return tron.Message{}, false
+}
+
+// HasTool reports whether a tool with the given name was offered.
+func (c Call) HasTool(name string) bool {
+	for _, t := range c.Tools {
+		if t.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Last returns the final message of the request.
+func (c Call) Last() tron.Message {
+	if len(c.Messages) == 0 {
+		return tron.Message{}
+	}
+	return c.Messages[len(c.Messages)-1]
+}
+
+type step struct {
+	resp *tron.LLMResponse
+	err  error
+}
+
+// ScriptedClient answers each request with the next scripted step and records
+// what it was sent. Running past the end of the script is an error.
+type ScriptedClient struct {
+	mu     sync.Mutex
+	steps  []step
+	calls  []Call
+	models []string
+}
+
+func NewScriptedClient(responses ...*tron.LLMResponse) *ScriptedClient {
+	c := &ScriptedClient{models: []string{"scripted"}}
+	for _, r := range responses {
+		c.steps = append(c.steps, step{resp: r})
+	}
+	return c
+}
+
+// Then appends a response to the script.
+func (c *ScriptedClient) Then(resp *tron.LLMResponse) *ScriptedClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, step{resp: resp})
+	return c
+}
+
+// ThenError appends a failing step to the script.
+func (c *ScriptedClient) ThenError(err error) *ScriptedClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, step{err: err})
+	return c
+}
+
+func (c *ScriptedClient) SetModels(models ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.models = models
+}
+
+func (c *ScriptedClient) Chat(ctx context.Context, messages []tron.Message, tools []tron.Tool) (*tron.LLMResponse, error) {
+	return c.ChatWithOptions(ctx, messages, tools, tron.ChatOptions{})
+}
+
+func (c *ScriptedClient) ChatWithOptions(ctx context.Context, messages []tron.Message, tools []tron.Tool, opts tron.ChatOptions) (*tron.LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{
+		Messages: append([]tron.Message(nil), messages...),
+		Tools:    append([]tron.Tool(nil), tools...),
+		Options:  opts,
+	})
+
+	n := len(c.calls)
+	if n > len(c.steps) {
+		return nil, fmt.Errorf("llmtest: unexpected call %d, script has %d steps", n, len(c.steps))
+	}
+	s := c.steps[n-1]
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	resp := *s.resp
+	resp.ToolCalls = append([]tron.ToolCall(nil), s.resp.ToolCalls...)
+	return &resp, nil
+}
+
+func (c *ScriptedClient) Models(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.models...), nil
+}
+
+// Calls returns the requests received so far.
+func (c *ScriptedClient) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Call returns the i-th request (0-based).
+func (c *ScriptedClient) Call(i int) (Call, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i < 0 || i >= len(c.calls) {
+		return Call{}, fmt.Errorf("llmtest: call %d not made (%d calls)", i, len(c.calls))
+	}
+	return c.calls[i], nil
+}
+
+// Remaining returns how many scripted steps have not been consumed.
+func (c *ScriptedClient) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.steps) - len(c.calls)
+}
+
+// Reply is a plain text response.
+func Reply(content string) *tron.LLMResponse {
+	return &tron.LLMResponse{Content: content}
+}
+
+// CallTool is a response that requests a single tool call.
+func CallTool(id, name, argsJSON string) *tron.LLMResponse {
+	return &tron.LLMResponse{ToolCalls: []tron.ToolCall{ToolCall(id, name, argsJSON)}}
+}
+
+// CallTools is a response that requests several tool calls at once.
+func CallTools(calls ...tron.ToolCall) *tron.LLMResponse {
+	return &tron.LLMResponse{ToolCalls: calls}
+}
+
+func ToolCall(id, name, argsJSON string) tron.ToolCall {
+	return tron.ToolCall{
+		ID:   id,
+		Type: "function",
+		Function: tron.ToolCallFunction{
+			Name:      name,
+			Arguments: argsJSON,
+		},
+	}
+}
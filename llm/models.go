@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+type modelsResponse struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (c *Client) Models(ctx context.Context) ([]string, error) {
+	endpoint := c.apiURL + "/models"
+	if c.azureDeployment != "" {
+		endpoint = c.apiURL + "/openai/models?api-version=" + url.QueryEscape(c.azureAPIVersion)
+	}
+
+	return c.listModels(ctx, endpoint, c.setAuth)
+}
+
+func (c *Client) ValidateModel(ctx context.Context, name string) error {
+	return validateModel(ctx, c, name)
+}
+
+func (c *Client) listModels(ctx context.Context, endpoint string, setHeaders func(*http.Request)) ([]string, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		setHeaders(httpReq)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResp modelsResponse
+	if err := decodeResponse(resp, &modelsResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(modelsResp.Data))
+	for _, m := range modelsResp.Data {
+		models = append(models, m.ID)
+	}
+	sort.Strings(models)
+
+	return models, nil
+}
+
+func validateModel(ctx context.Context, client interface {
+	Models(ctx context.Context) ([]string, error)
+}, name string) error {
+	models, err := client.Models(ctx)
+	if err != nil {
+		return fmt.Errorf("list models: %w", err)
+	}
+	for _, m := range models {
+		if m == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q is not offered by the API (%d models available)", name, len(models))
+}
@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"tron"
+)
+
+var (
+	jsonFencePattern = regexp.MustCompile("(?s)^```(?:json)?\\s*(.*?)\\s*```$")
+	toolTagPattern   = regexp.MustCompile(`(?s)^<tool_call>\s*(.*?)\s*</tool_call>$`)
+)
+
+type contentToolCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Parameters json.RawMessage `json:"parameters"`
+	Function   *struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+func (c *Client) normalizeResponse(resp *tron.LLMResponse, tools []tron.Tool) {
+	if !c.ollamaCompat {
+		return
+	}
+
+	if len(resp.ToolCalls) == 0 && resp.Content != "" {
+		if calls := extractContentToolCalls(resp.Content, tools); len(calls) > 0 {
+			resp.ToolCalls = calls
+			resp.Content = ""
+		}
+	}
+
+	for i := range resp.ToolCalls {
+		if resp.ToolCalls[i].ID == "" {
+			resp.ToolCalls[i].ID = newToolCallID()
+		}
+		if resp.ToolCalls[i].Type == "" {
+			resp.ToolCalls[i].Type = "function"
+		}
+	}
+}
+
+func extractContentToolCalls(content string, tools []tron.Tool) []tron.ToolCall {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	text := strings.TrimSpace(content)
+	if m := jsonFencePattern.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+	if m := toolTagPattern.FindStringSubmatch(text); m != nil {
+		text = m[1]
+	}
+
+	var raw []contentToolCall
+	switch {
+	case strings.HasPrefix(text, "["):
+		if json.Unmarshal([]byte(text), &raw) != nil {
+			return nil
+		}
+	case strings.HasPrefix(text, "{"):
+		var wrapper struct {
+			ToolCalls []contentToolCall `json:"tool_calls"`
+		}
+		if json.Unmarshal([]byte(text), &wrapper) == nil && len(wrapper.ToolCalls) > 0 {
+			raw = wrapper.ToolCalls
+			break
+		}
+		var single contentToolCall
+		if json.Unmarshal([]byte(text), &single) != nil {
+			return nil
+		}
+		raw = []contentToolCall{single}
+	default:
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, t := range tools {
+		known[t.Function.Name] = true
+	}
+
+	var calls []tron.ToolCall
+	for _, r := range raw {
+		name, args := r.Name, r.Arguments
+		if len(args) == 0 {
+			args = r.Parameters
+		}
+		if r.Function != nil {
+			name, args = r.Function.Name, r.Function.Arguments
+		}
+		if !known[name] {
+			return nil
+		}
+
+		arguments := "{}"
+		if len(args) > 0 {
+			arguments = string(args)
+			var s string
+			if json.Unmarshal(args, &s) == nil {
+				arguments = s
+			}
+		}
+
+		calls = append(calls, tron.ToolCall{
+			Type: "function",
+			Function: tron.ToolCallFunction{
+				Name:      name,
+				Arguments: arguments,
+			},
+		})
+	}
+
+	return calls
+}
+
+func newToolCallID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"tron"
+)
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+var thinkPattern = regexp.MustCompile(`(?s)<think>(.*?)</think>`)
+
+// splitReasoning removes <think> spans from content and returns them
+// separately. Some servers drop the opening tag, so anything before a lone
+// closing tag is treated as reasoning too; an unterminated block is all
+// reasoning.
+func splitReasoning(content string) (answer, reasoning string) {
+	var parts []string
+	answer = thinkPattern.ReplaceAllStringFunc(content, func(m string) string {
+		parts = append(parts, strings.TrimSpace(thinkPattern.FindStringSubmatch(m)[1]))
+		return ""
+	})
+
+	if i := strings.Index(answer, thinkClose); i >= 0 {
+		parts = append(parts, strings.TrimSpace(answer[:i]))
+		answer = answer[i+len(thinkClose):]
+	}
+	if i := strings.Index(answer, thinkOpen); i >= 0 {
+		parts = append(parts, strings.TrimSpace(answer[i+len(thinkOpen):]))
+		answer = answer[:i]
+	}
+
+	return strings.TrimSpace(answer), strings.Join(parts, "\n\n")
+}
+
+func applyReasoning(resp *tron.LLMResponse, reasoningContent string) error {
+	if !strings.Contains(resp.Content, thinkOpen) && !strings.Contains(resp.Content, thinkClose) {
+		resp.Reasoning = reasoningContent
+	} else {
+		answer, reasoning := splitReasoning(resp.Content)
+		resp.Content = answer
+		resp.Reasoning = strings.TrimSpace(reasoningContent + "\n\n" + reasoning)
+	}
+
+	if resp.Content == "" && len(resp.ToolCalls) == 0 && resp.Reasoning != "" {
+		return fmt.Errorf("model returned reasoning but no answer")
+	}
+	return nil
+}
+
+// thinkFilter suppresses a leading <think> block from streamed deltas so that
+// reasoning never reaches onDelta.
+type thinkFilter struct {
+	buf      strings.Builder
+	decided  bool
+	thinking bool
+}
+
+func (f *thinkFilter) write(delta string) string {
+	if f.decided && !f.thinking {
+		return delta
+	}
+
+	f.buf.WriteString(delta)
+	s := f.buf.String()
+
+	if !f.decided {
+		trimmed := strings.TrimLeft(s, " \t\r\n")
+		if len(trimmed) < len(thinkOpen) && strings.HasPrefix(thinkOpen, trimmed) {
+			return ""
+		}
+		f.decided = true
+		f.thinking = strings.HasPrefix(trimmed, thinkOpen)
+		if !f.thinking {
+			f.buf.Reset()
+			return s
+		}
+	}
+
+	i := strings.Index(s, thinkClose)
+	if i < 0 {
+		return ""
+	}
+	f.thinking = false
+	rest := strings.TrimLeft(s[i+len(thinkClose):], " \t\r\n")
+	f.buf.Reset()
+	return rest
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tron"
+)
+
+func TestChatReasoning(t *testing.T) {
+	tests := []struct {
+		name          string
+		message       string
+		wantContent   string
+		wantReasoning string
+		wantErr       bool
+	}{{
+		name:          "reasoning_content field",
+		message:       `{"role":"assistant","content":"42","reasoning_content":"6 times 7"}`,
+		wantContent:   "42",
+		wantReasoning: "6 times 7",
+	}, {
+		name:          "think block in content",
+		message:       `{"role":"assistant","content":"<think>\n6 times 7\n</think>\n\n42"}`,
+		wantContent:   "42",
+		wantReasoning: "6 times 7",
+	}, {
+		name:          "think block without opening tag",
+		message:       `{"role":"assistant","content":"6 times 7\n</think>\n42"}`,
+		wantContent:   "42",
+		wantReasoning: "6 times 7",
+	}, {
+		name:          "both shapes",
+		message:       `{"role":"assistant","content":"<think>check</think>42","reasoning_content":"6 times 7"}`,
+		wantContent:   "42",
+		wantReasoning: "6 times 7\n\ncheck",
+	}, {
+		name:        "no reasoning",
+		message:     `{"role":"assistant","content":"42"}`,
+		wantContent: "42",
+	}, {
+		name:    "reasoning but no answer",
+		message: `{"role":"assistant","content":"","reasoning_content":"6 times 7"}`,
+		wantErr: true,
+	}, {
+		name:    "unterminated think block",
+		message: `{"role":"assistant","content":"<think>6 times"}`,
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"choices":[{"message":%s,"finish_reason":"stop"}]}`, tt.message)
+			}))
+			defer srv.Close()
+
+			resp, err := NewClient(srv.URL, "key", "model").Chat(context.Background(), []tron.Message{{Role: "user", Content: "6*7?"}}, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != tt.wantContent || resp.Reasoning != tt.wantReasoning {
+				t.Errorf("got content %q reasoning %q, want %q and %q", resp.Content, resp.Reasoning, tt.wantContent, tt.wantReasoning)
+			}
+		})
+	}
+}
+
+func TestReadStreamReasoning(t *testing.T) {
+	tests := []struct {
+		name          string
+		deltas        []string
+		reasoning     []string
+		wantVisible   string
+		wantContent   string
+		wantReasoning string
+	}{{
+		name:          "think block split across deltas",
+		deltas:        []string{"<th", "ink>6 times", " 7</thi", "nk>\n4", "2"},
+		wantVisible:   "42",
+		wantContent:   "42",
+		wantReasoning: "6 times 7",
+	}, {
+		name:          "reasoning_content deltas",
+		reasoning:     []string{"6 times", " 7"},
+		deltas:        []string{"4", "2"},
+		wantVisible:   "42",
+		wantContent:   "42",
+		wantReasoning: "6 times 7",
+	}, {
+		name:        "angle bracket that is not a think tag",
+		deltas:      []string{"<", "b>42</b>"},
+		wantVisible: "<b>42</b>",
+		wantContent: "<b>42</b>",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []string
+			for _, r := range tt.reasoning {
+				chunks = append(chunks, fmt.Sprintf(`{"choices":[{"delta":{"reasoning_content":%q}}]}`, r))
+			}
+			for _, d := range tt.deltas {
+				chunks = append(chunks, fmt.Sprintf(`{"choices":[{"delta":{"content":%q}}]}`, d))
+			}
+
+			var visible strings.Builder
+			resp, err := readStream(strings.NewReader(sse(chunks...)), func(d string) { visible.WriteString(d) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := applyReasoning(resp, resp.Reasoning); err != nil {
+				t.Fatal(err)
+			}
+			if visible.String() != tt.wantVisible {
+				t.Errorf("streamed %q, want %q", visible.String(), tt.wantVisible)
+			}
+			if resp.Content != tt.wantContent || resp.Reasoning != tt.wantReasoning {
+				t.Errorf("got content %q reasoning %q, want %q and %q", resp.Content, resp.Reasoning, tt.wantContent, tt.wantReasoning)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tron"
+)
+
+const (
+	defaultRecorderMax   = 50
+	maxRecordedBodyBytes = 256 * 1024
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_+-]+`)
+
+type Exchange struct {
+	Time     time.Time       `json:"time"`
+	ChatID   string          `json:"chat_id,omitempty"`
+	URL      string          `json:"url"`
+	Headers  []string        `json:"headers,omitempty"`
+	Status   int             `json:"status,omitempty"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response string          `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Recorder keeps the most recent LLM exchanges in memory and, when dir is set,
+// also writes each one to a timestamped file in dir.
+type Recorder struct {
+	dir string
+	max int
+
+	mu          sync.Mutex
+	exchanges   []Exchange
+	toolOutputs []ToolOutput
+}
+
+// ToolOutput is the complete result of a tool call that was too long to send
+// to the LLM as is.
+type ToolOutput struct {
+	Time   time.Time
+	ChatID string
+	Tool   string
+	Output string
+}
+
+func NewRecorder(dir string, max int) (*Recorder, error) {
+	if max <= 0 {
+		max = defaultRecorderMax
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("create log dir: %w", err)
+		}
+	}
+	return &Recorder{dir: dir, max: max}, nil
+}
+
+func WithRecorder(r *Recorder) Option {
+	return func(c *Client) {
+		c.recorder = r
+	}
+}
+
+// Recent returns the exchange for chatID that is back exchanges before the
+// most recent one; an empty chatID matches every chat.
+func (r *Recorder) Recent(chatID string, back int) (Exchange, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.exchanges) - 1; i >= 0; i-- {
+		if chatID != "" && r.exchanges[i].ChatID != chatID {
+			continue
+		}
+		if back == 0 {
+			return r.exchanges[i], true
+		}
+		back--
+	}
+	return Exchange{}, false
+}
+
+// AddToolOutput keeps the full output of an oversized tool result.
+func (r *Recorder) AddToolOutput(chatID, tool, output string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.toolOutputs = append(r.toolOutputs, ToolOutput{Time: time.Now(), ChatID: chatID, Tool: tool, Output: output})
+	if len(r.toolOutputs) > r.max {
+		r.toolOutputs = r.toolOutputs[len(r.toolOutputs)-r.max:]
+	}
+}
+
+// RecentToolOutput works like Recent for outputs kept by AddToolOutput.
+func (r *Recorder) RecentToolOutput(chatID string, back int) (ToolOutput, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.toolOutputs) - 1; i >= 0; i-- {
+		if chatID != "" && r.toolOutputs[i].ChatID != chatID {
+			continue
+		}
+		if back == 0 {
+			return r.toolOutputs[i], true
+		}
+		back--
+	}
+	return ToolOutput{}, false
+}
+
+func (r *Recorder) add(ex Exchange) {
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, ex)
+	if len(r.exchanges) > r.max {
+		r.exchanges = r.exchanges[len(r.exchanges)-r.max:]
+	}
+	r.mu.Unlock()
+
+	if r.dir != "" {
+		if err := r.write(ex); err != nil {
+			log.Printf("[llm] failed to write exchange log: %v", err)
+		}
+	}
+}
+
+func (r *Recorder) write(ex Exchange) error {
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := ex.Time.UTC().Format("20060102T150405.000000000")
+	if ex.ChatID != "" {
+		name += "-" + unsafeFileChars.ReplaceAllString(ex.ChatID, "_")
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, name+".json"), data, 0600); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for len(files) > r.max {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// record captures the request body and wraps the response body so the
+// exchange is stored once the caller has read and closed it.
+func (c *Client) record(httpReq *http.Request, resp *http.Response, errBody []byte, reqErr error) {
+	ex := Exchange{
+		Time:   time.Now(),
+		ChatID: tron.ChatIDFromContext(httpReq.Context()),
+		URL:    httpReq.URL.Redacted(),
+	}
+	// Only header names are kept; values may carry credentials.
+	for name := range httpReq.Header {
+		ex.Headers = append(ex.Headers, name)
+	}
+	sort.Strings(ex.Headers)
+	if httpReq.GetBody != nil {
+		if body, err := httpReq.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxRecordedBodyBytes))
+			body.Close()
+			if json.Valid(data) {
+				ex.Request = json.RawMessage(c.redact(string(data)))
+			}
+		}
+	}
+	if reqErr != nil {
+		ex.Error = reqErr.Error()
+	}
+
+	if resp == nil {
+		c.recorder.add(ex)
+		return
+	}
+	ex.Status = resp.StatusCode
+	if errBody != nil {
+		ex.Response = c.redact(string(errBody))
+		c.recorder.add(ex)
+		return
+	}
+
+	resp.Body = &recordingBody{ReadCloser: resp.Body, done: func(data []byte) {
+		ex.Response = c.redact(string(data))
+		c.recorder.add(ex)
+	}}
+}
+
+func (c *Client) redact(s string) string {
+	if c.apiKey == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, c.apiKey, "[REDACTED]")
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := maxRecordedBodyBytes - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+	return b.ReadCloser.Close()
+}
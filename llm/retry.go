@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultTimeout    = 120 * time.Second
+	maxErrorBodyBytes = 512
+	maxResponseBytes  = 10 << 20
+	baseRetryDelay    = time.Second
+	maxRetryDelay     = 30 * time.Second
+)
+
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		httpReq, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			if attempt < c.maxRetries && ctx.Err() == nil && isRetryableError(err) {
+				if err := c.sleepBeforeRetry(ctx, attempt, "", err.Error()); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if c.recorder != nil {
+				c.record(httpReq, nil, nil, err)
+			}
+			return nil, fmt.Errorf("send request: %w", err)
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if c.recorder != nil {
+				c.record(httpReq, resp, nil, nil)
+			}
+			return resp, nil
+		}
+
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes+1))
+		resp.Body.Close()
+
+		if attempt < c.maxRetries && isRetryableStatus(resp.StatusCode) {
+			if err := c.sleepBeforeRetry(ctx, attempt, resp.Header.Get("Retry-After"), resp.Status); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if c.recorder != nil {
+			c.record(httpReq, resp, errBody, nil)
+		}
+		return nil, fmt.Errorf("api error: status %d: %s", resp.StatusCode, snippet(errBody))
+	}
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decode response (status %d, content-type %q): %w: %s",
+			resp.StatusCode, resp.Header.Get("Content-Type"), err, snippet(body))
+	}
+	return nil
+}
+
+func snippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) > maxErrorBodyBytes {
+		s = s[:maxErrorBodyBytes] + "..."
+	}
+	return s
+}
+
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+func isRetryableError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func retryDelay(attempt int, retryAfter string) time.Duration {
+	if retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, maxRetryDelay)
+		}
+		if t, err := http.ParseTime(retryAfter); err == nil {
+			return min(max(time.Until(t), 0), maxRetryDelay)
+		}
+	}
+
+	return min(baseRetryDelay<<attempt, maxRetryDelay)
+}
+
+func (c *Client) sleepBeforeRetry(ctx context.Context, attempt int, retryAfter, reason string) error {
+	delay := retryDelay(attempt, retryAfter)
+	log.Printf("[llm] request failed (%s), retrying in %s (attempt %d/%d)", reason, delay, attempt+1, c.maxRetries)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"tron"
+)
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *tron.Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type toolCallBuilder struct {
+	id        string
+	typ       string
+	name      strings.Builder
+	arguments strings.Builder
+}
+
+func (c *Client) ChatStream(ctx context.Context, messages []tron.Message, tools []tron.Tool, onDelta func(string)) (*tron.LLMResponse, error) {
+	req := chatRequest{
+		Model:         c.model,
+		Messages:      c.requestMessages(messages),
+		Stream:        true,
+		StreamOptions: &streamOptions{IncludeUsage: true},
+		ChatOptions:   c.options,
+		KeepAlive:     c.keepAlive,
+	}
+	if len(tools) > 0 {
+		req.Tools = tools
+	}
+
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result, err := readStream(resp.Body, onDelta)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyReasoning(result, result.Reasoning); err != nil {
+		return nil, err
+	}
+	c.normalizeResponse(result, tools)
+
+	return result, nil
+}
+
+func readStream(r io.Reader, onDelta func(string)) (*tron.LLMResponse, error) {
+	var content, reasoning strings.Builder
+	var filter thinkFilter
+	var usage *tron.Usage
+	builders := make(map[int]*toolCallBuilder)
+	var sawData bool
+	var other []byte
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			if len(other) <= maxErrorBodyBytes {
+				other = append(other, line+"\n"...)
+			}
+			continue
+		}
+		sawData = true
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("decode chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("api error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta
+		reasoning.WriteString(delta.ReasoningContent)
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if visible := filter.write(delta.Content); visible != "" && onDelta != nil {
+				onDelta(visible)
+			}
+		}
+
+		for _, tc := range delta.ToolCalls {
+			b, ok := builders[tc.Index]
+			if !ok {
+				b = &toolCallBuilder{}
+				builders[tc.Index] = b
+			}
+			if tc.ID != "" {
+				b.id = tc.ID
+			}
+			if tc.Type != "" {
+				b.typ = tc.Type
+			}
+			b.name.WriteString(tc.Function.Name)
+			b.arguments.WriteString(tc.Function.Arguments)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	if !sawData {
+		return nil, fmt.Errorf("no events in stream response: %s", snippet(other))
+	}
+
+	indexes := make([]int, 0, len(builders))
+	for i := range builders {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	result := &tron.LLMResponse{Content: content.String(), Usage: usage, Reasoning: reasoning.String()}
+	for _, i := range indexes {
+		b := builders[i]
+		typ := b.typ
+		if typ == "" {
+			typ = "function"
+		}
+		result.ToolCalls = append(result.ToolCalls, tron.ToolCall{
+			ID:   b.id,
+			Type: typ,
+			Function: tron.ToolCallFunction{
+				Name:      b.name.String(),
+				Arguments: b.arguments.String(),
+			},
+		})
+	}
+
+	return result, nil
+}
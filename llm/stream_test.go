@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tron"
+)
+
+// sse turns chunks into a chat-completions event stream.
+func sse(chunks ...string) string {
+	var sb strings.Builder
+	for _, c := range chunks {
+		fmt.Fprintf(&sb, "data: %s\n\n", c)
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+func TestReadStreamToolCalls(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []tron.ToolCall
+	}{{
+		name: "arguments split across chunks",
+		stream: sse(
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"task","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"act"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ion\": \"li"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"st\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		),
+		want: []tron.ToolCall{{ID: "call_1", Type: "function", Function: tron.ToolCallFunction{Name: "task", Arguments: `{"action": "list"}`}}},
+	}, {
+		name: "name split across chunks",
+		stream: sse(
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"wea"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"ther","arguments":"{}"}}]}}]}`,
+		),
+		want: []tron.ToolCall{{ID: "call_1", Type: "function", Function: tron.ToolCallFunction{Name: "weather", Arguments: "{}"}}},
+	}, {
+		name: "parallel calls interleaved",
+		stream: sse(
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"weather","arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"task","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+		),
+		want: []tron.ToolCall{
+			{ID: "call_a", Type: "function", Function: tron.ToolCallFunction{Name: "task", Arguments: "{}"}},
+			{ID: "call_b", Type: "function", Function: tron.ToolCallFunction{Name: "weather", Arguments: `{"city":"Oslo"}`}},
+		},
+	}, {
+		name: "several calls in one chunk",
+		stream: sse(
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"task","arguments":"{}"}},{"index":1,"id":"call_b","function":{"name":"note","arguments":"{}"}}]}}]}`,
+		),
+		want: []tron.ToolCall{
+			{ID: "call_a", Type: "function", Function: tron.ToolCallFunction{Name: "task", Arguments: "{}"}},
+			{ID: "call_b", Type: "function", Function: tron.ToolCallFunction{Name: "note", Arguments: "{}"}},
+		},
+	}, {
+		name:   "no tool calls",
+		stream: sse(`{"choices":[{"delta":{"content":"Hi"}}]}`),
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := readStream(strings.NewReader(tt.stream), nil)
+			if err != nil {
+				t.Fatalf("readStream: %v", err)
+			}
+			if !reflect.DeepEqual(resp.ToolCalls, tt.want) {
+				t.Errorf("tool calls = %+v, want %+v", resp.ToolCalls, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadStreamContent(t *testing.T) {
+	stream := sse(
+		`{"choices":[{"delta":{"role":"assistant","content":""}}]}`,
+		`{"choices":[{"delta":{"content":"Hel"}}]}`,
+		`{"choices":[{"delta":{"content":"lo"}}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}`,
+	)
+	var deltas []string
+	resp, err := readStream(strings.NewReader(stream), func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" {
+		t.Errorf("content = %q, want %q", resp.Content, "Hello")
+	}
+	if fmt.Sprint(deltas) != "[Hel lo]" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 5 || resp.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestReadStreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+	}{
+		{"api error", sse(`{"error":{"message":"overloaded"}}`)},
+		{"bad chunk", sse(`{"choices":`)},
+		{"not a stream", `{"error":"not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readStream(strings.NewReader(tt.stream), nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, sse(
+			`{"choices":[{"delta":{"content":"Checking."}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"task","arguments":"{\"action\""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":":\"list\"}"}}]}}]}`,
+		))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "key", "model")
+	resp, err := c.ChatStream(context.Background(), []tron.Message{{Role: "user", Content: "tasks?"}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Checking." || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Function.Arguments != `{"action":"list"}` {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -0,0 +1,11 @@
+package memory
+
+import "time"
+
+func (s *Store) RecordAudit(chatID string, messageLen, responseLen int, duration time.Duration, errMsg string) error {
+	_, err := s.exec(`
+		INSERT INTO audit_log (chat_id, message_length, response_length, duration_ms, error)
+		VALUES (?, ?, ?, ?, ?)
+	`, chatID, messageLen, responseLen, duration.Milliseconds(), errMsg)
+	return err
+}
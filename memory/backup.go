@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	backupPagesPerStep = 256
+	backupStepPause    = 10 * time.Millisecond
+)
+
+// Backup writes a consistent copy of the SQLite database at dbPath to dest
+// using SQLite's online backup API, so it is safe while the bot is running.
+// progress, if set, is called after each step with pages copied and total.
+func Backup(ctx context.Context, dbPath, dest string, progress func(copied, total int)) error {
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", sqliteDSN(dbPath))
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer db.Close()
+
+	return backupDB(ctx, db, dest, progress)
+}
+
+// Backup copies the store's database to dest. Only SQLite is supported.
+func (s *Store) Backup(ctx context.Context, dest string, progress func(copied, total int)) error {
+	if s.driver != "sqlite3" {
+		return fmt.Errorf("backup is only supported for sqlite3, not %s", s.driver)
+	}
+	return backupDB(ctx, s.db, dest, progress)
+}
+
+// backupDB writes to a temporary file next to dest and renames it into place
+// so an interrupted backup never replaces a good one.
+func backupDB(ctx context.Context, src *sql.DB, dest string, progress func(copied, total int)) error {
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+
+	if err := copyDB(ctx, src, tmp, progress); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename backup: %w", err)
+	}
+	return nil
+}
+
+func copyDB(ctx context.Context, src *sql.DB, dest string, progress func(copied, total int)) error {
+	destDB, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer destDB.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("source connection: %w", err)
+	}
+	defer srcConn.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("backup connection: %w", err)
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			d, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup needs a sqlite3 connection")
+			}
+			s, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("backup needs a sqlite3 connection")
+			}
+
+			b, err := d.Backup("main", s, "main")
+			if err != nil {
+				return fmt.Errorf("start backup: %w", err)
+			}
+
+			for {
+				done, err := b.Step(backupPagesPerStep)
+				if err != nil {
+					b.Close()
+					return fmt.Errorf("backup step: %w", err)
+				}
+				if progress != nil {
+					total := b.PageCount()
+					progress(total-b.Remaining(), total)
+				}
+				if done {
+					break
+				}
+
+				select {
+				case <-ctx.Done():
+					b.Close()
+					return ctx.Err()
+				case <-time.After(backupStepPause):
+				}
+			}
+
+			if err := b.Finish(); err != nil {
+				return fmt.Errorf("finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package memory
+
+// ChatSystemPrompts returns the system prompts set for individual chats.
+func (s *Store) ChatSystemPrompts() (map[string]string, error) {
+	rows, err := s.query("SELECT chat_id, system_prompt FROM chat_config WHERE system_prompt <> ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prompts := make(map[string]string)
+	for rows.Next() {
+		var chatID, prompt string
+		if err := rows.Scan(&chatID, &prompt); err != nil {
+			return nil, err
+		}
+		prompts[chatID] = prompt
+	}
+	return prompts, rows.Err()
+}
+
+// SetChatSystemPrompt stores the system prompt of chatID. An empty prompt
+// removes it, so the chat uses the global one again.
+func (s *Store) SetChatSystemPrompt(chatID, prompt string) error {
+	if prompt == "" {
+		_, err := s.exec("DELETE FROM chat_config WHERE chat_id = ?", chatID)
+		return err
+	}
+	_, err := s.exec(`
+		INSERT INTO chat_config (chat_id, system_prompt, updated_at) VALUES (?, ?, {{now}})
+		ON CONFLICT(chat_id) DO UPDATE SET system_prompt = excluded.system_prompt, updated_at = excluded.updated_at
+	`, chatID, prompt)
+	return err
+}
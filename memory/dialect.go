@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dialect adapts the SQLite-flavoured SQL used in this package to the
+// configured database. Schemas and queries are written with ? placeholders
+// and the {{autoincrement}}, {{timestamp}}, {{blob}} and {{now}} tokens.
+type dialect interface {
+	ddl(schema string) string
+	rebind(query string) string
+	hasColumn(tx *sql.Tx, table, column string) (bool, error)
+}
+
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "", "sqlite3":
+		return sqliteDialect{}, nil
+	case "postgres":
+		return postgresDialect{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+type sqliteDialect struct{}
+
+var sqliteTokens = strings.NewReplacer(
+	"{{autoincrement}}", "INTEGER PRIMARY KEY AUTOINCREMENT",
+	"{{timestamp}}", "DATETIME",
+	"{{blob}}", "BLOB",
+	"{{now}}", "CURRENT_TIMESTAMP",
+)
+
+func (sqliteDialect) ddl(schema string) string {
+	return sqliteTokens.Replace(schema)
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return sqliteTokens.Replace(query)
+}
+
+func (sqliteDialect) hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name, typ  string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+type postgresDialect struct{}
+
+var postgresTokens = strings.NewReplacer(
+	"{{autoincrement}}", "SERIAL PRIMARY KEY",
+	"{{timestamp}}", "TIMESTAMPTZ",
+	"{{blob}}", "BYTEA",
+	"{{now}}", "NOW()",
+)
+
+func (postgresDialect) ddl(schema string) string {
+	return postgresTokens.Replace(schema)
+}
+
+// rebind numbers ? placeholders as $1, $2, ... outside of quoted strings.
+func (postgresDialect) rebind(query string) string {
+	query = postgresTokens.Replace(query)
+
+	var sb strings.Builder
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func (d postgresDialect) hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	var n int
+	err := tx.QueryRow(d.rebind(`
+		SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?
+	`), table, column).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,5 @@
+//go:build postgres
+
+package memory
+
+import _ "github.com/lib/pq"
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks content encrypted by the store. Rows without it are
+// plaintext, written before encryption was enabled.
+const encryptedPrefix = "enc:v1:"
+
+var errNoKey = errors.New("database contains encrypted content but no db_encryption_key is set")
+
+// WithEncryptionKey encrypts message content and tool calls, archived
+// messages and rolling summaries with AES-256-GCM under key, which must be
+// 32 bytes. Existing plaintext stays readable; EncryptExisting converts it.
+// Facts are not encrypted.
+func WithEncryptionKey(key []byte) Option {
+	return func(s *Store) {
+		s.encryptionKey = key
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts s if a key is set. Empty strings stay empty so queries for
+// empty content keep working.
+func (s *Store) seal(plaintext string) (string, error) {
+	if s.aead == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts content written by seal and returns plaintext unchanged.
+func (s *Store) open(content string) (string, error) {
+	if !strings.HasPrefix(content, encryptedPrefix) {
+		return content, nil
+	}
+	if s.aead == nil {
+		return "", errNoKey
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted content: %w", err)
+	}
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return "", fmt.Errorf("encrypted content too short")
+	}
+	plaintext, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt content: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptResult counts the rows EncryptExisting encrypted.
+type EncryptResult struct {
+	Messages  int
+	ToolCalls int
+	Archived  int
+	Summaries int
+}
+
+// EncryptExisting encrypts the plaintext content left from before
+// encryption was enabled, in one transaction. For SQLite it then rebuilds
+// the file and empties the WAL, since both still hold the old plaintext
+// pages.
+func (s *Store) EncryptExisting() (EncryptResult, error) {
+	var result EncryptResult
+	if s.aead == nil {
+		return result, fmt.Errorf("no encryption key set")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, t := range []struct {
+		table, key, column string
+		count              *int
+	}{
+		{"messages", "id", "content", &result.Messages},
+		{"messages", "id", "tool_calls", &result.ToolCalls},
+		{"message_vectors", "id", "content", &result.Archived},
+		{"conversation_summaries", "chat_id", "summary", &result.Summaries},
+	} {
+		n, err := s.encryptColumn(tx, t.table, t.key, t.column)
+		if err != nil {
+			return result, fmt.Errorf("encrypt %s: %w", t.table, err)
+		}
+		*t.count = n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	if s.driver != "sqlite3" {
+		return result, nil
+	}
+	if err := s.rebuild(context.Background()); err != nil {
+		return result, fmt.Errorf("remove old plaintext: %w", err)
+	}
+	return result, nil
+}
+
+func (s *Store) encryptColumn(tx *sql.Tx, table, key, column string) (int, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s NOT LIKE '%s%%' AND %s <> ''",
+		key, column, table, column, encryptedPrefix, column))
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		key     interface{}
+		content string
+	}
+	var plain []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.key, &r.content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		plain = append(plain, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := s.dialect.rebind(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", table, column, key))
+	for _, r := range plain {
+		sealed, err := s.seal(r.content)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(update, sealed, r.key); err != nil {
+			return 0, err
+		}
+	}
+	return len(plain), nil
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tron"
+)
+
+const secret = "correct-horse-battery-staple"
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func secretMessages() []tron.Message {
+	return []tron.Message{
+		{Role: "user", Content: "remember my password " + secret},
+		{Role: "assistant", ToolCalls: []tron.ToolCall{{
+			ID: "call_1", Type: "function",
+			Function: tron.ToolCallFunction{Name: "memory", Arguments: `{"action":"remember","content":"password ` + secret + `"}`},
+		}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "Remembered."},
+		{Role: "assistant", Content: "Done."},
+	}
+}
+
+// fileContains reports whether the database file or its WAL contains s.
+func fileContains(t *testing.T, path, s string) bool {
+	t.Helper()
+	for _, p := range []string{path, path + "-wal"} {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEncryptExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tron.db")
+
+	plain, err := NewStore(path, 50, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.AddMessages("dm:+100", secretMessages(), 0); err != nil {
+		t.Fatal(err)
+	}
+	plain.Close()
+
+	s, err := NewStore(path, 50, 60, WithEncryptionKey(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.EncryptExisting()
+	if err != nil {
+		t.Fatalf("EncryptExisting: %v", err)
+	}
+	if want := (EncryptResult{Messages: 3, ToolCalls: 1}); result != want {
+		t.Errorf("EncryptExisting() = %+v, want %+v", result, want)
+	}
+	if fileContains(t, path, secret) {
+		t.Error("database file still contains the plaintext after EncryptExisting")
+	}
+
+	history, err := s.GetHistoryByRole("dm:+100", "assistant", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || len(history[0].ToolCalls) != 1 || !strings.Contains(history[0].ToolCalls[0].Function.Arguments, secret) {
+		t.Errorf("assistant messages after encryption = %+v, want the tool call readable", history)
+	}
+	s.Close()
+}
+
+func TestToolCallsEncrypted(t *testing.T) {
+	s := newTestStore(t, 50, WithEncryptionKey(testKey))
+	if err := s.AddMessages("dm:+100", secretMessages(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw sql.NullString
+	if err := s.db.QueryRow("SELECT tool_calls FROM messages WHERE tool_calls IS NOT NULL").Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw.String, encryptedPrefix) {
+		t.Errorf("stored tool_calls = %q, want it encrypted", raw.String)
+	}
+
+	history, err := s.GetHistory("dm:+100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args string
+	for _, m := range history {
+		for _, tc := range m.ToolCalls {
+			args = tc.Function.Arguments
+		}
+	}
+	if !strings.Contains(args, secret) {
+		t.Errorf("tool call arguments read back = %q, want the original", args)
+	}
+
+	var export bytes.Buffer
+	if err := s.Export(&export); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(export.String(), `"arguments"`) || !strings.Contains(export.String(), secret) {
+		t.Errorf("export does not contain the decrypted tool call:\n%s", export.String())
+	}
+}
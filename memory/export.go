@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportVersion is written in the header of every export. Import refuses
+// dumps with a newer version.
+const exportVersion = 1
+
+// ErrNotEmpty is returned by Import when the store already holds data and
+// merging was not requested.
+var ErrNotEmpty = errors.New("database is not empty")
+
+// exportRecord is one line of an export. The first line is a "header";
+// the others are "message" or "fact" records.
+type exportRecord struct {
+	Type       string          `json:"type"`
+	Version    int             `json:"version,omitempty"`
+	ExportedAt *time.Time      `json:"exported_at,omitempty"`
+	ChatID     string          `json:"chat_id,omitempty"`
+	Role       string          `json:"role,omitempty"`
+	Content    string          `json:"content,omitempty"`
+	Timestamp  *time.Time      `json:"timestamp,omitempty"`
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Sender     string          `json:"sender,omitempty"`
+	Pinned     bool            `json:"pinned,omitempty"`
+	Tags       []string        `json:"tags,omitempty"`
+}
+
+// ImportResult counts what Import added and what it skipped as duplicates.
+type ImportResult struct {
+	Messages int
+	Facts    int
+	Skipped  int
+}
+
+// Export writes the stored messages and facts to w as JSON lines, starting
+// with a versioned header. Expired messages are left out.
+func (s *Store) Export(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	now := time.Now().UTC()
+	if err := enc.Encode(exportRecord{Type: "header", Version: exportVersion, ExportedAt: &now}); err != nil {
+		return err
+	}
+	if err := s.exportMessages(enc); err != nil {
+		return fmt.Errorf("export messages: %w", err)
+	}
+	if err := s.exportFacts(enc); err != nil {
+		return fmt.Errorf("export facts: %w", err)
+	}
+	return bw.Flush()
+}
+
+func (s *Store) exportMessages(enc *json.Encoder) error {
+	rows, err := s.query(`
+		SELECT chat_id, role, content, signal_timestamp, expires_at, tool_calls, tool_call_id, sender, pinned
+		FROM messages
+		WHERE expires_at IS NULL OR expires_at > {{now}} OR pinned = 1
+		ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			r                             = exportRecord{Type: "message"}
+			timestamp                     time.Time
+			expiresAt                     sql.NullTime
+			toolCalls, toolCallID, sender sql.NullString
+		)
+		if err := rows.Scan(&r.ChatID, &r.Role, &r.Content, &timestamp, &expiresAt, &toolCalls, &toolCallID, &sender, &r.Pinned); err != nil {
+			return err
+		}
+		timestamp = timestamp.UTC()
+		r.Timestamp = &timestamp
+		if expiresAt.Valid {
+			t := expiresAt.Time.UTC()
+			r.ExpiresAt = &t
+		}
+		if toolCalls.String != "" {
+			data, err := s.open(toolCalls.String)
+			if err != nil {
+				return err
+			}
+			r.ToolCalls = json.RawMessage(data)
+		}
+		r.ToolCallID = toolCallID.String
+		r.Sender = sender.String
+		if r.Content, err = s.open(r.Content); err != nil {
+			return err
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Store) exportFacts(enc *json.Encoder) error {
+	rows, err := s.query("SELECT chat_id, content, tags, created_at FROM facts ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			r         = exportRecord{Type: "fact"}
+			tags      string
+			createdAt time.Time
+		)
+		if err := rows.Scan(&r.ChatID, &r.Content, &tags, &createdAt); err != nil {
+			return err
+		}
+		createdAt = createdAt.UTC()
+		r.Timestamp = &createdAt
+		r.Tags = splitTags(tags)
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import reads a dump written by Export. Unless merge is set it refuses to
+// write to a store that already holds messages or facts. Messages already
+// present with the same chat, time, role and content, and facts with the
+// same chat and content, are skipped, so importing a dump twice is
+// harmless. Everything is imported in one transaction.
+func (s *Store) Import(r io.Reader, merge bool) (ImportResult, error) {
+	var result ImportResult
+
+	if !merge {
+		var n int
+		if err := s.queryRow("SELECT (SELECT COUNT(*) FROM messages) + (SELECT COUNT(*) FROM facts)").Scan(&n); err != nil {
+			return result, err
+		}
+		if n > 0 {
+			return result, ErrNotEmpty
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line, header := 0, false
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec exportRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !header {
+			if rec.Type != "header" {
+				return result, fmt.Errorf("line %d: not a tron export", line)
+			}
+			if rec.Version > exportVersion {
+				return result, fmt.Errorf("export version %d is newer than supported (%d)", rec.Version, exportVersion)
+			}
+			header = true
+			continue
+		}
+
+		var added bool
+		switch rec.Type {
+		case "message":
+			added, err = s.importMessage(tx, rec)
+			if added {
+				result.Messages++
+			}
+		case "fact":
+			added, err = s.importFact(tx, rec)
+			if added {
+				result.Facts++
+			}
+		default:
+			err = fmt.Errorf("unknown record type %q", rec.Type)
+		}
+		if err != nil {
+			return result, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !added {
+			result.Skipped++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return result, err
+	}
+	if !header {
+		return result, fmt.Errorf("empty export")
+	}
+
+	return result, tx.Commit()
+}
+
+func (s *Store) importMessage(tx *sql.Tx, rec exportRecord) (bool, error) {
+	if rec.ChatID == "" || rec.Role == "" || rec.Timestamp == nil {
+		return false, fmt.Errorf("message needs chat_id, role and timestamp")
+	}
+	sentAt := rec.Timestamp.UTC()
+
+	dup, err := s.hasMessage(tx, rec.ChatID, sentAt, rec.Role, rec.Content)
+	if err != nil || dup {
+		return false, err
+	}
+	content, err := s.seal(rec.Content)
+	if err != nil {
+		return false, err
+	}
+	toolCalls, err := s.seal(string(rec.ToolCalls))
+	if err != nil {
+		return false, err
+	}
+
+	var expiresAt sql.NullTime
+	if rec.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *rec.ExpiresAt, Valid: true}
+	}
+	_, err = tx.Exec(s.dialect.rebind(`
+		INSERT INTO messages (chat_id, role, content, timestamp, signal_timestamp, expires_at, tool_calls, tool_call_id, sender, pinned)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), rec.ChatID, rec.Role, content, formatTimestamp(sentAt), sentAt, expiresAt,
+		nullString(toolCalls), nullString(rec.ToolCallID), nullString(rec.Sender), boolInt(rec.Pinned))
+	return err == nil, err
+}
+
+func (s *Store) importFact(tx *sql.Tx, rec exportRecord) (bool, error) {
+	if rec.Content == "" {
+		return false, fmt.Errorf("fact needs content")
+	}
+
+	var n int
+	err := tx.QueryRow(s.dialect.rebind("SELECT COUNT(*) FROM facts WHERE chat_id = ? AND content = ?"),
+		rec.ChatID, rec.Content,
+	).Scan(&n)
+	if err != nil || n > 0 {
+		return false, err
+	}
+
+	createdAt := formatTimestamp(time.Now())
+	if rec.Timestamp != nil {
+		createdAt = formatTimestamp(*rec.Timestamp)
+	}
+	_, err = tx.Exec(s.dialect.rebind("INSERT INTO facts (chat_id, content, tags, created_at) VALUES (?, ?, ?, ?)"),
+		rec.ChatID, rec.Content, joinTags(rec.Tags), createdAt)
+	return err == nil, err
+}
+
+// formatTimestamp writes t in UTC the way the databases' CURRENT_TIMESTAMP
+// defaults store it, so imported rows sort and compare like native ones.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,328 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"tron"
+)
+
+// maxPromptFacts caps how many facts are added to the system prompt.
+const maxPromptFacts = 50
+
+// Fact is something worth remembering beyond the conversation window. An
+// empty ChatID makes it global, visible in every chat.
+type Fact struct {
+	ID        int64
+	ChatID    string
+	Content   string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+// AddFact stores a fact for chatID, or a global one if chatID is empty.
+// Facts are not removed by the cleanup loop or ClearHistory.
+func (s *Store) AddFact(chatID, content string, tags []string) (int64, error) {
+	var id int64
+	err := s.queryRow(
+		"INSERT INTO facts (chat_id, content, tags) VALUES (?, ?, ?) RETURNING id",
+		chatID, content, joinTags(tags),
+	).Scan(&id)
+	return id, err
+}
+
+// Facts returns the facts of chatID and the global ones, newest first. With
+// a query, only facts whose content or tags contain it are returned; with a
+// tag, only facts carrying it. limit <= 0 returns all.
+func (s *Store) Facts(chatID, query, tag string, limit int) ([]Fact, error) {
+	q := "SELECT id, chat_id, content, tags, created_at FROM facts WHERE (chat_id = ? OR chat_id = '')"
+	args := []interface{}{chatID}
+	if query != "" {
+		q += " AND (LOWER(content) LIKE ? OR LOWER(tags) LIKE ?)"
+		pattern := "%" + strings.ToLower(query) + "%"
+		args = append(args, pattern, pattern)
+	}
+	if tag != "" {
+		q += " AND tags LIKE ?"
+		args = append(args, "%,"+normalizeTag(tag)+",%")
+	}
+	q += " ORDER BY created_at DESC, id DESC"
+	if limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facts []Fact
+	for rows.Next() {
+		var (
+			f    Fact
+			tags string
+		)
+		if err := rows.Scan(&f.ID, &f.ChatID, &f.Content, &tags, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.Tags = splitTags(tags)
+		facts = append(facts, f)
+	}
+	return facts, rows.Err()
+}
+
+// DeleteFact removes a fact of chatID or a global one. It reports whether a
+// fact was deleted.
+func (s *Store) DeleteFact(chatID string, id int64) (bool, error) {
+	result, err := s.exec("DELETE FROM facts WHERE id = ? AND (chat_id = ? OR chat_id = '')", id, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// FactsPrompt lists the newest facts visible in chatID for the system
+// prompt, or returns "" when there are none.
+func (s *Store) FactsPrompt(ctx context.Context, chatID string) (string, error) {
+	facts, err := s.Facts(chatID, "", "", maxPromptFacts)
+	if err != nil {
+		return "", err
+	}
+	if len(facts) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	for _, f := range facts {
+		sb.WriteString("- " + formatFact(f) + "\n")
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// Tags are stored as ",a,b," so a single tag can be matched with LIKE.
+func joinTags(tags []string) string {
+	var clean []string
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" {
+			clean = append(clean, t)
+		}
+	}
+	if len(clean) == 0 {
+		return ""
+	}
+	return "," + strings.Join(clean, ",") + ","
+}
+
+func splitTags(s string) []string {
+	s = strings.Trim(s, ",")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, ",", " ")))
+}
+
+func formatFact(f Fact) string {
+	s := fmt.Sprintf("#%d %s", f.ID, f.Content)
+	if len(f.Tags) > 0 {
+		s += " [" + strings.Join(f.Tags, ", ") + "]"
+	}
+	if f.ChatID == "" {
+		s += " (global)"
+	}
+	return s
+}
+
+type FactsTool struct {
+	store *Store
+
+	mu     sync.Mutex
+	chatID string
+}
+
+type factsArgs struct {
+	Action  string   `json:"action"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+	Scope   string   `json:"scope"`
+	Query   string   `json:"query"`
+	Tag     string   `json:"tag"`
+	ID      int64    `json:"id"`
+}
+
+func NewFactsTool(store *Store) *FactsTool {
+	return &FactsTool{store: store}
+}
+
+func (t *FactsTool) SetContext(chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chatID = chatID
+}
+
+func (t *FactsTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name: "memory",
+			Description: "Long-term memory for durable facts such as birthdays, schedules and preferences, kept beyond the conversation. " +
+				"Use remember when the user shares something worth keeping or asks you to remember it, and forget when it is no longer true.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"remember", "recall", "forget", "list", "pin", "stats", "vacuum", "redact"},
+						"description": "remember: store a fact; recall: search facts; forget: delete a fact by id; list: show facts; pin: keep the user's latest message in the conversation for good; stats: how much is remembered; vacuum: reclaim disk space, only when the operator asks; redact: delete stored messages containing query, or without query the user's previous message, e.g. a password sent by mistake",
+					},
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "The fact to remember, as a self-contained sentence",
+					},
+					"tags": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Optional tags for remember, e.g. family, school",
+					},
+					"scope": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"chat", "global"},
+						"description": "chat: only this chat (default); global: every chat",
+					},
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "Text to search for with recall, or to delete with redact",
+					},
+					"tag": map[string]interface{}{
+						"type":        "string",
+						"description": "Only recall or list facts with this tag",
+					},
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "Fact id for forget",
+					},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+func (t *FactsTool) Execute(argsJSON string) (string, error) {
+	var args factsArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("parse args: %w", err)
+	}
+
+	t.mu.Lock()
+	chatID := t.chatID
+	t.mu.Unlock()
+
+	switch args.Action {
+	case "remember":
+		content := strings.TrimSpace(args.Content)
+		if content == "" {
+			return "", fmt.Errorf("content is required for remember")
+		}
+		scope := chatID
+		if args.Scope == "global" {
+			scope = ""
+		}
+		id, err := t.store.AddFact(scope, content, args.Tags)
+		if err != nil {
+			return "", fmt.Errorf("add fact: %w", err)
+		}
+		return fmt.Sprintf("Remembered as #%d.", id), nil
+
+	case "recall", "list":
+		query := ""
+		if args.Action == "recall" {
+			query = strings.TrimSpace(args.Query)
+		}
+		facts, err := t.store.Facts(chatID, query, args.Tag, 0)
+		if err != nil {
+			return "", fmt.Errorf("get facts: %w", err)
+		}
+		if len(facts) == 0 {
+			return "No matching facts.", nil
+		}
+		var sb strings.Builder
+		for _, f := range facts {
+			sb.WriteString(formatFact(f) + "\n")
+		}
+		return strings.TrimRight(sb.String(), "\n"), nil
+
+	case "forget":
+		if args.ID <= 0 {
+			return "", fmt.Errorf("id is required for forget")
+		}
+		ok, err := t.store.DeleteFact(chatID, args.ID)
+		if err != nil {
+			return "", fmt.Errorf("delete fact: %w", err)
+		}
+		if !ok {
+			return fmt.Sprintf("No fact #%d in this chat.", args.ID), nil
+		}
+		return fmt.Sprintf("Forgot #%d.", args.ID), nil
+
+	case "pin":
+		m, found, err := t.store.PinLastMessage(chatID)
+		if err != nil {
+			return "", fmt.Errorf("pin message: %w", err)
+		}
+		if !found {
+			return "There is no message to pin.", nil
+		}
+		return fmt.Sprintf("Pinned: %s", m.Content), nil
+
+	case "stats":
+		st, err := t.store.Stats()
+		if err != nil {
+			return "", fmt.Errorf("get stats: %w", err)
+		}
+		counts, err := t.store.GetRoleCounts(chatID)
+		if err != nil {
+			return "", fmt.Errorf("count roles: %w", err)
+		}
+		return st.Summary(chatID) + fmt.Sprintf("\nIn this chat: %d from users, %d from the assistant, %d tool results",
+			counts["user"], counts["assistant"], counts["tool"]), nil
+
+	case "redact":
+		if query := strings.TrimSpace(args.Query); query != "" {
+			n, err := t.store.DeleteByContentMatch(chatID, query)
+			if err != nil {
+				return "", fmt.Errorf("delete messages: %w", err)
+			}
+			return fmt.Sprintf("Deleted %d messages.", n), nil
+		}
+		// The newest user message is the request to redact.
+		_, found, err := t.store.deleteUserMessage(chatID, 1)
+		if err != nil {
+			return "", fmt.Errorf("delete message: %w", err)
+		}
+		if !found {
+			return "There is no earlier message to delete.", nil
+		}
+		return "Deleted the previous message.", nil
+
+	case "vacuum":
+		reclaimed, err := t.store.Vacuum(context.Background())
+		if err != nil {
+			return "", fmt.Errorf("vacuum: %w", err)
+		}
+		return fmt.Sprintf("Reclaimed %s.", formatSize(reclaimed)), nil
+
+	default:
+		return "", fmt.Errorf("unknown action: %s", args.Action)
+	}
+}
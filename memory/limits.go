@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"tron"
+	"tron/util"
+)
+
+// Limits bounds the history kept for a chat. A zero field is inherited:
+// a chat's runtime setting wins over WithChatLimits, which wins over the
+// limits passed to NewStore.
+type Limits struct {
+	MaxMessages int
+	MaxMinutes  int
+}
+
+// WithChatLimits overrides the history limits for chat IDs or * patterns. An
+// exact chat ID wins over patterns; among patterns the longest match wins.
+func WithChatLimits(limits map[string]Limits) Option {
+	return func(s *Store) {
+		s.chatLimits = limits
+	}
+}
+
+// Limits returns the history limits in effect for chatID.
+func (s *Store) Limits(chatID string) Limits {
+	limits := Limits{MaxMessages: s.maxMessages, MaxMinutes: s.maxAgeMinutes}
+
+	configured, found := s.chatLimits[chatID]
+	if !found {
+		best := -1
+		for pattern, l := range s.chatLimits {
+			if util.GlobMatch(pattern, chatID) && len(pattern) > best {
+				best = len(pattern)
+				configured = l
+			}
+		}
+	}
+	limits = limits.override(configured)
+
+	s.settingsMu.RLock()
+	runtime := s.settings[chatID]
+	s.settingsMu.RUnlock()
+	return limits.override(runtime)
+}
+
+// ChatLimits returns the limits set for chatID at runtime, zero if none.
+func (s *Store) ChatLimits(chatID string) Limits {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings[chatID]
+}
+
+// SetChatLimits stores limits for chatID that override the configured ones
+// and survive restarts. Zero limits remove the override.
+func (s *Store) SetChatLimits(chatID string, limits Limits) error {
+	var err error
+	if limits == (Limits{}) {
+		_, err = s.exec("DELETE FROM chat_settings WHERE chat_id = ?", chatID)
+	} else {
+		_, err = s.exec(`
+			INSERT INTO chat_settings (chat_id, max_messages, max_minutes, updated_at) VALUES (?, ?, ?, {{now}})
+			ON CONFLICT(chat_id) DO UPDATE SET max_messages = excluded.max_messages, max_minutes = excluded.max_minutes, updated_at = excluded.updated_at
+		`, chatID, limits.MaxMessages, limits.MaxMinutes)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.settingsMu.Lock()
+	if limits == (Limits{}) {
+		delete(s.settings, chatID)
+	} else {
+		s.settings[chatID] = limits
+	}
+	s.settingsMu.Unlock()
+
+	return s.pruneOldMessages(chatID)
+}
+
+func (s *Store) loadChatSettings() error {
+	rows, err := s.query("SELECT chat_id, max_messages, max_minutes FROM chat_settings")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]Limits)
+	for rows.Next() {
+		var (
+			chatID string
+			l      Limits
+		)
+		if err := rows.Scan(&chatID, &l.MaxMessages, &l.MaxMinutes); err != nil {
+			return err
+		}
+		settings[chatID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.settingsMu.Lock()
+	s.settings = settings
+	s.settingsMu.Unlock()
+	return nil
+}
+
+// LimitsTool lets the model show and change how much history the current
+// chat keeps.
+type LimitsTool struct {
+	store *Store
+
+	mu     sync.Mutex
+	chatID string
+}
+
+type limitsArgs struct {
+	Action      string `json:"action"`
+	MaxMessages int    `json:"max_messages"`
+	MaxMinutes  int    `json:"max_minutes"`
+}
+
+func NewLimitsTool(store *Store) *LimitsTool {
+	return &LimitsTool{store: store}
+}
+
+func (t *LimitsTool) SetContext(chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chatID = chatID
+}
+
+func (t *LimitsTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name:        "memory_settings",
+			Description: "Show or change how many messages and how many minutes of conversation this chat remembers. Only use it when the user asks.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"show", "set", "reset"},
+						"description": "show: current limits; set: change them for this chat; reset: back to the configured defaults",
+					},
+					"max_messages": map[string]interface{}{
+						"type":        "integer",
+						"description": "Messages to remember, for set (0 keeps the current value)",
+					},
+					"max_minutes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Minutes to remember messages for, for set (0 keeps the current value)",
+					},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+func (t *LimitsTool) Execute(argsJSON string) (string, error) {
+	var args limitsArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("parse args: %w", err)
+	}
+
+	t.mu.Lock()
+	chatID := t.chatID
+	t.mu.Unlock()
+
+	switch args.Action {
+	case "show":
+	case "set":
+		if args.MaxMessages < 0 || args.MaxMinutes < 0 {
+			return "", fmt.Errorf("limits must not be negative")
+		}
+		if args.MaxMessages == 0 && args.MaxMinutes == 0 {
+			return "", fmt.Errorf("max_messages or max_minutes is required for set")
+		}
+		limits := t.store.ChatLimits(chatID).override(Limits{MaxMessages: args.MaxMessages, MaxMinutes: args.MaxMinutes})
+		if err := t.store.SetChatLimits(chatID, limits); err != nil {
+			return "", fmt.Errorf("set limits: %w", err)
+		}
+	case "reset":
+		if err := t.store.SetChatLimits(chatID, Limits{}); err != nil {
+			return "", fmt.Errorf("reset limits: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unknown action: %s", args.Action)
+	}
+
+	limits := t.store.Limits(chatID)
+	return fmt.Sprintf("This chat remembers the last %d messages from the past %d minutes.", limits.MaxMessages, limits.MaxMinutes), nil
+}
+
+func (l Limits) override(o Limits) Limits {
+	if o.MaxMessages > 0 {
+		l.MaxMessages = o.MaxMessages
+	}
+	if o.MaxMinutes > 0 {
+		l.MaxMinutes = o.MaxMinutes
+	}
+	return l
+}
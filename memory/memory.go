@@ -2,9 +2,15 @@ package memory
 
 import (
 	"context"
+	"crypto/cipher"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -16,52 +22,107 @@ type Store struct {
 	db            *sql.DB
 	maxMessages   int
 	maxAgeMinutes int
+	chatLimits    map[string]Limits
+	settingsMu    sync.RWMutex
+	settings      map[string]Limits
+	driver        string
+	dialect       dialect
 	cancel        context.CancelFunc
+
+	encryptionKey []byte
+	aead          cipher.AEAD
+
+	vacuumInterval time.Duration
+	vacuumMu       sync.Mutex
+	// lastWrite is when a message was last stored, in Unix nanoseconds.
+	lastWrite atomic.Int64
 }
 
-func NewStore(dbPath string, maxMessages, maxAgeMinutes int) (*Store, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
-	}
+type Option func(*Store)
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ping db: %w", err)
+// WithDriver selects the database/sql driver, "sqlite3" (default) or
+// "postgres". For postgres, dbPath is the connection string and the binary
+// must be built with -tags postgres.
+func WithDriver(driver string) Option {
+	return func(s *Store) {
+		s.driver = driver
 	}
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
+func NewStore(dbPath string, maxMessages, maxAgeMinutes int, opts ...Option) (*Store, error) {
 	s := &Store{
-		db:            db,
 		maxMessages:   maxMessages,
 		maxAgeMinutes: maxAgeMinutes,
-		cancel:        cancel,
+		driver:        "sqlite3",
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	d, err := dialectFor(s.driver)
+	if err != nil {
+		return nil, err
+	}
+	s.dialect = d
+	if s.encryptionKey != nil {
+		if s.aead, err = newAEAD(s.encryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
+	dsn := dbPath
+	if s.driver == "sqlite3" {
+		dsn = sqliteDSN(dbPath)
+	}
+	db, err := sql.Open(s.driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
 	}
 
+	s.db = db
 	if err := s.migrate(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
+	if err := s.loadChatSettings(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("load chat settings: %w", err)
+	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
 	go s.cleanupLoop(ctx)
 
 	return s, nil
 }
 
-func (s *Store) migrate() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			chat_id TEXT NOT NULL,
-			role TEXT NOT NULL,
-			content TEXT NOT NULL,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-			expires_at DATETIME
-		);
-		CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
-		CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
-		CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at);
-	`)
-	return err
+// sqliteDSN adds the connection settings every SQLite handle uses: WAL so
+// readers do not block the writer, a busy timeout instead of immediate
+// "database is locked" errors, foreign keys, and transactions that take the
+// write lock when they begin. Settings already in path take precedence.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on&_txlock=immediate"
+}
+
+func (s *Store) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *Store) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.rebind(query), args...)
+}
+
+func (s *Store) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.dialect.rebind(query), args...)
 }
 
 func (s *Store) cleanupLoop(ctx context.Context) {
@@ -76,12 +137,16 @@ func (s *Store) cleanupLoop(ctx context.Context) {
 			if err := s.deleteExpiredMessages(); err != nil {
 				log.Printf("[memory] cleanup error: %v", err)
 			}
+			if err := s.pruneAllChats(); err != nil {
+				log.Printf("[memory] cleanup error: %v", err)
+			}
+			s.maybeVacuum(ctx)
 		}
 	}
 }
 
 func (s *Store) deleteExpiredMessages() error {
-	result, err := s.db.Exec("DELETE FROM messages WHERE expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP")
+	result, err := s.exec("DELETE FROM messages WHERE expires_at IS NOT NULL AND expires_at <= {{now}} AND pinned = 0")
 	if err != nil {
 		return err
 	}
@@ -93,6 +158,35 @@ func (s *Store) deleteExpiredMessages() error {
 	return nil
 }
 
+// pruneAllChats applies each chat's limits, so history also ages out of
+// chats that receive no new messages.
+func (s *Store) pruneAllChats() error {
+	rows, err := s.query("SELECT DISTINCT chat_id FROM messages")
+	if err != nil {
+		return err
+	}
+	var chatIDs []string
+	for rows.Next() {
+		var chatID string
+		if err := rows.Scan(&chatID); err != nil {
+			rows.Close()
+			return err
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, chatID := range chatIDs {
+		if err := s.pruneOldMessages(chatID); err != nil {
+			return fmt.Errorf("chat %s: %w", chatID, err)
+		}
+	}
+	return nil
+}
+
 func (s *Store) AddMessage(chatID, role, content string, expiresInSeconds int) error {
 	var expiresAt sql.NullTime
 	if expiresInSeconds > 0 {
@@ -102,9 +196,18 @@ func (s *Store) AddMessage(chatID, role, content string, expiresInSeconds int) e
 		}
 	}
 
-	_, err := s.db.Exec(
-		"INSERT INTO messages (chat_id, role, content, expires_at) VALUES (?, ?, ?, ?)",
-		chatID, role, content, expiresAt,
+	s.lastWrite.Store(time.Now().UnixNano())
+	sentAt, err := fallbackTimestamp(s.queryRow(newestMessageQuery, chatID))
+	if err != nil {
+		return err
+	}
+	content, err = s.seal(content)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(
+		"INSERT INTO messages (chat_id, role, content, expires_at, signal_timestamp) VALUES (?, ?, ?, ?, ?)",
+		chatID, role, content, expiresAt, sentAt,
 	)
 	if err != nil {
 		return err
@@ -113,55 +216,226 @@ func (s *Store) AddMessage(chatID, role, content string, expiresInSeconds int) e
 	return s.pruneOldMessages(chatID)
 }
 
+const newestMessageQuery = "SELECT signal_timestamp FROM messages WHERE chat_id = ? ORDER BY signal_timestamp DESC LIMIT 1"
+
+// fallbackTimestamp returns the time to store for a message that has no
+// Signal timestamp, such as a reply: now, but never before the chat's newest
+// message, so replies sort after their question even when the sender's
+// clock is ahead. row is the result of newestMessageQuery.
+func fallbackTimestamp(row *sql.Row) (time.Time, error) {
+	now := time.Now().UTC()
+	var newest sql.NullTime
+	if err := row.Scan(&newest); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return now, err
+	}
+	if newest.Valid && newest.Time.After(now) {
+		return newest.Time.UTC(), nil
+	}
+	return now, nil
+}
+
+// GetHistory returns the pinned messages of chatID followed by the newest
+// other messages within its limits, each oldest first. Pinned messages do
+// not count against the limits.
 func (s *Store) GetHistory(chatID string) ([]tron.Message, error) {
-	cutoff := time.Now().Add(-time.Duration(s.maxAgeMinutes) * time.Minute)
+	pinned, err := s.pinnedMessages(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := s.Limits(chatID)
+	cutoff := time.Now().Add(-time.Duration(limits.MaxMinutes) * time.Minute)
+
+	rows, err := s.query(`
+		SELECT `+messageColumns+` FROM (
+			SELECT `+messageColumns+`
+			FROM messages
+			WHERE chat_id = ?
+			  AND pinned = 0
+			  AND signal_timestamp > ?
+			  AND (expires_at IS NULL OR expires_at > {{now}})
+			ORDER BY signal_timestamp DESC, id DESC
+			LIMIT ?
+		) AS recent
+		ORDER BY signal_timestamp ASC, id ASC
+	`, chatID, cutoff.UTC(), limits.MaxMessages)
+	if err != nil {
+		return nil, err
+	}
+	recent, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	return append(pinned, recent...), nil
+}
+
+// GetHistoryRange returns the messages of chatID sent in [from, to), oldest
+// first. With limit > 0 only the newest limit messages are returned. Only
+// messages still within the retention window (memory_max_minutes) exist.
+func (s *Store) GetHistoryRange(chatID string, from, to time.Time, limit int) ([]tron.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE chat_id = ?
+		  AND signal_timestamp >= ?
+		  AND signal_timestamp < ?
+		  AND (expires_at IS NULL OR expires_at > {{now}})`
+	args := []interface{}{chatID, from.UTC(), to.UTC()}
+	if limit > 0 {
+		query += " ORDER BY signal_timestamp DESC, id DESC LIMIT ?"
+		args = append(args, limit)
+	}
+	query = "SELECT " + messageColumns + " FROM (" + query + ") AS recent ORDER BY signal_timestamp ASC, id ASC"
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessages(rows)
+}
+
+// GetHistoryByRole returns the newest limit messages of chatID with role,
+// oldest first; limit <= 0 returns all. Unlike GetHistory, tool calls and
+// results are not paired up.
+func (s *Store) GetHistoryByRole(chatID, role string, limit int) ([]tron.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE chat_id = ?
+		  AND role = ?
+		  AND (expires_at IS NULL OR expires_at > {{now}})
+		ORDER BY signal_timestamp DESC, id DESC`
+	args := []interface{}{chatID, role}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	query = "SELECT " + messageColumns + " FROM (" + query + ") AS recent ORDER BY signal_timestamp ASC, id ASC"
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.scanMessageRows(rows)
+}
 
-	rows, err := s.db.Query(`
-		SELECT role, content
+// GetRoleCounts returns how many messages of each role chatID has.
+func (s *Store) GetRoleCounts(chatID string) (map[string]int, error) {
+	rows, err := s.query(`
+		SELECT role, COUNT(*)
 		FROM messages
 		WHERE chat_id = ?
-		  AND timestamp > ?
-		  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
-		ORDER BY timestamp ASC
-		LIMIT ?
-	`, chatID, cutoff, s.maxMessages)
+		  AND (expires_at IS NULL OR expires_at > {{now}})
+		GROUP BY role
+	`, chatID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			role string
+			n    int
+		)
+		if err := rows.Scan(&role, &n); err != nil {
+			return nil, err
+		}
+		counts[role] = n
+	}
+	return counts, rows.Err()
+}
+
+const messageColumns = "id, role, content, signal_timestamp, tool_calls, tool_call_id, sender"
+
+func (s *Store) scanMessages(rows *sql.Rows) ([]tron.Message, error) {
+	messages, err := s.scanMessageRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return pairToolMessages(messages), nil
+}
+
+func (s *Store) scanMessageRows(rows *sql.Rows) ([]tron.Message, error) {
+	defer rows.Close()
+
 	var messages []tron.Message
 	for rows.Next() {
-		var m tron.Message
-		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+		var (
+			m          tron.Message
+			toolCalls  sql.NullString
+			toolCallID sql.NullString
+			sender     sql.NullString
+		)
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Timestamp, &toolCalls, &toolCallID, &sender); err != nil {
 			return nil, err
 		}
+		if toolCalls.String != "" {
+			data, err := s.open(toolCalls.String)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(data), &m.ToolCalls); err != nil {
+				return nil, fmt.Errorf("decode tool calls: %w", err)
+			}
+		}
+		m.ToolCallID = toolCallID.String
+		m.Sender = sender.String
+		content, err := s.open(m.Content)
+		if err != nil {
+			return nil, err
+		}
+		m.Content = content
 		messages = append(messages, m)
 	}
-
 	return messages, rows.Err()
 }
 
 func (s *Store) pruneOldMessages(chatID string) error {
-	cutoff := time.Now().Add(-time.Duration(s.maxAgeMinutes) * time.Minute)
-	_, err := s.db.Exec(
-		"DELETE FROM messages WHERE chat_id = ? AND timestamp < ?",
-		chatID, cutoff,
+	limits := s.Limits(chatID)
+	cutoff := time.Now().Add(-time.Duration(limits.MaxMinutes) * time.Minute)
+	_, err := s.exec(
+		"DELETE FROM messages WHERE chat_id = ? AND signal_timestamp < ? AND pinned = 0",
+		chatID, cutoff.UTC(),
 	)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(`
-		DELETE FROM messages WHERE chat_id = ? AND id NOT IN (
-			SELECT id FROM messages WHERE chat_id = ? ORDER BY timestamp DESC LIMIT ?
+	_, err = s.exec(`
+		DELETE FROM messages WHERE chat_id = ? AND pinned = 0 AND id NOT IN (
+			SELECT id FROM messages WHERE chat_id = ? AND pinned = 0 ORDER BY signal_timestamp DESC, id DESC LIMIT ?
 		)
-	`, chatID, chatID, s.maxMessages)
+	`, chatID, chatID, limits.MaxMessages)
 	return err
 }
 
 func (s *Store) ClearHistory(chatID string) error {
-	_, err := s.db.Exec("DELETE FROM messages WHERE chat_id = ?", chatID)
+	if _, err := s.exec("DELETE FROM messages WHERE chat_id = ?", chatID); err != nil {
+		return err
+	}
+	if _, err := s.exec("DELETE FROM conversation_summaries WHERE chat_id = ?", chatID); err != nil {
+		return err
+	}
+	_, err := s.exec("DELETE FROM message_vectors WHERE chat_id = ?", chatID)
+	return err
+}
+
+func (s *Store) GetState(key string) (string, error) {
+	var value string
+	err := s.queryRow("SELECT value FROM bot_state WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (s *Store) SetState(key, value string) error {
+	_, err := s.exec(`
+		INSERT INTO bot_state (key, value, updated_at) VALUES (?, ?, {{now}})
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`, key, value)
 	return err
 }
 
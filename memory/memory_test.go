@@ -0,0 +1,124 @@
+package memory
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"tron"
+)
+
+func newTestStore(t *testing.T, maxMessages int, opts ...Option) *Store {
+	t.Helper()
+	s, err := NewStore(filepath.Join(t.TempDir(), "tron.db"), maxMessages, 60, opts...)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestGetHistoryReturnsNewest(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxMessages int
+		inserts     int
+		want        []string
+	}{
+		{"more than the limit", 3, 5, []string{"message 3", "message 4", "message 5"}},
+		{"exactly the limit", 3, 3, []string{"message 1", "message 2", "message 3"}},
+		{"fewer than the limit", 3, 2, []string{"message 1", "message 2"}},
+		{"empty", 3, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, tt.maxMessages)
+			for i := 1; i <= tt.inserts; i++ {
+				if err := s.AddMessage("dm:+100", "user", fmt.Sprintf("message %d", i), 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			history, err := s.GetHistory("dm:+100")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range history {
+				got = append(got, m.Content)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetHistory = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	s := newTestStore(t, 50)
+
+	const (
+		workers    = 8
+		iterations = 50
+	)
+	errs := make(chan error, workers*4)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		chatID := fmt.Sprintf("dm:+%d", w)
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := s.AddMessage(chatID, "user", fmt.Sprintf("message %d", i), 0); err != nil {
+					errs <- fmt.Errorf("AddMessage: %w", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if _, err := s.GetHistory(chatID); err != nil {
+					errs <- fmt.Errorf("GetHistory: %w", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := s.RecordUsage(chatID, tron.Usage{PromptTokens: 1, CompletionTokens: 1}); err != nil {
+					errs <- fmt.Errorf("RecordUsage: %w", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := s.SetState("key", chatID); err != nil {
+					errs <- fmt.Errorf("SetState: %w", err)
+					return
+				}
+				if err := s.deleteExpiredMessages(); err != nil {
+					errs <- fmt.Errorf("deleteExpiredMessages: %w", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	history, err := s.GetHistory("dm:+0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != iterations {
+		t.Errorf("got %d messages, want %d", len(history), iterations)
+	}
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations are applied in order and recorded in schema_migrations. Never
+// edit or renumber an existing entry; append a new one instead. Databases
+// created before versioning already contain some of these objects, so each
+// step must tolerate that.
+func (s *Store) migrations() []migration {
+	execMigration := func(schema string) func(tx *sql.Tx) error {
+		return func(tx *sql.Tx) error {
+			_, err := tx.Exec(s.dialect.ddl(schema))
+			return err
+		}
+	}
+
+	return []migration{
+		{1, "initial schema", execMigration(`
+			CREATE TABLE IF NOT EXISTS messages (
+				id {{autoincrement}},
+				chat_id TEXT NOT NULL,
+				role TEXT NOT NULL,
+				content TEXT NOT NULL,
+				timestamp {{timestamp}} DEFAULT {{now}}
+			);
+			CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+			CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);
+		`)},
+		{2, "messages.expires_at", func(tx *sql.Tx) error {
+			if err := addColumn(tx, s.dialect, "messages", "expires_at", "{{timestamp}}"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at)")
+			return err
+		}},
+		{3, "usage", execMigration(`
+			CREATE TABLE IF NOT EXISTS usage (
+				chat_id TEXT NOT NULL,
+				date TEXT NOT NULL,
+				prompt_tokens INTEGER NOT NULL DEFAULT 0,
+				completion_tokens INTEGER NOT NULL DEFAULT 0,
+				requests INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (chat_id, date)
+			);
+		`)},
+		{4, "audit_log", execMigration(`
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id {{autoincrement}},
+				chat_id TEXT NOT NULL,
+				message_length INTEGER NOT NULL,
+				response_length INTEGER NOT NULL,
+				duration_ms INTEGER NOT NULL,
+				error TEXT NOT NULL DEFAULT '',
+				timestamp {{timestamp}} DEFAULT {{now}}
+			);
+		`)},
+		{5, "bot_state", execMigration(`
+			CREATE TABLE IF NOT EXISTS bot_state (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL,
+				updated_at {{timestamp}} DEFAULT {{now}}
+			);
+		`)},
+		{6, "scheduler_state", execMigration(`
+			CREATE TABLE IF NOT EXISTS scheduler_state (
+				name TEXT PRIMARY KEY,
+				last_sent {{timestamp}} NOT NULL
+			);
+		`)},
+		{7, "messages tool calls", func(tx *sql.Tx) error {
+			if err := addColumn(tx, s.dialect, "messages", "tool_calls", "TEXT"); err != nil {
+				return err
+			}
+			return addColumn(tx, s.dialect, "messages", "tool_call_id", "TEXT")
+		}},
+		{8, "messages.sender", func(tx *sql.Tx) error {
+			return addColumn(tx, s.dialect, "messages", "sender", "TEXT")
+		}},
+		{9, "conversation_summaries", execMigration(`
+			CREATE TABLE IF NOT EXISTS conversation_summaries (
+				chat_id TEXT PRIMARY KEY,
+				summary TEXT NOT NULL,
+				through_id INTEGER NOT NULL,
+				updated_at {{timestamp}} DEFAULT {{now}}
+			);
+		`)},
+		{10, "facts", execMigration(`
+			CREATE TABLE IF NOT EXISTS facts (
+				id {{autoincrement}},
+				chat_id TEXT NOT NULL DEFAULT '',
+				content TEXT NOT NULL,
+				tags TEXT NOT NULL DEFAULT '',
+				created_at {{timestamp}} DEFAULT {{now}}
+			);
+			CREATE INDEX IF NOT EXISTS idx_facts_chat_id ON facts(chat_id);
+		`)},
+		{11, "message_vectors", execMigration(`
+			CREATE TABLE IF NOT EXISTS message_vectors (
+				id {{autoincrement}},
+				chat_id TEXT NOT NULL,
+				role TEXT NOT NULL,
+				content TEXT NOT NULL,
+				vector {{blob}} NOT NULL,
+				created_at {{timestamp}} DEFAULT {{now}}
+			);
+			CREATE INDEX IF NOT EXISTS idx_message_vectors_chat_id ON message_vectors(chat_id);
+		`)},
+		{12, "chat_settings", execMigration(`
+			CREATE TABLE IF NOT EXISTS chat_settings (
+				chat_id TEXT PRIMARY KEY,
+				max_messages INTEGER NOT NULL DEFAULT 0,
+				max_minutes INTEGER NOT NULL DEFAULT 0,
+				updated_at {{timestamp}} DEFAULT {{now}}
+			);
+		`)},
+		{13, "messages.signal_timestamp", func(tx *sql.Tx) error {
+			if err := addColumn(tx, s.dialect, "messages", "signal_timestamp", "{{timestamp}}"); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("UPDATE messages SET signal_timestamp = timestamp WHERE signal_timestamp IS NULL"); err != nil {
+				return err
+			}
+			_, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_messages_chat_signal_timestamp ON messages(chat_id, signal_timestamp)")
+			return err
+		}},
+		{14, "messages.pinned", func(tx *sql.Tx) error {
+			return addColumn(tx, s.dialect, "messages", "pinned", "INTEGER NOT NULL DEFAULT 0")
+		}},
+		{15, "chat_config", execMigration(`
+			CREATE TABLE IF NOT EXISTS chat_config (
+				chat_id TEXT PRIMARY KEY,
+				system_prompt TEXT NOT NULL DEFAULT '',
+				updated_at {{timestamp}} DEFAULT {{now}}
+			);
+		`)},
+	}
+}
+
+func (s *Store) migrate() error {
+	if _, err := s.db.Exec(s.dialect.ddl(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at {{timestamp}} DEFAULT {{now}}
+		)
+	`)); err != nil {
+		return err
+	}
+
+	applied, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range s.migrations() {
+		if applied[m.version] {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		log.Printf("[memory] applied migration %d: %s", m.version, m.name)
+	}
+
+	return nil
+}
+
+func (s *Store) appliedMigrations() (map[int]bool, error) {
+	rows, err := s.db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+func (s *Store) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.dialect.rebind("INSERT INTO schema_migrations (version) VALUES (?)"), m.version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func addColumn(tx *sql.Tx, d dialect, table, column, definition string) error {
+	exists, err := d.hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(d.ddl(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)))
+	return err
+}
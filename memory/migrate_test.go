@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"tron"
+)
+
+// openRaw opens the SQLite file at path without migrating it.
+func openRaw(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", sqliteDSN(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func appliedVersions(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func latestVersion() int {
+	m := (&Store{dialect: sqliteDialect{}}).migrations()
+	return m[len(m)-1].version
+}
+
+func checkFullyMigrated(t *testing.T, db *sql.DB) {
+	t.Helper()
+	versions := appliedVersions(t, db)
+	if len(versions) != latestVersion() {
+		t.Fatalf("applied migrations %v, want 1..%d", versions, latestVersion())
+	}
+	for i, v := range versions {
+		if v != i+1 {
+			t.Fatalf("applied migrations %v, want 1..%d", versions, latestVersion())
+		}
+	}
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	s := newTestStore(t, 10)
+	if err := s.AddMessage("dm:+100", "user", "hello", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.migrate(); err != nil {
+			t.Fatalf("migrate run %d: %v", i+2, err)
+		}
+	}
+	checkFullyMigrated(t, s.db)
+
+	history, err := s.GetHistory("dm:+100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Content != "hello" {
+		t.Errorf("history after migrating again = %+v", history)
+	}
+}
+
+func TestMigrateUpgradesV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tron.db")
+	db := openRaw(t, path)
+
+	v1 := (&Store{dialect: sqliteDialect{}}).migrations()[0]
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.up(tx); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, applied_at DATETIME DEFAULT CURRENT_TIMESTAMP)",
+		"INSERT INTO schema_migrations (version) VALUES (1)",
+		"INSERT INTO messages (chat_id, role, content) VALUES ('dm:+100', 'user', 'from v1')",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewStore(path, 10, 60)
+	if err != nil {
+		t.Fatalf("NewStore on a v1 database: %v", err)
+	}
+	defer s.Close()
+
+	checkFullyMigrated(t, s.db)
+	history, err := s.GetHistory("dm:+100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Content != "from v1" {
+		t.Errorf("history after upgrade = %+v, want the v1 message", history)
+	}
+}
+
+func TestMigrateExistingDatabases(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, path string)
+		// existing reports whether setup stored data that must survive.
+		existing bool
+	}{{
+		name:  "empty database",
+		setup: func(t *testing.T, path string) {},
+	}, {
+		// Before schema_migrations the tables were created with IF NOT EXISTS
+		// and columns added by blind ALTER TABLEs, so an old database has
+		// some of the later objects but no record of them.
+		name:     "created before versioning",
+		existing: true,
+		setup: func(t *testing.T, path string) {
+			db := openRaw(t, path)
+			for _, stmt := range []string{
+				`CREATE TABLE messages (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					chat_id TEXT NOT NULL,
+					role TEXT NOT NULL,
+					content TEXT NOT NULL,
+					timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+					expires_at DATETIME,
+					tool_calls TEXT
+				)`,
+				`CREATE INDEX idx_messages_chat_id ON messages(chat_id)`,
+				`CREATE TABLE bot_state (key TEXT PRIMARY KEY, value TEXT NOT NULL, updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+				`INSERT INTO messages (chat_id, role, content) VALUES ('dm:+100', 'user', 'kept')`,
+				`INSERT INTO bot_state (key, value) VALUES ('trigger_keyword', 'tron')`,
+			} {
+				if _, err := db.Exec(stmt); err != nil {
+					t.Fatalf("%s: %v", stmt, err)
+				}
+			}
+		},
+	}, {
+		name:     "created by the current code",
+		existing: true,
+		setup: func(t *testing.T, path string) {
+			s, err := NewStore(path, 10, 60)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if err := s.AddMessage("dm:+100", "user", "kept", 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetState("trigger_keyword", "tron"); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tron.db")
+			tt.setup(t, path)
+
+			s, err := NewStore(path, 10, 60)
+			if err != nil {
+				t.Fatalf("NewStore: %v", err)
+			}
+			defer s.Close()
+			checkFullyMigrated(t, s.db)
+
+			// Every table and column of the latest schema is usable.
+			if err := s.AddMessages("group:abc", []tron.Message{{Role: "user", Content: "hi", Sender: "Alice"}}, 60); err != nil {
+				t.Fatalf("AddMessages: %v", err)
+			}
+			if _, _, err := s.PinLastMessage("group:abc"); err != nil {
+				t.Fatalf("PinLastMessage: %v", err)
+			}
+			if err := s.SetChatSystemPrompt("group:abc", "Be brief."); err != nil {
+				t.Fatalf("SetChatSystemPrompt: %v", err)
+			}
+			if err := s.RecordUsage("group:abc", tron.Usage{PromptTokens: 1}); err != nil {
+				t.Fatalf("RecordUsage: %v", err)
+			}
+
+			if !tt.existing {
+				return
+			}
+			history, err := s.GetHistory("dm:+100")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) != 1 || history[0].Content != "kept" {
+				t.Errorf("history = %+v, want the existing message", history)
+			}
+			if v, err := s.GetState("trigger_keyword"); err != nil || v != "tron" {
+				t.Errorf("GetState = %q, %v, want the existing value", v, err)
+			}
+		})
+	}
+}
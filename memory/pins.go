@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+
+	"tron"
+)
+
+// PinLastMessage pins the newest user or assistant message of chatID, so it
+// is neither pruned nor expired and GetHistory always returns it. It returns
+// the pinned message, and false if there is nothing left to pin.
+func (s *Store) PinLastMessage(chatID string) (tron.Message, bool, error) {
+	var m tron.Message
+	err := s.queryRow(`
+		SELECT id, role, content, signal_timestamp
+		FROM messages
+		WHERE chat_id = ?
+		  AND pinned = 0
+		  AND role IN ('user', 'assistant')
+		  AND tool_calls IS NULL
+		  AND content <> ''
+		ORDER BY signal_timestamp DESC, id DESC
+		LIMIT 1
+	`, chatID).Scan(&m.ID, &m.Role, &m.Content, &m.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, err
+	}
+	if m.Content, err = s.open(m.Content); err != nil {
+		return m, false, err
+	}
+
+	if _, err := s.exec("UPDATE messages SET pinned = 1 WHERE id = ?", m.ID); err != nil {
+		return m, false, err
+	}
+	m.Pinned = true
+	return m, true, nil
+}
+
+// UnpinMessages unpins all messages of chatID and returns how many were
+// pinned. They are pruned again like any other message.
+func (s *Store) UnpinMessages(chatID string) (int, error) {
+	result, err := s.exec("UPDATE messages SET pinned = 0 WHERE chat_id = ? AND pinned = 1", chatID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+	if err := s.pruneOldMessages(chatID); err != nil {
+		return int(n), err
+	}
+	return int(n), nil
+}
+
+func (s *Store) pinnedMessages(chatID string) ([]tron.Message, error) {
+	rows, err := s.query(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE chat_id = ? AND pinned = 1
+		ORDER BY signal_timestamp ASC, id ASC
+	`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := s.scanMessages(rows)
+	for i := range messages {
+		messages[i].Pinned = true
+	}
+	return messages, err
+}
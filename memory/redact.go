@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"tron"
+)
+
+// maxRedactMatches caps how many messages DeleteByContentMatch removes, so
+// a short search text cannot wipe a whole chat.
+const maxRedactMatches = 20
+
+// DeleteLastUserMessage deletes the newest user message of chatID and its
+// archived copy, and returns it; false if the chat has none.
+func (s *Store) DeleteLastUserMessage(chatID string) (tron.Message, bool, error) {
+	return s.deleteUserMessage(chatID, 0)
+}
+
+// deleteUserMessage deletes the user message of chatID that has skip newer
+// user messages after it.
+func (s *Store) deleteUserMessage(chatID string, skip int) (tron.Message, bool, error) {
+	var m tron.Message
+	err := s.queryRow(`
+		SELECT id, role, content
+		FROM messages
+		WHERE chat_id = ? AND role = 'user'
+		ORDER BY signal_timestamp DESC, id DESC
+		LIMIT 1 OFFSET ?
+	`, chatID, skip).Scan(&m.ID, &m.Role, &m.Content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, err
+	}
+	if m.Content, err = s.open(m.Content); err != nil {
+		return m, false, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return m, false, err
+	}
+	defer tx.Rollback()
+
+	if err := s.redact(tx, chatID, []int64{m.ID}); err != nil {
+		return m, false, err
+	}
+	archived, err := s.matchingRows(tx, "SELECT id, content FROM message_vectors WHERE chat_id = ? AND role = 'user'", chatID,
+		func(content string) bool { return content == m.Content })
+	if err != nil {
+		return m, false, err
+	}
+	if err := deleteRows(tx, s.dialect.rebind("DELETE FROM message_vectors WHERE id = ?"), archived); err != nil {
+		return m, false, err
+	}
+	return m, true, tx.Commit()
+}
+
+// DeleteByContentMatch deletes the messages of chatID, of any role, whose
+// content contains text (ignoring case), and archived messages that do. It
+// refuses to delete more than maxRedactMatches messages. Content is matched
+// after decryption, so the chat's messages are read one by one.
+func (s *Store) DeleteByContentMatch(chatID, text string) (int, error) {
+	text = strings.ToLower(text)
+	if strings.TrimSpace(text) == "" {
+		return 0, fmt.Errorf("empty search text")
+	}
+	contains := func(content string) bool { return strings.Contains(strings.ToLower(content), text) }
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := s.matchingRows(tx, "SELECT id, content FROM messages WHERE chat_id = ?", chatID, contains)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > maxRedactMatches {
+		return 0, fmt.Errorf("%d messages match, more than %d; use a longer search text", len(ids), maxRedactMatches)
+	}
+	if err := s.redact(tx, chatID, ids); err != nil {
+		return 0, err
+	}
+
+	archived, err := s.matchingRows(tx, "SELECT id, content FROM message_vectors WHERE chat_id = ?", chatID, contains)
+	if err != nil {
+		return 0, err
+	}
+	if err := deleteRows(tx, s.dialect.rebind("DELETE FROM message_vectors WHERE id = ?"), archived); err != nil {
+		return 0, err
+	}
+	return len(ids), tx.Commit()
+}
+
+// matchingRows runs query, which selects id and content for chatID, and
+// returns the ids whose decrypted content satisfies match.
+func (s *Store) matchingRows(tx *sql.Tx, query, chatID string, match func(content string) bool) ([]int64, error) {
+	rows, err := tx.Query(s.dialect.rebind(query), chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var (
+			id      int64
+			content string
+		)
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, err
+		}
+		if content, err = s.open(content); err != nil {
+			return nil, err
+		}
+		if match(content) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+func deleteRows(tx *sql.Tx, stmt string, ids []int64) error {
+	for _, id := range ids {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redact deletes the messages ids of chatID. The chat's rolling summary is
+// dropped too if it covers any of them, as it may repeat what they said.
+func (s *Store) redact(tx *sql.Tx, chatID string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var throughID int64
+	err := tx.QueryRow(s.dialect.rebind("SELECT through_id FROM conversation_summaries WHERE chat_id = ?"), chatID).Scan(&throughID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if err := deleteRows(tx, s.dialect.rebind("DELETE FROM messages WHERE id = ?"), ids); err != nil {
+		return err
+	}
+	covered := false
+	for _, id := range ids {
+		covered = covered || id <= throughID
+	}
+
+	if !covered {
+		return nil
+	}
+	_, err = tx.Exec(s.dialect.rebind("DELETE FROM conversation_summaries WHERE chat_id = ?"), chatID)
+	return err
+}
@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LastSent returns when the named schedule last ran, or the zero time.
+func (s *Store) LastSent(name string) (time.Time, error) {
+	var t time.Time
+	err := s.queryRow("SELECT last_sent FROM scheduler_state WHERE name = ?", name).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+func (s *Store) SetLastSent(name string, t time.Time) error {
+	_, err := s.exec(`
+		INSERT INTO scheduler_state (name, last_sent) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET last_sent = excluded.last_sent
+	`, name, t.UTC())
+	return err
+}
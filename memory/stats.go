@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ChatStats describes the history kept for one chat.
+type ChatStats struct {
+	ChatID   string
+	Messages int
+	Pinned   int
+	Oldest   time.Time
+	Newest   time.Time
+}
+
+// Stats describes what the store holds. SizeBytes is the size of the
+// database, 0 if it cannot be determined.
+type Stats struct {
+	Chats     []ChatStats
+	Messages  int
+	Facts     int
+	Archived  int
+	Summaries int
+	SizeBytes int64
+}
+
+// Stats counts the stored messages per chat, the facts, archived messages
+// and conversation summaries, and measures the database.
+func (s *Store) Stats() (Stats, error) {
+	var st Stats
+
+	rows, err := s.query(`
+		SELECT chat_id, COUNT(*), SUM(pinned), MIN(signal_timestamp), MAX(signal_timestamp)
+		FROM messages
+		GROUP BY chat_id
+		ORDER BY chat_id
+	`)
+	if err != nil {
+		return st, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			c              ChatStats
+			oldest, newest interface{}
+		)
+		if err := rows.Scan(&c.ChatID, &c.Messages, &c.Pinned, &oldest, &newest); err != nil {
+			return st, err
+		}
+		c.Oldest, c.Newest = parseDBTime(oldest), parseDBTime(newest)
+		st.Chats = append(st.Chats, c)
+		st.Messages += c.Messages
+	}
+	if err := rows.Err(); err != nil {
+		return st, err
+	}
+
+	err = s.queryRow(`
+		SELECT (SELECT COUNT(*) FROM facts),
+		       (SELECT COUNT(*) FROM message_vectors),
+		       (SELECT COUNT(*) FROM conversation_summaries)
+	`).Scan(&st.Facts, &st.Archived, &st.Summaries)
+	if err != nil {
+		return st, err
+	}
+
+	sizeQuery := "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()"
+	if s.driver == "postgres" {
+		sizeQuery = "SELECT pg_database_size(current_database())"
+	}
+	if err := s.queryRow(sizeQuery).Scan(&st.SizeBytes); err != nil {
+		return st, fmt.Errorf("database size: %w", err)
+	}
+
+	return st, nil
+}
+
+// Chat returns the stats of chatID, zero if it has no messages.
+func (st Stats) Chat(chatID string) ChatStats {
+	for _, c := range st.Chats {
+		if c.ChatID == chatID {
+			return c
+		}
+	}
+	return ChatStats{ChatID: chatID}
+}
+
+// Summary describes chatID's history and the store as a whole in a few
+// lines for the user.
+func (st Stats) Summary(chatID string) string {
+	var sb strings.Builder
+	c := st.Chat(chatID)
+	fmt.Fprintf(&sb, "This chat: %d messages", c.Messages)
+	if c.Pinned > 0 {
+		fmt.Fprintf(&sb, " (%d pinned)", c.Pinned)
+	}
+	if c.Messages > 0 {
+		fmt.Fprintf(&sb, " from %s to %s", c.Oldest.Local().Format("2006-01-02 15:04"), c.Newest.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(&sb, "\nAll chats: %d messages in %d chats, %d facts, %d archived messages, %d summaries",
+		st.Messages, len(st.Chats), st.Facts, st.Archived, st.Summaries)
+	if st.SizeBytes > 0 {
+		fmt.Fprintf(&sb, "\nDatabase size: %s", formatSize(st.SizeBytes))
+	}
+	return sb.String()
+}
+
+// parseDBTime reads the result of MIN or MAX over a timestamp column, which
+// SQLite returns as text.
+func parseDBTime(v interface{}) time.Time {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return time.Time{}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02T15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05Z"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%dKB", size/1024)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
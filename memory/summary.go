@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetSummary returns the rolling summary of chatID and the ID of the last
+// message it covers. Summaries older than the retention window are ignored.
+func (s *Store) GetSummary(chatID string) (string, int64, error) {
+	cutoff := time.Now().Add(-time.Duration(s.Limits(chatID).MaxMinutes) * time.Minute)
+
+	var (
+		summary   string
+		throughID int64
+	)
+	err := s.queryRow(
+		"SELECT summary, through_id FROM conversation_summaries WHERE chat_id = ? AND updated_at > ?",
+		chatID, cutoff.UTC(),
+	).Scan(&summary, &throughID)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	summary, err = s.open(summary)
+	return summary, throughID, err
+}
+
+func (s *Store) SetSummary(chatID, summary string, throughID int64) error {
+	summary, err := s.seal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(`
+		INSERT INTO conversation_summaries (chat_id, summary, through_id, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET summary = excluded.summary, through_id = excluded.through_id, updated_at = excluded.updated_at
+	`, chatID, summary, throughID, time.Now().UTC())
+	return err
+}
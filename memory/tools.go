@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tron"
+)
+
+// AddMessages stores messages in order, including assistant tool calls, tool
+// results and the sender of user messages. Messages are dated by their
+// Timestamp, the time the sender sent them, if set.
+func (s *Store) AddMessages(chatID string, messages []tron.Message, expiresInSeconds int) error {
+	var expiresAt sql.NullTime
+	if expiresInSeconds > 0 {
+		expiresAt = sql.NullTime{
+			Time:  time.Now().Add(time.Duration(expiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+
+	s.lastWrite.Store(time.Now().UnixNano())
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	fallback, err := fallbackTimestamp(tx.QueryRow(s.dialect.rebind(newestMessageQuery), chatID))
+	if err != nil {
+		return err
+	}
+
+	insert := s.dialect.rebind(`
+		INSERT INTO messages (chat_id, role, content, expires_at, tool_calls, tool_call_id, sender, signal_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	for _, m := range messages {
+		var toolCalls, toolCallID, sender sql.NullString
+		if len(m.ToolCalls) > 0 {
+			data, err := json.Marshal(m.ToolCalls)
+			if err != nil {
+				return fmt.Errorf("encode tool calls: %w", err)
+			}
+			sealed, err := s.seal(string(data))
+			if err != nil {
+				return err
+			}
+			toolCalls = sql.NullString{String: sealed, Valid: true}
+		}
+		if m.ToolCallID != "" {
+			toolCallID = sql.NullString{String: m.ToolCallID, Valid: true}
+		}
+		if m.Sender != "" {
+			sender = sql.NullString{String: m.Sender, Valid: true}
+		}
+		sentAt := fallback
+		if !m.Timestamp.IsZero() {
+			sentAt = m.Timestamp.UTC()
+			// Signal redelivers messages that were not acknowledged.
+			dup, err := s.hasMessage(tx, chatID, sentAt, m.Role, m.Content)
+			if err != nil {
+				return err
+			}
+			if dup {
+				continue
+			}
+		}
+		content, err := s.seal(m.Content)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(insert, chatID, m.Role, content, expiresAt, toolCalls, toolCallID, sender, sentAt); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.pruneOldMessages(chatID)
+}
+
+// hasMessage reports whether chatID already has a message sent at sentAt
+// with role and content. Content is compared after decryption, as each
+// encryption of the same text differs.
+func (s *Store) hasMessage(tx *sql.Tx, chatID string, sentAt time.Time, role, content string) (bool, error) {
+	rows, err := tx.Query(s.dialect.rebind(
+		"SELECT content FROM messages WHERE chat_id = ? AND signal_timestamp = ? AND role = ?"),
+		chatID, sentAt, role)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stored string
+		if err := rows.Scan(&stored); err != nil {
+			return false, err
+		}
+		if stored, err = s.open(stored); err != nil {
+			return false, err
+		}
+		if stored == content {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// pairToolMessages drops tool calls whose results are incomplete and tool
+// results without their call, which pruning and expiry can leave behind.
+// Providers reject either. Text that came with dropped calls is kept.
+func pairToolMessages(messages []tron.Message) []tron.Message {
+	result := make([]tron.Message, 0, len(messages))
+	for i := 0; i < len(messages); i++ {
+		m := messages[i]
+		if m.Role == "tool" {
+			continue
+		}
+		if m.Role != "assistant" || len(m.ToolCalls) == 0 {
+			result = append(result, m)
+			continue
+		}
+
+		pending := make(map[string]bool, len(m.ToolCalls))
+		for _, tc := range m.ToolCalls {
+			pending[tc.ID] = true
+		}
+		group := []tron.Message{m}
+		j := i + 1
+		for ; j < len(messages) && messages[j].Role == "tool"; j++ {
+			if pending[messages[j].ToolCallID] {
+				delete(pending, messages[j].ToolCallID)
+				group = append(group, messages[j])
+			}
+		}
+
+		if len(pending) == 0 {
+			result = append(result, group...)
+		} else if m.Content != "" {
+			m.ToolCalls = nil
+			result = append(result, m)
+		}
+		i = j - 1
+	}
+	return result
+}
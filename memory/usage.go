@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tron"
+)
+
+type UsageRow struct {
+	ChatID           string
+	Date             string
+	PromptTokens     int
+	CompletionTokens int
+	Requests         int
+}
+
+func (s *Store) RecordUsage(chatID string, usage tron.Usage) error {
+	_, err := s.exec(`
+		INSERT INTO usage (chat_id, date, prompt_tokens, completion_tokens, requests)
+		VALUES (?, ?, ?, ?, 1)
+		ON CONFLICT(chat_id, date) DO UPDATE SET
+			prompt_tokens = usage.prompt_tokens + excluded.prompt_tokens,
+			completion_tokens = usage.completion_tokens + excluded.completion_tokens,
+			requests = usage.requests + 1
+	`, chatID, time.Now().Format("2006-01-02"), usage.PromptTokens, usage.CompletionTokens)
+	return err
+}
+
+func (s *Store) GetUsage(chatID string, since time.Time) ([]UsageRow, error) {
+	query := `
+		SELECT chat_id, date, prompt_tokens, completion_tokens, requests
+		FROM usage
+		WHERE date >= ?`
+	args := []interface{}{since.Format("2006-01-02")}
+	if chatID != "" {
+		query += " AND chat_id = ?"
+		args = append(args, chatID)
+	}
+	query += " ORDER BY date ASC, chat_id ASC"
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []UsageRow
+	for rows.Next() {
+		var r UsageRow
+		if err := rows.Scan(&r.ChatID, &r.Date, &r.PromptTokens, &r.CompletionTokens, &r.Requests); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
+
+type UsageTool struct {
+	store            *Store
+	inputPerMillion  float64
+	outputPerMillion float64
+	chatID           string
+}
+
+type usageArgs struct {
+	Days  int    `json:"days"`
+	Scope string `json:"scope"`
+}
+
+func NewUsageTool(store *Store, inputPerMillion, outputPerMillion float64) *UsageTool {
+	return &UsageTool{
+		store:            store,
+		inputPerMillion:  inputPerMillion,
+		outputPerMillion: outputPerMillion,
+	}
+}
+
+func (t *UsageTool) SetContext(chatID string) {
+	t.chatID = chatID
+}
+
+func (t *UsageTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name:        "usage",
+			Description: "Report LLM token usage per day and the estimated cost. Use when asked how many tokens were used or how much the bot costs.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"days": map[string]interface{}{
+						"type":        "integer",
+						"description": "Number of days to report, including today (default: 7)",
+					},
+					"scope": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"all", "chat"},
+						"description": "all: usage across every chat (default); chat: only the current chat",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (t *UsageTool) Commands() []tron.Command {
+	return []tron.Command{{
+		Name:        "/usage",
+		Description: "Token usage and cost per day (optional: number of days)",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			days := 7
+			if args != "" {
+				n, err := strconv.Atoi(args)
+				if err != nil || n <= 0 {
+					return "Usage: /usage [days]", nil
+				}
+				days = n
+			}
+			return t.Execute(fmt.Sprintf(`{"days": %d}`, days))
+		},
+	}}
+}
+
+func (t *UsageTool) Execute(argsJSON string) (string, error) {
+	var args usageArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("parse args: %w", err)
+		}
+	}
+	if args.Days <= 0 {
+		args.Days = 7
+	}
+
+	chatID := ""
+	if args.Scope == "chat" {
+		chatID = t.chatID
+	}
+
+	since := time.Now().AddDate(0, 0, -(args.Days - 1))
+	rows, err := t.store.GetUsage(chatID, since)
+	if err != nil {
+		return "", fmt.Errorf("get usage: %w", err)
+	}
+	if len(rows) == 0 {
+		return fmt.Sprintf("No usage recorded in the last %d days.", args.Days), nil
+	}
+
+	type dayTotal struct {
+		prompt, completion, requests int
+	}
+	var dates []string
+	days := make(map[string]*dayTotal)
+	var total dayTotal
+	for _, r := range rows {
+		d, ok := days[r.Date]
+		if !ok {
+			d = &dayTotal{}
+			days[r.Date] = d
+			dates = append(dates, r.Date)
+		}
+		d.prompt += r.PromptTokens
+		d.completion += r.CompletionTokens
+		d.requests += r.Requests
+		total.prompt += r.PromptTokens
+		total.completion += r.CompletionTokens
+		total.requests += r.Requests
+	}
+
+	var sb strings.Builder
+	for _, date := range dates {
+		d := days[date]
+		fmt.Fprintf(&sb, "%s: %d requests, %d prompt + %d completion tokens, $%.4f\n",
+			date, d.requests, d.prompt, d.completion, t.cost(d.prompt, d.completion))
+	}
+	fmt.Fprintf(&sb, "Total: %d requests, %d prompt + %d completion tokens, $%.4f",
+		total.requests, total.prompt, total.completion, t.cost(total.prompt, total.completion))
+
+	return sb.String(), nil
+}
+
+func (t *UsageTool) cost(promptTokens, completionTokens int) float64 {
+	return float64(promptTokens)/1e6*t.inputPerMillion + float64(completionTokens)/1e6*t.outputPerMillion
+}
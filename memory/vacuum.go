@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// vacuumQuietPeriod is how long no message must have been stored
+	// before a scheduled vacuum starts.
+	vacuumQuietPeriod  = 10 * time.Minute
+	vacuumPagesPerStep = 1000
+	vacuumStepPause    = 50 * time.Millisecond
+	lastVacuumKey      = "memory.last_vacuum"
+)
+
+// WithVacuumInterval reclaims the space freed by pruning and expiry every
+// interval, once no message has been stored for ten minutes. Only SQLite
+// is vacuumed; 0 disables it.
+func WithVacuumInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.vacuumInterval = interval
+	}
+}
+
+// Vacuum returns free pages of the SQLite file to the file system and
+// truncates the WAL, and reports the bytes reclaimed. The first run switches
+// the database to incremental auto-vacuum, which takes one full VACUUM that
+// blocks writers while it runs; later runs free pages in small steps so
+// message handling is never held up for long.
+func (s *Store) Vacuum(ctx context.Context) (int64, error) {
+	if s.driver != "sqlite3" {
+		return 0, fmt.Errorf("vacuum is only supported for sqlite3, not %s", s.driver)
+	}
+	s.vacuumMu.Lock()
+	defer s.vacuumMu.Unlock()
+
+	// auto_vacuum only takes effect through a VACUUM on the same connection.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	before, err := databaseSize(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return 0, err
+	}
+	if mode != 2 {
+		log.Printf("[memory] switching to incremental auto-vacuum; running a full VACUUM once")
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return 0, err
+		}
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return 0, fmt.Errorf("vacuum: %w", err)
+		}
+	} else if err := incrementalVacuum(ctx, conn); err != nil {
+		return 0, fmt.Errorf("incremental vacuum: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return 0, fmt.Errorf("checkpoint: %w", err)
+	}
+
+	after, err := databaseSize(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.SetState(lastVacuumKey, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return 0, err
+	}
+
+	reclaimed := max(before-after, 0)
+	log.Printf("[memory] vacuum reclaimed %s (%s -> %s)", formatSize(reclaimed), formatSize(before), formatSize(after))
+	return reclaimed, nil
+}
+
+// rebuild runs a full VACUUM and truncates the WAL, so no free page or old
+// WAL frame keeps deleted or overwritten content.
+func (s *Store) rebuild(ctx context.Context) error {
+	s.vacuumMu.Lock()
+	defer s.vacuumMu.Unlock()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	var busy, walFrames, checkpointed int
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &walFrames, &checkpointed); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if busy != 0 {
+		return fmt.Errorf("checkpoint: database is in use by another connection")
+	}
+	return nil
+}
+
+func incrementalVacuum(ctx context.Context, conn *sql.Conn) error {
+	for {
+		var free int
+		if err := conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&free); err != nil {
+			return err
+		}
+		if free == 0 {
+			return nil
+		}
+		// The pragma frees one page per row it steps over.
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", vacuumPagesPerStep))
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(vacuumStepPause):
+		}
+	}
+}
+
+func databaseSize(ctx context.Context, conn *sql.Conn) (int64, error) {
+	var size int64
+	err := conn.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	return size, err
+}
+
+// maybeVacuum runs a scheduled vacuum when one is due and the chats are
+// quiet.
+func (s *Store) maybeVacuum(ctx context.Context) {
+	if s.vacuumInterval <= 0 || s.driver != "sqlite3" {
+		return
+	}
+	if time.Since(time.Unix(0, s.lastWrite.Load())) < vacuumQuietPeriod {
+		return
+	}
+
+	last, err := s.GetState(lastVacuumKey)
+	if err != nil {
+		log.Printf("[memory] vacuum error: %v", err)
+		return
+	}
+	if t, err := time.Parse(time.RFC3339, last); err == nil && time.Since(t) < s.vacuumInterval {
+		return
+	}
+
+	if _, err := s.Vacuum(ctx); err != nil {
+		log.Printf("[memory] vacuum error: %v", err)
+	}
+}
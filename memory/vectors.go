@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"sort"
+
+	"tron"
+	"tron/vector"
+)
+
+// maxVectorsPerChat bounds the archive of each chat, and with it the cost of
+// a search; the oldest entries are removed first.
+const maxVectorsPerChat = 2000
+
+// AddVector archives a message with its embedding for SearchVectors. The
+// archive is kept apart from the history and is not pruned by age.
+func (s *Store) AddVector(chatID, role, content string, vec []float32) error {
+	content, err := s.seal(content)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(
+		"INSERT INTO message_vectors (chat_id, role, content, vector) VALUES (?, ?, ?, ?)",
+		chatID, role, content, vector.Encode(vec),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(`
+		DELETE FROM message_vectors WHERE chat_id = ? AND id NOT IN (
+			SELECT id FROM message_vectors WHERE chat_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, chatID, chatID, maxVectorsPerChat)
+	return err
+}
+
+// SearchVectors returns up to k archived messages of chatID whose cosine
+// similarity to query is at least minScore, most similar first.
+func (s *Store) SearchVectors(chatID string, query []float32, k int, minScore float32) ([]tron.Snippet, error) {
+	rows, err := s.query("SELECT role, content, vector, created_at FROM message_vectors WHERE chat_id = ?", chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []tron.Snippet
+	for rows.Next() {
+		var (
+			sn  tron.Snippet
+			buf []byte
+		)
+		if err := rows.Scan(&sn.Role, &sn.Content, &buf, &sn.Timestamp); err != nil {
+			return nil, err
+		}
+		sn.Score = vector.Cosine(query, vector.Decode(buf))
+		if sn.Score < minScore {
+			continue
+		}
+		if sn.Content, err = s.open(sn.Content); err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, sn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Score > snippets[j].Score })
+	if k > 0 && len(snippets) > k {
+		snippets = snippets[:k]
+	}
+	return snippets, nil
+}
@@ -0,0 +1,118 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"tron"
+)
+
+// AdminTool lets the operator manage the loaded external plugins. Calls made
+// for anyone else are refused; calls without a role, from the API and
+// scheduled summaries, are allowed.
+type AdminTool struct {
+	manager *Manager
+}
+
+type adminArgs struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+}
+
+func NewAdminTool(m *Manager) *AdminTool {
+	return &AdminTool{manager: m}
+}
+
+func (t *AdminTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name: "plugin_admin",
+			Description: "Manage the bot's external plugins. Use 'reload' after plugins were added, removed or edited on disk, " +
+				"'disable' to hide a plugin from the tool list until 'enable' is used. Only when the operator asks.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"reload", "disable", "enable"},
+						"description": "reload: rescan the plugin directory and report what changed; disable/enable: turn a plugin off or on again",
+					},
+					"name": map[string]interface{}{
+						"type":        "string",
+						"description": "Plugin name (for disable and enable)",
+					},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+func (t *AdminTool) Commands() []tron.Command {
+	return []tron.Command{{
+		Name:        "/plugin",
+		Description: "Rescan the plugin directory or turn a plugin off or on (/plugin reload, /plugin disable <name>, /plugin enable <name>)",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			action, name, _ := strings.Cut(strings.TrimSpace(args), " ")
+			name = strings.TrimSpace(name)
+			switch {
+			case action == "reload" && name == "":
+				return t.reload()
+			case (action == "disable" || action == "enable") && name != "":
+				return t.setEnabled(name, action == "enable")
+			}
+			return "Usage: /plugin reload, /plugin disable <name> or /plugin enable <name>", nil
+		},
+	}}
+}
+
+func (t *AdminTool) Execute(argsJSON string) (string, error) {
+	return t.ExecuteFor(context.Background(), "", argsJSON)
+}
+
+func (t *AdminTool) ExecuteFor(ctx context.Context, chatID, argsJSON string) (string, error) {
+	if role := tron.RoleFromContext(ctx); role != "" && role != tron.RoleOperator {
+		return "", fmt.Errorf("plugin_admin is only available to the operator")
+	}
+
+	var args adminArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("parse args: %w", err)
+	}
+
+	switch args.Action {
+	case "reload":
+		return t.reload()
+	case "disable", "enable":
+		if args.Name == "" {
+			return "", fmt.Errorf("name is required for %s", args.Action)
+		}
+		return t.setEnabled(args.Name, args.Action == "enable")
+	default:
+		return "", fmt.Errorf("unknown action: %s", args.Action)
+	}
+}
+
+func (t *AdminTool) reload() (string, error) {
+	result, err := t.manager.Reload()
+	if err != nil {
+		return "", fmt.Errorf("reload plugins: %w", err)
+	}
+	return "Plugins reloaded: " + result.String(), nil
+}
+
+func (t *AdminTool) setEnabled(name string, enabled bool) (string, error) {
+	if enabled {
+		if err := t.manager.Enable(name); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Plugin %s enabled.", name), nil
+	}
+	if err := t.manager.Disable(name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Plugin %s disabled. It stays off after restarts until enabled again.", name), nil
+}
@@ -0,0 +1,42 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+
+	"tron"
+)
+
+func TestAdminToolRefusesNonOperators(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr bool
+	}{
+		{"user role", tron.WithRole(context.Background(), "family"), true},
+		{"ignored role", tron.WithRole(context.Background(), tron.RoleIgnored), true},
+		{"operator", tron.WithRole(context.Background(), tron.RoleOperator), false},
+		{"no role", context.Background(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginDir := t.TempDir()
+			writePlugin(t, pluginDir, "echoenv", map[string]interface{}{}, map[string]string{})
+			m, err := NewManager(pluginDir, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.RegisterTool("plugin_admin", NewAdminTool(m))
+
+			for _, args := range []string{`{"action":"disable","name":"echoenv"}`, `{"action":"reload"}`} {
+				_, err := m.ExecuteWithContext(tt.ctx, "plugin_admin", args, "dm:+200")
+				if (err != nil) != tt.wantErr {
+					t.Errorf("%s: error = %v, want error %v", args, err, tt.wantErr)
+				}
+			}
+			if got := m.HasPlugin("echoenv"); got != tt.wantErr {
+				t.Errorf("HasPlugin() = %v, want %v", got, tt.wantErr)
+			}
+		})
+	}
+}
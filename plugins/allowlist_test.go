@@ -0,0 +1,48 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestLoadPluginAllowlist(t *testing.T) {
+	const script = "#!/bin/sh\nenv\n"
+	sum := sha256.Sum256([]byte(script))
+	hash := "sha256:" + hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name        string
+		allowlist   map[string]string
+		wantRefused bool
+	}{
+		{"no allowlist", nil, false},
+		{"listed with matching hash", map[string]string{"echoenv": hash}, false},
+		{"listed with uppercase hash", map[string]string{"echoenv": "SHA256:" + hex.EncodeToString(sum[:])}, false},
+		{"listed with other hash", map[string]string{"echoenv": "sha256:00"}, true},
+		{"listed without prefix", map[string]string{"echoenv": hex.EncodeToString(sum[:])}, true},
+		{"not listed", map[string]string{"other": hash}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginDir := t.TempDir()
+			dir := writePlugin(t, pluginDir, "echoenv", map[string]interface{}{}, map[string]string{"run": script})
+
+			m, err := NewManager(pluginDir, false, WithAllowlist(tt.allowlist))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = m.loadPlugin(dir)
+			if refused := errors.Is(err, errNotAllowed); refused != tt.wantRefused {
+				t.Fatalf("loadPlugin() error = %v, want refused %v", err, tt.wantRefused)
+			}
+			if !tt.wantRefused && err != nil {
+				t.Fatalf("loadPlugin() error = %v", err)
+			}
+			if got := m.HasPlugin("echoenv"); got == tt.wantRefused {
+				t.Errorf("HasPlugin() = %v after NewManager", got)
+			}
+		})
+	}
+}
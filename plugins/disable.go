@@ -0,0 +1,113 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const disabledPluginsKey = "disabled_plugins"
+
+// StateStore persists which plugins are disabled.
+type StateStore interface {
+	GetState(key string) (string, error)
+	SetState(key, value string) error
+}
+
+// WithStateStore keeps the set of disabled plugins in state so it survives
+// restarts.
+func WithStateStore(state StateStore) Option {
+	return func(m *Manager) {
+		m.state = state
+	}
+}
+
+func (m *Manager) loadDisabled() error {
+	if m.state == nil {
+		return nil
+	}
+	value, err := m.state.GetState(disabledPluginsKey)
+	if err != nil || value == "" {
+		return err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(value), &names); err != nil {
+		return fmt.Errorf("parse %s: %w", disabledPluginsKey, err)
+	}
+	for _, name := range names {
+		m.disabled[name] = true
+	}
+	return nil
+}
+
+// saveDisabled stores the disabled set. The caller holds m.mu.
+func (m *Manager) saveDisabled() error {
+	if m.state == nil {
+		return nil
+	}
+	names := make([]string, 0, len(m.disabled))
+	for name := range m.disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return m.state.SetState(disabledPluginsKey, string(data))
+}
+
+// Disable hides the external plugin name from the tool list and refuses
+// calls to it until Enable is called. Internal tools cannot be disabled.
+func (m *Manager) Disable(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.internalTools[name]; ok {
+		return fmt.Errorf("%s is an internal tool and cannot be disabled", name)
+	}
+	if m.disabled[name] {
+		return nil
+	}
+	plugin, ok := m.plugins[name]
+	if !ok {
+		return fmt.Errorf("unknown plugin: %s", name)
+	}
+	delete(m.plugins, name)
+	m.inactivePlugins[name] = plugin
+	m.disabled[name] = true
+	log.Printf("[plugin] disabled: %s", name)
+	return m.saveDisabled()
+}
+
+// Enable makes a plugin disabled with Disable available again.
+func (m *Manager) Enable(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.disabled[name] {
+		if _, ok := m.plugins[name]; ok {
+			return nil
+		}
+		return fmt.Errorf("unknown plugin: %s", name)
+	}
+	if plugin, ok := m.inactivePlugins[name]; ok {
+		m.plugins[name] = plugin
+		delete(m.inactivePlugins, name)
+	}
+	delete(m.disabled, name)
+	log.Printf("[plugin] enabled: %s", name)
+	return m.saveDisabled()
+}
+
+// setInactive moves the disabled plugins out of plugins and returns them.
+// The caller holds m.mu.
+func (m *Manager) setInactive(plugins map[string]*Plugin) map[string]*Plugin {
+	inactive := make(map[string]*Plugin)
+	for name := range m.disabled {
+		if plugin, ok := plugins[name]; ok {
+			inactive[name] = plugin
+			delete(plugins, name)
+		}
+	}
+	return inactive
+}
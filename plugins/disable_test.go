@@ -0,0 +1,114 @@
+package plugins
+
+import (
+	"sync"
+	"testing"
+)
+
+type mapState struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (s *mapState) GetState(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key], nil
+}
+
+func (s *mapState) SetState(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func TestDisableSurvivesRestart(t *testing.T) {
+	pluginDir := t.TempDir()
+	writePlugin(t, pluginDir, "echoenv", map[string]interface{}{}, map[string]string{})
+	state := &mapState{values: make(map[string]string)}
+
+	m, err := NewManager(pluginDir, false, WithStateStore(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Disable("echoenv"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if got := state.values[disabledPluginsKey]; got != `["echoenv"]` {
+		t.Errorf("stored %s = %q", disabledPluginsKey, got)
+	}
+
+	restarted, err := NewManager(pluginDir, false, WithStateStore(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.HasPlugin("echoenv") || len(restarted.GetTools()) != 0 {
+		t.Fatal("plugin is offered again after a restart")
+	}
+	if err := restarted.Enable("echoenv"); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if !restarted.HasPlugin("echoenv") {
+		t.Error("plugin not offered after Enable")
+	}
+	if got := state.values[disabledPluginsKey]; got != `[]` {
+		t.Errorf("stored %s after Enable = %q", disabledPluginsKey, got)
+	}
+}
+
+func TestDisableErrors(t *testing.T) {
+	pluginDir := t.TempDir()
+	writePlugin(t, pluginDir, "echoenv", map[string]interface{}{}, map[string]string{})
+	m, err := NewManager(pluginDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RegisterTool("plugin_admin", NewAdminTool(m))
+
+	tests := []struct {
+		name string
+		fn   func(string) error
+		arg  string
+	}{
+		{"disable internal tool", m.Disable, "plugin_admin"},
+		{"disable unknown plugin", m.Disable, "missing"},
+		{"enable unknown plugin", m.Enable, "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(tt.arg); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestDisableDuringReload is meant for the race detector.
+func TestDisableDuringReload(t *testing.T) {
+	pluginDir := t.TempDir()
+	writePlugin(t, pluginDir, "echoenv", map[string]interface{}{}, map[string]string{})
+	m, err := NewManager(pluginDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			m.Disable("echoenv")
+			m.Enable("echoenv")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			if _, err := m.Reload(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+}
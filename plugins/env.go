@@ -0,0 +1,128 @@
+package plugins
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// baseEnv lists the variables of the bot's environment every plugin gets.
+var baseEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR"}
+
+// pluginEnv builds the environment a plugin runs with: the base variables,
+// then the env_files in order, then env, later values winning. Values that
+// start with "$" name a variable of the bot's environment.
+func pluginEnv(plugin *Plugin) ([]string, error) {
+	vars := make(map[string]string)
+	var order []string
+	set := func(key, value string) {
+		if _, ok := vars[key]; !ok {
+			order = append(order, key)
+		}
+		vars[key] = value
+	}
+
+	for _, key := range baseEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			set(key, value)
+		}
+	}
+	for _, name := range plugin.Definition.EnvFiles {
+		path, err := envFilePath(plugin.Dir, name)
+		if err != nil {
+			return nil, err
+		}
+		values, keys, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			set(key, resolveEnv(values[key]))
+		}
+	}
+	keys := make([]string, 0, len(plugin.Definition.Env))
+	for key := range plugin.Definition.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		set(key, resolveEnv(plugin.Definition.Env[key]))
+	}
+
+	env := make([]string, 0, len(order))
+	for _, key := range order {
+		env = append(env, key+"="+vars[key])
+	}
+	return env, nil
+}
+
+// envFilePath returns the path of the env file name in dir. Definitions are
+// checked when loaded, but the file may since have been replaced by a
+// symlink, so the resolved path is checked again on every read.
+func envFilePath(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("env file %q is outside the plugin directory", name)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("read env file: %w", err)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", fmt.Errorf("read env file: %w", err)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("env file %q is outside the plugin directory", name)
+	}
+	return path, nil
+}
+
+func resolveEnv(value string) string {
+	if name, ok := strings.CutPrefix(value, "$"); ok {
+		return os.Getenv(strings.TrimSuffix(strings.TrimPrefix(name, "{"), "}"))
+	}
+	return value
+}
+
+// readEnvFile reads KEY=VALUE lines from a dotenv-style file. Blank lines,
+// # comments and an "export " prefix are ignored, and matching quotes around
+// a value are removed.
+func readEnvFile(path string) (map[string]string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read env file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, nil, fmt.Errorf("%s:%d: expected KEY=VALUE", filepath.Base(path), n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read env file: %w", err)
+	}
+	return values, keys, nil
+}
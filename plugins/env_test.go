@@ -0,0 +1,155 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePlugin creates a plugin named name in pluginDir whose executable
+// prints its environment, one variable per line, and writes files into its
+// directory.
+func writePlugin(t *testing.T, pluginDir, name string, def map[string]interface{}, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(pluginDir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	def["name"] = name
+	def["description"] = "Prints its environment"
+	def["enabled"] = true
+	def["parameters"] = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	data, err := json.Marshal(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files["definition.json"] = string(data)
+	if _, ok := files["run"]; !ok {
+		files["run"] = "#!/bin/sh\nenv\n"
+	}
+	for file, content := range files {
+		mode := os.FileMode(0o644)
+		if file == "run" {
+			mode = 0o755
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func parseEnv(output string) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+func TestPluginEnv(t *testing.T) {
+	t.Setenv("TRON_TEST_SHARED_KEY", "shared-secret")
+	t.Setenv("TRON_TEST_BOT_SECRET", "bot-only")
+	t.Setenv("LANG", "C.UTF-8")
+
+	pluginDir := t.TempDir()
+	writePlugin(t, pluginDir, "echoenv", map[string]interface{}{
+		"env": map[string]string{
+			"FROM_ENV":   "definition",
+			"OVERRIDDEN": "definition wins",
+			"SHARED":     "$TRON_TEST_SHARED_KEY",
+			"BRACED":     "${TRON_TEST_SHARED_KEY}",
+			"UNSET":      "$TRON_TEST_NOT_SET",
+		},
+		"env_files": []string{".env", "more.env"},
+	}, map[string]string{
+		".env":     "# comment\nexport FROM_FILE=\"quoted value\"\nOVERRIDDEN=first file\nFILE_ORDER=first\n\nFILE_REF=$TRON_TEST_SHARED_KEY\n",
+		"more.env": "FILE_ORDER='second'\n",
+	})
+
+	m, err := NewManager(pluginDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Execute(context.Background(), "echoenv", "{}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := parseEnv(out)
+
+	want := map[string]string{
+		"FROM_ENV":   "definition",
+		"FROM_FILE":  "quoted value",
+		"OVERRIDDEN": "definition wins",
+		"FILE_ORDER": "second",
+		"FILE_REF":   "shared-secret",
+		"SHARED":     "shared-secret",
+		"BRACED":     "shared-secret",
+		"UNSET":      "",
+		"LANG":       "C.UTF-8",
+	}
+	for key, value := range want {
+		if got, ok := env[key]; !ok || got != value {
+			t.Errorf("%s = %q (set: %v), want %q", key, got, ok, value)
+		}
+	}
+	if _, ok := env["TRON_TEST_BOT_SECRET"]; ok {
+		t.Error("the plugin inherited a bot variable it was not given")
+	}
+}
+
+func TestPluginEnvFileErrors(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret.env")
+	if err := os.WriteFile(outside, []byte("SECRET=stolen\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		envFiles []string
+		files    map[string]string
+		symlink  string
+		loads    bool
+	}{
+		{"parent directory", []string{"../other/.env"}, nil, "", false},
+		{"absolute path", []string{outside}, nil, "", false},
+		{"symlink out of the directory", []string{".env"}, nil, outside, true},
+		{"missing file", []string{"missing.env"}, nil, "", true},
+		{"malformed line", []string{".env"}, map[string]string{".env": "NOT A VARIABLE\n"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginDir := t.TempDir()
+			files := tt.files
+			if files == nil {
+				files = make(map[string]string)
+			}
+			dir := writePlugin(t, pluginDir, "echoenv", map[string]interface{}{"env_files": tt.envFiles}, files)
+			if tt.symlink != "" {
+				if err := os.Symlink(tt.symlink, filepath.Join(dir, ".env")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			m, err := NewManager(pluginDir, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.HasPlugin("echoenv") != tt.loads {
+				t.Fatalf("loaded = %v, want %v", m.HasPlugin("echoenv"), tt.loads)
+			}
+			if !tt.loads {
+				return
+			}
+			out, err := m.Execute(context.Background(), "echoenv", "{}")
+			if err == nil {
+				t.Errorf("expected the call to fail, got output %q", out)
+			}
+		})
+	}
+}
@@ -3,14 +3,24 @@ package plugins
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"tron"
+	"tron/util"
 )
 
 type PluginDefinition struct {
@@ -19,6 +29,16 @@ type PluginDefinition struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 	Timeout     int                    `json:"timeout,omitempty"`
 	Enabled     bool                   `json:"enabled,omitempty"`
+	Version     string                 `json:"version,omitempty"`
+	Author      string                 `json:"author,omitempty"`
+	Priority    int                    `json:"priority,omitempty"`
+	// OutputSchema, if set, is the JSON Schema the plugin's stdout must
+	// match.
+	OutputSchema map[string]interface{} `json:"output_schema,omitempty"`
+	// Env and the dotenv-style EnvFiles, relative to the plugin directory,
+	// add to the minimal environment the plugin runs with.
+	Env      map[string]string `json:"env,omitempty"`
+	EnvFiles []string          `json:"env_files,omitempty"`
 }
 
 type Plugin struct {
@@ -37,34 +57,214 @@ type ContextAwareTool interface {
 	SetContext(chatID string)
 }
 
+// CallerTool is an internal tool that needs the caller's context, such as
+// the sender's role or when their message was sent. The manager calls
+// ExecuteFor instead of Execute.
+type CallerTool interface {
+	InternalTool
+	ExecuteFor(ctx context.Context, chatID, argsJSON string) (string, error)
+}
+
+// CommandTool is an internal tool that also exposes chat commands.
+type CommandTool interface {
+	InternalTool
+	Commands() []tron.Command
+}
+
 type Manager struct {
+	mu            sync.RWMutex
 	plugins       map[string]*Plugin
+	pluginDir     string
+	registry      *registry
+	stats         callStats
 	internalTools map[string]InternalTool
+	priorities    map[string]int
+	toolLocks     map[string]*sync.Mutex
+	allowlist     map[string]string
+	perChat       map[string][]string
+	roles         map[string][]string
 	debug         bool
+
+	// inactivePlugins holds the loaded plugins named in disabled.
+	inactivePlugins map[string]*Plugin
+	disabled        map[string]bool
+	state           StateStore
+}
+
+type Option func(*Manager)
+
+var errNotAllowed = errors.New("executable rejected by plugin_allowlist")
+
+func WithAllowlist(allowlist map[string]string) Option {
+	return func(m *Manager) {
+		m.allowlist = allowlist
+	}
+}
+
+func WithPerChatPlugins(perChat map[string][]string) Option {
+	return func(m *Manager) {
+		m.perChat = perChat
+	}
+}
+
+// WithRoles sets the tools each sender role may use, as names or * patterns.
+// The operator and callers without a role may use every tool.
+func WithRoles(roles map[string][]string) Option {
+	return func(m *Manager) {
+		m.roles = roles
+	}
 }
 
-func NewManager(pluginDir string, debug bool) (*Manager, error) {
+func NewManager(pluginDir string, debug bool, opts ...Option) (*Manager, error) {
 	m := &Manager{
-		plugins:       make(map[string]*Plugin),
-		internalTools: make(map[string]InternalTool),
-		debug:         debug,
+		plugins:         make(map[string]*Plugin),
+		inactivePlugins: make(map[string]*Plugin),
+		disabled:        make(map[string]bool),
+		internalTools:   make(map[string]InternalTool),
+		priorities:      make(map[string]int),
+		toolLocks:       make(map[string]*sync.Mutex),
+		pluginDir:       pluginDir,
+		debug:           debug,
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
-	if err := m.loadPlugins(pluginDir); err != nil {
+	if err := m.loadDisabled(); err != nil {
+		return nil, err
+	}
+	if _, err := m.load(); err != nil {
 		return nil, err
 	}
 
 	return m, nil
 }
 
+// ReloadResult names the external plugins a reload added or removed, and
+// those whose definition changed or that now run a different executable.
+type ReloadResult struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (r ReloadResult) String() string {
+	var parts []string
+	for _, p := range []struct {
+		label string
+		names []string
+	}{{"added", r.Added}, {"removed", r.Removed}, {"changed", r.Changed}} {
+		if len(p.names) > 0 {
+			parts = append(parts, p.label+": "+strings.Join(p.names, ", "))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Reload rescans the plugin directory and, if configured, re-fetches the
+// remote registry, replacing the loaded external plugins in one step.
+// Calls already running finish with the plugin they started with.
+func (m *Manager) Reload() (ReloadResult, error) {
+	result, err := m.load()
+	if err != nil {
+		return result, err
+	}
+	log.Printf("[plugin] reloaded: %s", result)
+	return result, nil
+}
+
+func (m *Manager) load() (ReloadResult, error) {
+	var result ReloadResult
+	plugins := make(map[string]*Plugin)
+
+	if m.registry != nil {
+		dir, err := m.registry.sync(m.debug)
+		if err != nil {
+			log.Printf("[plugin] registry: %v; using cached plugins", err)
+		}
+		if err := m.loadPlugins(dir, plugins); err != nil {
+			return result, err
+		}
+	}
+
+	local := make(map[string]*Plugin)
+	if err := m.loadPlugins(m.pluginDir, local); err != nil {
+		return result, err
+	}
+	if len(local) > 0 && len(m.allowlist) == 0 {
+		log.Printf("[plugin] WARNING: %d plugin(s) loaded without a plugin_allowlist; executables are not verified", len(local))
+	}
+	for name, plugin := range local {
+		if _, ok := plugins[name]; ok {
+			log.Printf("[plugin] %s in %s overrides the registry copy", name, m.pluginDir)
+		}
+		plugins[name] = plugin
+	}
+
+	m.mu.Lock()
+	old := m.plugins
+	m.inactivePlugins = m.setInactive(plugins)
+	m.plugins = plugins
+	m.mu.Unlock()
+
+	for name, plugin := range plugins {
+		prev, ok := old[name]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, name)
+		case prev.Executable != plugin.Executable || !reflect.DeepEqual(prev.Definition, plugin.Definition):
+			result.Changed = append(result.Changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := plugins[name]; !ok {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Changed)
+
+	return result, nil
+}
+
+func (m *Manager) plugin(name string) (*Plugin, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.plugins[name]
+	return p, ok
+}
+
 func (m *Manager) RegisterTool(name string, tool InternalTool) {
+	m.RegisterToolWithPriority(name, tool, 0)
+}
+
+// RegisterToolWithPriority registers an internal tool that GetTools lists
+// ahead of tools with a lower priority. RegisterTool uses priority 0.
+func (m *Manager) RegisterToolWithPriority(name string, tool InternalTool, priority int) {
 	m.internalTools[name] = tool
+	m.priorities[name] = priority
+	m.toolLocks[name] = &sync.Mutex{}
 	if m.debug {
 		fmt.Printf("[plugin] registered internal tool: %s\n", name)
 	}
 }
 
-func (m *Manager) loadPlugins(pluginDir string) error {
+// Commands returns the chat commands exposed by internal tools.
+func (m *Manager) Commands() []tron.Command {
+	var cmds []tron.Command
+	for _, tool := range m.internalTools {
+		if ct, ok := tool.(CommandTool); ok {
+			cmds = append(cmds, ct.Commands()...)
+		}
+	}
+	return cmds
+}
+
+func (m *Manager) loadPlugins(pluginDir string, plugins map[string]*Plugin) error {
 	absPluginDir, err := filepath.Abs(pluginDir)
 	if err != nil {
 		return fmt.Errorf("abs path: %w", err)
@@ -85,6 +285,14 @@ func (m *Manager) loadPlugins(pluginDir string) error {
 
 		pluginPath := filepath.Join(absPluginDir, entry.Name())
 		plugin, err := m.loadPlugin(pluginPath)
+		if errors.Is(err, errNotAllowed) {
+			log.Printf("[plugin] SECURITY: refusing to load %s: %v", entry.Name(), err)
+			continue
+		}
+		if errors.Is(err, errInvalidDefinition) {
+			log.Printf("[plugin] skip %s: %v", entry.Name(), err)
+			continue
+		}
 		if err != nil {
 			if m.debug {
 				fmt.Printf("[plugin] skip %s: %v\n", entry.Name(), err)
@@ -93,7 +301,7 @@ func (m *Manager) loadPlugins(pluginDir string) error {
 		}
 
 		if plugin.Definition.Enabled {
-			m.plugins[plugin.Definition.Name] = plugin
+			plugins[plugin.Definition.Name] = plugin
 			if m.debug {
 				fmt.Printf("[plugin] loaded: %s\n", plugin.Definition.Name)
 			}
@@ -115,6 +323,10 @@ func (m *Manager) loadPlugin(dir string) (*Plugin, error) {
 		return nil, fmt.Errorf("parse definition: %w", err)
 	}
 
+	if err := validateDefinition(def); err != nil {
+		return nil, err
+	}
+
 	if def.Timeout == 0 {
 		def.Timeout = 30
 	}
@@ -124,6 +336,14 @@ func (m *Manager) loadPlugin(dir string) (*Plugin, error) {
 		return nil, fmt.Errorf("no executable found")
 	}
 
+	if expected, ok := m.allowlist[def.Name]; ok {
+		if err := verifyHash(executable, expected); err != nil {
+			return nil, err
+		}
+	} else if len(m.allowlist) > 0 && def.Enabled {
+		return nil, fmt.Errorf("%w: %s is not listed", errNotAllowed, def.Name)
+	}
+
 	return &Plugin{
 		Definition: def,
 		Executable: executable,
@@ -131,6 +351,29 @@ func (m *Manager) loadPlugin(dir string) (*Plugin, error) {
 	}, nil
 }
 
+func verifyHash(path, expected string) error {
+	want, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(expected)), "sha256:")
+	if !ok {
+		return fmt.Errorf("%w: entry must be in the form sha256:<hex>", errNotAllowed)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open executable: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("hash executable: %w", err)
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%w: hash mismatch (got sha256:%s)", errNotAllowed, got)
+	}
+	return nil
+}
+
 func (m *Manager) findExecutable(dir string) string {
 	candidates := []string{"run", "run.sh", "run.py", "run.rb", "main"}
 
@@ -148,32 +391,54 @@ func (m *Manager) findExecutable(dir string) string {
 	return ""
 }
 
-func (m *Manager) ExecuteWithContext(name string, argsJSON string, chatID string) (string, error) {
+func (m *Manager) ExecuteWithContext(ctx context.Context, name string, argsJSON string, chatID string) (result string, err error) {
+	if !m.enabledForChat(name, chatID) {
+		return "", fmt.Errorf("plugin %s is not enabled in this chat", name)
+	}
+	if role := tron.RoleFromContext(ctx); !m.allowedForRole(name, role) {
+		return "", fmt.Errorf("plugin %s is not available to role %s", name, role)
+	}
+	defer func() { m.stats.record(name, err) }()
+
 	if tool, ok := m.internalTools[name]; ok {
+		if callerTool, ok := tool.(CallerTool); ok {
+			return callerTool.ExecuteFor(ctx, chatID, argsJSON)
+		}
 		if ctxTool, ok := tool.(ContextAwareTool); ok {
+			// SetContext and Execute must not interleave with another
+			// chat's call of the same tool.
+			mu := m.toolLocks[name]
+			mu.Lock()
+			defer mu.Unlock()
 			ctxTool.SetContext(chatID)
 		}
 		return tool.Execute(argsJSON)
 	}
 
-	plugin, ok := m.plugins[name]
+	plugin, ok := m.plugin(name)
 	if !ok {
 		return "", fmt.Errorf("unknown plugin: %s", name)
 	}
 
 	timeout := time.Duration(plugin.Definition.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	env, err := pluginEnv(plugin)
+	if err != nil {
+		return "", fmt.Errorf("plugin environment: %w", err)
+	}
+
 	cmd := exec.CommandContext(ctx, plugin.Executable)
 	cmd.Dir = plugin.Dir
+	cmd.Env = env
 	cmd.Stdin = bytes.NewReader([]byte(argsJSON))
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if ctx.Err() == context.DeadlineExceeded {
 		return "", fmt.Errorf("plugin timeout after %ds", plugin.Definition.Timeout)
 	}
@@ -185,32 +450,43 @@ func (m *Manager) ExecuteWithContext(name string, argsJSON string, chatID string
 		return "", fmt.Errorf("plugin error: %s", errMsg)
 	}
 
-	return stdout.String(), nil
+	return checkOutput(plugin, stdout.String()), nil
 }
 
-func (m *Manager) Execute(name string, argsJSON string) (string, error) {
+func (m *Manager) Execute(ctx context.Context, name string, argsJSON string) (result string, err error) {
+	defer func() { m.stats.record(name, err) }()
+
 	if tool, ok := m.internalTools[name]; ok {
+		if callerTool, ok := tool.(CallerTool); ok {
+			return callerTool.ExecuteFor(ctx, "", argsJSON)
+		}
 		return tool.Execute(argsJSON)
 	}
 
-	plugin, ok := m.plugins[name]
+	plugin, ok := m.plugin(name)
 	if !ok {
 		return "", fmt.Errorf("unknown plugin: %s", name)
 	}
 
 	timeout := time.Duration(plugin.Definition.Timeout) * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	env, err := pluginEnv(plugin)
+	if err != nil {
+		return "", fmt.Errorf("plugin environment: %w", err)
+	}
+
 	cmd := exec.CommandContext(ctx, plugin.Executable)
 	cmd.Dir = plugin.Dir
+	cmd.Env = env
 	cmd.Stdin = bytes.NewReader([]byte(argsJSON))
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if ctx.Err() == context.DeadlineExceeded {
 		return "", fmt.Errorf("plugin timeout after %ds", plugin.Definition.Timeout)
 	}
@@ -222,16 +498,22 @@ func (m *Manager) Execute(name string, argsJSON string) (string, error) {
 		return "", fmt.Errorf("plugin error: %s", errMsg)
 	}
 
-	return stdout.String(), nil
+	return checkOutput(plugin, stdout.String()), nil
 }
 
+// GetTools returns all tools ordered by priority, highest first, and then
+// by name, so the LLM sees them in the same order on every request.
 func (m *Manager) GetTools() []tron.Tool {
 	var tools []tron.Tool
+	priority := make(map[string]int)
 
-	for _, tool := range m.internalTools {
-		tools = append(tools, tool.Definition())
+	for name, tool := range m.internalTools {
+		def := tool.Definition()
+		tools = append(tools, def)
+		priority[def.Function.Name] = m.priorities[name]
 	}
 
+	m.mu.RLock()
 	for _, plugin := range m.plugins {
 		tools = append(tools, tron.Tool{
 			Type: "function",
@@ -241,19 +523,100 @@ func (m *Manager) GetTools() []tron.Tool {
 				Parameters:  plugin.Definition.Parameters,
 			},
 		})
+		priority[plugin.Definition.Name] = plugin.Definition.Priority
 	}
+	m.mu.RUnlock()
 
+	sort.Slice(tools, func(i, j int) bool {
+		a, b := tools[i].Function.Name, tools[j].Function.Name
+		if priority[a] != priority[b] {
+			return priority[a] > priority[b]
+		}
+		return a < b
+	})
 	return tools
 }
 
+func (m *Manager) GetToolsForChat(chatID string) []tron.Tool {
+	all := m.GetTools()
+	enabled, ok := m.chatPlugins(chatID)
+	if !ok {
+		return all
+	}
+
+	var tools []tron.Tool
+	for _, tool := range all {
+		if enabled[tool.Function.Name] {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// GetToolsForRole returns the tools of chatID that role may use.
+func (m *Manager) GetToolsForRole(chatID, role string) []tron.Tool {
+	var tools []tron.Tool
+	for _, tool := range m.GetToolsForChat(chatID) {
+		if m.allowedForRole(tool.Function.Name, role) {
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+func (m *Manager) allowedForRole(name, role string) bool {
+	if role == "" || role == tron.RoleOperator {
+		return true
+	}
+	for _, pattern := range m.roles[role] {
+		if util.GlobMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) enabledForChat(name, chatID string) bool {
+	enabled, ok := m.chatPlugins(chatID)
+	return !ok || enabled[name]
+}
+
+// chatPlugins returns the plugin names enabled for chatID. An exact chatID key
+// wins over patterns; among patterns the longest match wins. ok is false when
+// every plugin is enabled.
+func (m *Manager) chatPlugins(chatID string) (map[string]bool, bool) {
+	names, found := m.perChat[chatID]
+	if !found {
+		best := -1
+		for pattern, list := range m.perChat {
+			if util.GlobMatch(pattern, chatID) && len(pattern) > best {
+				best = len(pattern)
+				names = list
+				found = true
+			}
+		}
+	}
+	if !found || len(names) == 0 {
+		return nil, false
+	}
+
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+	return enabled, true
+}
+
 func (m *Manager) HasPlugin(name string) bool {
 	if _, ok := m.internalTools[name]; ok {
 		return true
 	}
-	_, ok := m.plugins[name]
+	_, ok := m.plugin(name)
 	return ok
 }
 
 func (m *Manager) PluginCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return len(m.plugins) + len(m.internalTools)
 }
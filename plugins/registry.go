@@ -0,0 +1,177 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	registryTimeout     = 60 * time.Second
+	maxRegistryIndex    = 1 << 20
+	maxPluginDefinition = 1 << 20
+	maxPluginExecutable = 50 << 20
+	registryExecutable  = "run"
+	registryDefinition  = "definition.json"
+)
+
+type registryEntry struct {
+	Name          string `json:"name"`
+	DefinitionURL string `json:"definition_url"`
+	ExecutableURL string `json:"executable_url"`
+	Checksum      string `json:"checksum"`
+}
+
+type registry struct {
+	url        string
+	cacheDir   string
+	httpClient *http.Client
+}
+
+// WithRegistry also loads plugins listed by the registry at registryURL. They
+// are downloaded into cacheDir, which is used as-is when the registry cannot
+// be reached.
+func WithRegistry(registryURL, cacheDir string) Option {
+	return func(m *Manager) {
+		if registryURL == "" {
+			return
+		}
+		m.registry = &registry{
+			url:        strings.TrimRight(registryURL, "/"),
+			cacheDir:   cacheDir,
+			httpClient: &http.Client{Timeout: registryTimeout},
+		}
+	}
+}
+
+// sync fetches the registry index and updates the cache directory, which it
+// returns. Entries that fail are logged and keep their cached copy.
+func (r *registry) sync(debug bool) (string, error) {
+	if err := os.MkdirAll(r.cacheDir, 0755); err != nil {
+		return r.cacheDir, fmt.Errorf("create cache dir: %w", err)
+	}
+
+	base, err := url.Parse(r.url + "/plugins")
+	if err != nil {
+		return r.cacheDir, fmt.Errorf("parse url: %w", err)
+	}
+
+	data, err := r.fetch(base.String(), maxRegistryIndex)
+	if err != nil {
+		return r.cacheDir, fmt.Errorf("fetch index: %w", err)
+	}
+
+	var entries []registryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return r.cacheDir, fmt.Errorf("parse index: %w", err)
+	}
+
+	for _, e := range entries {
+		if err := r.install(base, e); err != nil {
+			log.Printf("[plugin] registry: skip %s: %v", e.Name, err)
+			continue
+		}
+		if debug {
+			fmt.Printf("[plugin] registry: synced %s\n", e.Name)
+		}
+	}
+
+	return r.cacheDir, nil
+}
+
+func (r *registry) install(base *url.URL, e registryEntry) error {
+	if !pluginNamePattern.MatchString(e.Name) {
+		return fmt.Errorf("invalid name %q", e.Name)
+	}
+	want, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(e.Checksum)), "sha256:")
+	if !ok || want == "" {
+		return fmt.Errorf("checksum must be in the form sha256:<hex>")
+	}
+
+	defURL, err := base.Parse(e.DefinitionURL)
+	if err != nil {
+		return fmt.Errorf("definition url: %w", err)
+	}
+	exeURL, err := base.Parse(e.ExecutableURL)
+	if err != nil {
+		return fmt.Errorf("executable url: %w", err)
+	}
+
+	defData, err := r.fetch(defURL.String(), maxPluginDefinition)
+	if err != nil {
+		return fmt.Errorf("fetch definition: %w", err)
+	}
+	var def PluginDefinition
+	if err := json.Unmarshal(defData, &def); err != nil {
+		return fmt.Errorf("parse definition: %w", err)
+	}
+	if def.Name != e.Name {
+		return fmt.Errorf("definition name %q does not match registry name", def.Name)
+	}
+
+	dir := filepath.Join(r.cacheDir, e.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	exePath := filepath.Join(dir, registryExecutable)
+	if !def.Enabled {
+		os.Remove(exePath)
+	} else if err := verifyHash(exePath, e.Checksum); err != nil {
+		exe, err := r.fetch(exeURL.String(), maxPluginExecutable)
+		if err != nil {
+			return fmt.Errorf("fetch executable: %w", err)
+		}
+		sum := sha256.Sum256(exe)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return fmt.Errorf("checksum mismatch (got sha256:%s)", got)
+		}
+		if err := writeFileAtomic(exePath, exe, 0755); err != nil {
+			return fmt.Errorf("write executable: %w", err)
+		}
+	}
+
+	return writeFileAtomic(filepath.Join(dir, registryDefinition), defData, 0644)
+}
+
+func (r *registry) fetch(u string, limit int64) ([]byte, error) {
+	resp, err := r.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: status %d", u, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("GET %s: response larger than %d bytes", u, limit)
+	}
+	return data, nil
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,103 @@
+package plugins
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PluginStats describes a tool and how it has been used since startup.
+type PluginStats struct {
+	Name         string
+	Version      string
+	Author       string
+	Internal     bool
+	Disabled     bool
+	CallCount    int64
+	LastError    string
+	LastCalledAt *time.Time
+}
+
+// DescribedTool is an InternalTool that reports its own version and author.
+// The manager fills in the call statistics.
+type DescribedTool interface {
+	InternalTool
+	Describe() PluginStats
+}
+
+type callStats struct {
+	mu    sync.Mutex
+	calls map[string]*PluginStats
+}
+
+func (s *callStats) record(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.calls == nil {
+		s.calls = make(map[string]*PluginStats)
+	}
+	st, ok := s.calls[name]
+	if !ok {
+		st = &PluginStats{}
+		s.calls[name] = st
+	}
+	now := time.Now()
+	st.CallCount++
+	st.LastCalledAt = &now
+	if err != nil {
+		st.LastError = err.Error()
+	} else {
+		st.LastError = ""
+	}
+}
+
+func (s *callStats) fill(info *PluginStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.calls[info.Name]; ok {
+		info.CallCount = st.CallCount
+		info.LastError = st.LastError
+		info.LastCalledAt = st.LastCalledAt
+	}
+}
+
+// ListPluginInfo returns internal tools and external plugins sorted by name,
+// with their call counts and last error.
+func (m *Manager) ListPluginInfo() []PluginStats {
+	var infos []PluginStats
+	for name, tool := range m.internalTools {
+		info := PluginStats{}
+		if d, ok := tool.(DescribedTool); ok {
+			info = d.Describe()
+		}
+		info.Name = name
+		info.Internal = true
+		infos = append(infos, info)
+	}
+
+	m.mu.RLock()
+	for name, plugin := range m.plugins {
+		infos = append(infos, PluginStats{
+			Name:    name,
+			Version: plugin.Definition.Version,
+			Author:  plugin.Definition.Author,
+		})
+	}
+	for name, plugin := range m.inactivePlugins {
+		infos = append(infos, PluginStats{
+			Name:     name,
+			Version:  plugin.Definition.Version,
+			Author:   plugin.Definition.Author,
+			Disabled: true,
+		})
+	}
+	m.mu.RUnlock()
+
+	for i := range infos {
+		m.stats.fill(&infos[i])
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
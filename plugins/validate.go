@@ -0,0 +1,105 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"tron/util"
+)
+
+var (
+	pluginNamePattern    = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	errInvalidDefinition = errors.New("invalid definition")
+)
+
+func validateDefinition(def PluginDefinition) error {
+	var problems []string
+
+	if def.Name == "" {
+		problems = append(problems, "name is required")
+	} else if !pluginNamePattern.MatchString(def.Name) {
+		problems = append(problems, fmt.Sprintf("name %q must match %s", def.Name, pluginNamePattern))
+	}
+	if strings.TrimSpace(def.Description) == "" {
+		problems = append(problems, "description is required")
+	}
+
+	switch {
+	case def.Parameters == nil:
+		problems = append(problems, "parameters is required")
+	default:
+		if t, _ := def.Parameters["type"].(string); t != "object" {
+			problems = append(problems, `parameters.type must be "object"`)
+		}
+		props, ok := def.Parameters["properties"].(map[string]interface{})
+		if !ok {
+			problems = append(problems, "parameters.properties must be an object")
+		}
+
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := props[name].(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("parameters.properties.%s must be an object", name))
+				continue
+			}
+			if _, ok := prop["type"]; !ok {
+				problems = append(problems, fmt.Sprintf("parameters.properties.%s is missing type", name))
+			}
+		}
+	}
+
+	for key := range def.Env {
+		if key == "" || strings.ContainsAny(key, "= ") {
+			problems = append(problems, fmt.Sprintf("env name %q is not a valid variable name", key))
+		}
+	}
+	for _, name := range def.EnvFiles {
+		if !filepath.IsLocal(name) {
+			problems = append(problems, fmt.Sprintf("env_files entry %q must be a path inside the plugin directory", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errInvalidDefinition, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkOutput validates output against the plugin's output_schema. Output
+// that is not JSON or does not match is replaced by a JSON error, so the LLM
+// is not handed malformed data.
+func checkOutput(plugin *Plugin, output string) string {
+	schema := plugin.Definition.OutputSchema
+	if schema == nil {
+		return output
+	}
+
+	var value interface{}
+	err := json.Unmarshal([]byte(output), &value)
+	if err != nil {
+		err = fmt.Errorf("output is not JSON: %w", err)
+	} else {
+		err = util.ValidateSchema(value, schema)
+	}
+	if err == nil {
+		return output
+	}
+
+	log.Printf("[plugin] WARNING: %s output failed validation: %v", plugin.Definition.Name, err)
+	data, _ := json.Marshal(map[string]string{
+		"error":   "plugin output validation failed",
+		"details": err.Error(),
+	})
+	return string(data)
+}
@@ -0,0 +1,55 @@
+package plugins
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCheckOutput(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"count"},
+		"properties": map[string]interface{}{
+			"count": map[string]interface{}{"type": "integer"},
+		},
+	}
+	tests := []struct {
+		name        string
+		schema      map[string]interface{}
+		output      string
+		wantDetails string
+	}{
+		{"no schema", nil, "plain text", ""},
+		{"valid", schema, `{"count": 3}`, ""},
+		{"not JSON", schema, "count: 3", "output is not JSON"},
+		{"missing field", schema, `{"total": 3}`, `$: missing required field "count"`},
+		{"wrong type", schema, `{"count": "three"}`, "$.count: expected integer, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Plugin{Definition: PluginDefinition{Name: "counter", OutputSchema: tt.schema}}
+			got := checkOutput(plugin, tt.output)
+			if tt.wantDetails == "" {
+				if got != tt.output {
+					t.Fatalf("checkOutput() = %q, want the output unchanged", got)
+				}
+				return
+			}
+
+			var payload map[string]string
+			if err := json.Unmarshal([]byte(got), &payload); err != nil {
+				t.Fatalf("checkOutput() = %q, want a JSON error: %v", got, err)
+			}
+			if payload["error"] != "plugin output validation failed" {
+				t.Errorf("error = %q, want %q", payload["error"], "plugin output validation failed")
+			}
+			if !strings.Contains(payload["details"], tt.wantDetails) {
+				t.Errorf("details = %q, want it to contain %q", payload["details"], tt.wantDetails)
+			}
+			if len(payload) != 2 {
+				t.Errorf("payload = %v, want only error and details", payload)
+			}
+		})
+	}
+}
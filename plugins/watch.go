@@ -0,0 +1,166 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a new plugin directory must stay unchanged
+// before it is loaded, so a plugin still being copied in is not read half
+// written.
+const watchDebounce = 500 * time.Millisecond
+
+// WatchDir loads plugin directories created in the plugin directory and
+// unloads those removed from it, until ctx is done. A new directory that
+// fails to load is retried whenever a file in it changes.
+func (m *Manager) WatchDir(ctx context.Context) error {
+	dir, err := filepath.Abs(m.pluginDir)
+	if err != nil {
+		return fmt.Errorf("abs path: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+
+	var (
+		mu     sync.Mutex
+		timers = make(map[string]*time.Timer)
+	)
+	schedule := func(pluginDir string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if t, ok := timers[pluginDir]; ok {
+			t.Stop()
+		}
+		timers[pluginDir] = time.AfterFunc(watchDebounce, func() {
+			mu.Lock()
+			delete(timers, pluginDir)
+			mu.Unlock()
+			m.autoLoad(pluginDir)
+		})
+	}
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, t := range timers {
+			t.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			path := filepath.Clean(event.Name)
+			pluginDir := path
+			if parent := filepath.Dir(path); parent != dir {
+				// A file inside a plugin directory.
+				if filepath.Dir(parent) != dir {
+					continue
+				}
+				pluginDir = parent
+			}
+
+			switch {
+			case pluginDir == path && event.Has(fsnotify.Create):
+				if info, err := os.Stat(path); err != nil || !info.IsDir() {
+					continue
+				}
+				if err := watcher.Add(path); err != nil {
+					log.Printf("[plugin] watch %s: %v", path, err)
+				}
+				schedule(path)
+			case pluginDir == path && event.Has(fsnotify.Remove|fsnotify.Rename):
+				m.autoUnload(path)
+			case pluginDir != path && !m.loadedFrom(pluginDir):
+				schedule(pluginDir)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("[plugin] watch error: %v", err)
+		}
+	}
+}
+
+// autoLoad loads the plugin in dir if it is complete and enabled.
+func (m *Manager) autoLoad(dir string) {
+	plugin, err := m.loadPlugin(dir)
+	if errors.Is(err, errNotAllowed) {
+		log.Printf("[plugin] SECURITY: refusing to load %s: %v", filepath.Base(dir), err)
+		return
+	}
+	if errors.Is(err, errInvalidDefinition) {
+		log.Printf("[plugin] skip %s: %v", filepath.Base(dir), err)
+		return
+	}
+	if err != nil {
+		if m.debug {
+			fmt.Printf("[plugin] skip %s: %v\n", filepath.Base(dir), err)
+		}
+		return
+	}
+	if !plugin.Definition.Enabled {
+		return
+	}
+
+	name := plugin.Definition.Name
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.disabled[name] {
+		m.inactivePlugins[name] = plugin
+		log.Printf("[plugin] auto-loaded: %s (disabled)", name)
+		return
+	}
+	m.plugins[name] = plugin
+	log.Printf("[plugin] auto-loaded: %s", name)
+}
+
+// autoUnload unloads the plugins that were loaded from dir.
+func (m *Manager) autoUnload(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, plugin := range m.plugins {
+		if plugin.Dir == dir {
+			delete(m.plugins, name)
+			log.Printf("[plugin] auto-unloaded: %s", name)
+		}
+	}
+	for name, plugin := range m.inactivePlugins {
+		if plugin.Dir == dir {
+			delete(m.inactivePlugins, name)
+		}
+	}
+}
+
+func (m *Manager) loadedFrom(dir string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, plugins := range []map[string]*Plugin{m.plugins, m.inactivePlugins} {
+		for _, plugin := range plugins {
+			if plugin.Dir == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
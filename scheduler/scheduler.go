@@ -2,40 +2,88 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
+
+	"tron/config"
 )
 
-type SummaryFunc func() (string, error)
-type SendFunc func(message string) error
+type PromptFunc func(ctx context.Context, chatID, prompt string) (string, error)
+type SendFunc func(chatID, message string) error
+
+type schedule struct {
+	config.SummaryConfig
+	location *time.Location
+	lastSent time.Time
+}
+
+type WeeklyFunc func(ctx context.Context, chatID string) (string, error)
+
+// StateStore persists when each schedule last ran so a restart does not
+// send a summary twice.
+type StateStore interface {
+	LastSent(name string) (time.Time, error)
+	SetLastSent(name string, t time.Time) error
+}
 
 type Scheduler struct {
-	hour        int
-	location    *time.Location
-	summaryFunc SummaryFunc
-	sendFunc    SendFunc
-	lastSent    time.Time
+	schedules  []*schedule
+	promptFunc PromptFunc
+	weeklyFunc WeeklyFunc
+	sendFunc   SendFunc
+	state      StateStore
 }
 
-func NewScheduler(hour int, summaryFunc SummaryFunc, sendFunc SendFunc) (*Scheduler, error) {
-	loc, err := time.LoadLocation("America/Los_Angeles")
-	if err != nil {
-		loc = time.UTC
+type Option func(*Scheduler)
+
+func WithStateStore(state StateStore) Option {
+	return func(s *Scheduler) {
+		s.state = state
+	}
+}
+
+func WithWeeklySummary(fn WeeklyFunc) Option {
+	return func(s *Scheduler) {
+		s.weeklyFunc = fn
+	}
+}
+
+func NewScheduler(summaries []config.SummaryConfig, promptFunc PromptFunc, sendFunc SendFunc, opts ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		promptFunc: promptFunc,
+		sendFunc:   sendFunc,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, sc := range summaries {
+		loc, err := time.LoadLocation(sc.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("summary %s: load timezone %q: %w", sc.Name, sc.Timezone, err)
+		}
+		s.schedules = append(s.schedules, &schedule{
+			SummaryConfig: sc,
+			location:      loc,
+		})
 	}
 
-	return &Scheduler{
-		hour:        hour,
-		location:    loc,
-		summaryFunc: summaryFunc,
-		sendFunc:    sendFunc,
-	}, nil
+	return s, nil
 }
 
 func (s *Scheduler) Start(ctx context.Context) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
-	log.Printf("Scheduler started, will send daily summary at %02d:00 PDT", s.hour)
+	for _, sc := range s.schedules {
+		when := "daily"
+		if sc.Weekday != nil {
+			when = time.Weekday(*sc.Weekday).String()
+		}
+		log.Printf("Scheduler: summary %q %s at %02d:%02d %s", sc.Name, when, sc.Hour, sc.Minute, sc.Timezone)
+	}
+	log.Printf("Scheduler started with %d summaries", len(s.schedules))
 
 	for {
 		select {
@@ -43,44 +91,83 @@ func (s *Scheduler) Start(ctx context.Context) {
 			log.Println("Scheduler stopped")
 			return
 		case <-ticker.C:
-			s.checkAndSend()
+			for _, sc := range s.schedules {
+				s.checkAndSend(ctx, sc)
+			}
 		}
 	}
 }
 
-func (s *Scheduler) checkAndSend() {
-	now := time.Now().In(s.location)
+func (s *Scheduler) checkAndSend(ctx context.Context, sc *schedule) {
+	now := time.Now().In(sc.location)
 
-	if now.Hour() != s.hour {
+	if now.Hour() != sc.Hour || now.Minute() < sc.Minute {
+		return
+	}
+	if sc.Weekday != nil && int(now.Weekday()) != *sc.Weekday {
 		return
 	}
 
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
-	if !s.lastSent.Before(today) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, sc.location)
+	if !s.lastSent(sc).Before(today) {
 		return
 	}
 
-	log.Println("Sending daily summary...")
+	log.Printf("Sending summary %q...", sc.Name)
 
-	summary, err := s.summaryFunc()
-	if err != nil {
-		log.Printf("Error generating summary: %v", err)
+	if err := s.send(ctx, sc); err != nil {
+		log.Printf("Error sending summary %q: %v", sc.Name, err)
 		return
 	}
 
-	if err := s.sendFunc(summary); err != nil {
-		log.Printf("Error sending summary: %v", err)
-		return
+	sc.lastSent = now
+	if s.state != nil {
+		if err := s.state.SetLastSent(sc.Name, now); err != nil {
+			log.Printf("Error saving state of summary %q: %v", sc.Name, err)
+		}
 	}
+	log.Printf("Summary %q sent successfully", sc.Name)
+}
 
-	s.lastSent = now
-	log.Println("Daily summary sent successfully")
+// lastSent prefers the stored time, which survives restarts, and falls back
+// to the in-memory one if the store cannot be read.
+func (s *Scheduler) lastSent(sc *schedule) time.Time {
+	if s.state == nil {
+		return sc.lastSent
+	}
+	t, err := s.state.LastSent(sc.Name)
+	if err != nil {
+		log.Printf("Error reading state of summary %q: %v", sc.Name, err)
+		return sc.lastSent
+	}
+	if t.After(sc.lastSent) {
+		return t
+	}
+	return sc.lastSent
 }
 
-func (s *Scheduler) SendNow() error {
-	summary, err := s.summaryFunc()
+func (s *Scheduler) send(ctx context.Context, sc *schedule) error {
+	var summary string
+	var err error
+	if sc.Kind == config.SummaryKindWeekly {
+		if s.weeklyFunc == nil {
+			return fmt.Errorf("no weekly summary generator configured")
+		}
+		summary, err = s.weeklyFunc(ctx, sc.Recipient)
+	} else {
+		summary, err = s.promptFunc(ctx, sc.Recipient, sc.Prompt)
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("generate summary: %w", err)
+	}
+	return s.sendFunc(sc.Recipient, summary)
+}
+
+func (s *Scheduler) SendNow(ctx context.Context) error {
+	for _, sc := range s.schedules {
+		if err := s.send(ctx, sc); err != nil {
+			return fmt.Errorf("summary %s: %w", sc.Name, err)
+		}
 	}
-	return s.sendFunc(summary)
+	return nil
 }
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tron/config"
+	"tron/memory"
+)
+
+func TestNoResendAfterRestart(t *testing.T) {
+	now := time.Now().UTC()
+	summaries := []config.SummaryConfig{{
+		Name:      "daily",
+		Hour:      now.Hour(),
+		Timezone:  "UTC",
+		Recipient: "dm:+100",
+		Prompt:    "Summarize my day.",
+	}}
+
+	tests := []struct {
+		name      string
+		persist   bool
+		wantSends int
+	}{
+		{"state stored in the database", true, 1},
+		{"state kept in memory only", false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := memory.NewStore(filepath.Join(t.TempDir(), "tron.db"), 10, 60)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			sends := 0
+			prompt := func(ctx context.Context, chatID, prompt string) (string, error) {
+				return "summary", nil
+			}
+			send := func(chatID, message string) error {
+				sends++
+				return nil
+			}
+			start := func() *Scheduler {
+				var opts []Option
+				if tt.persist {
+					opts = append(opts, WithStateStore(store))
+				}
+				s, err := NewScheduler(summaries, prompt, send, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}
+
+			s := start()
+			s.checkAndSend(context.Background(), s.schedules[0])
+			s.checkAndSend(context.Background(), s.schedules[0])
+			if sends != 1 {
+				t.Fatalf("sent %d times before the restart, want 1", sends)
+			}
+
+			restarted := start()
+			restarted.checkAndSend(context.Background(), restarted.schedules[0])
+			if sends != tt.wantSends {
+				t.Errorf("sent %d times after the restart, want %d", sends, tt.wantSends)
+			}
+		})
+	}
+}
+
+func TestSendFailureIsRetried(t *testing.T) {
+	now := time.Now().UTC()
+	store, err := memory.NewStore(filepath.Join(t.TempDir(), "tron.db"), 10, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	fail := true
+	sends := 0
+	s, err := NewScheduler([]config.SummaryConfig{{Name: "daily", Hour: now.Hour(), Timezone: "UTC", Recipient: "dm:+100"}},
+		func(ctx context.Context, chatID, prompt string) (string, error) { return "summary", nil },
+		func(chatID, message string) error {
+			if fail {
+				return context.DeadlineExceeded
+			}
+			sends++
+			return nil
+		},
+		WithStateStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.checkAndSend(context.Background(), s.schedules[0])
+	if last, _ := store.LastSent("daily"); !last.IsZero() {
+		t.Fatalf("failed send was recorded at %v", last)
+	}
+	fail = false
+	s.checkAndSend(context.Background(), s.schedules[0])
+	if sends != 1 {
+		t.Errorf("sent %d times after the failure, want 1", sends)
+	}
+}
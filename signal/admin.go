@@ -0,0 +1,147 @@
+package signal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"tron"
+)
+
+type pendingTrust struct {
+	Recipient    string
+	SafetyNumber string
+	TrustAll     bool
+	// StagedAt is when the operator's message that led to staging was
+	// sent; only a later message can confirm.
+	StagedAt time.Time
+}
+
+// AdminTool administers the bot's Signal account. It only acts for the
+// operator in their direct chat, and a staged trust change is only applied
+// when confirmed in a later message than the one that staged it, so the
+// model cannot stage and confirm in one turn.
+type AdminTool struct {
+	client *Client
+
+	mu      sync.Mutex
+	pending map[string]pendingTrust
+}
+
+type adminArgs struct {
+	Action       string `json:"action"`
+	Recipient    string `json:"recipient"`
+	SafetyNumber string `json:"safety_number"`
+	TrustAll     bool   `json:"trust_all"`
+}
+
+func NewAdminTool(client *Client) *AdminTool {
+	return &AdminTool{
+		client:  client,
+		pending: make(map[string]pendingTrust),
+	}
+}
+
+func (t *AdminTool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name: "signal_admin",
+			Description: "Administer the bot's Signal account. Use 'trust' to stage trusting a contact's new safety number " +
+				"(for example after an untrusted identity error), then 'confirm' once the operator explicitly agrees, or 'cancel'. " +
+				"Only available in the operator's direct chat.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"trust", "confirm", "cancel"},
+						"description": "trust: stage a trust change; confirm: apply the staged change; cancel: discard it",
+					},
+					"recipient": map[string]interface{}{
+						"type":        "string",
+						"description": "Phone number or UUID of the contact (for trust)",
+					},
+					"safety_number": map[string]interface{}{
+						"type":        "string",
+						"description": "Verified safety number to trust (for trust, unless trust_all is set)",
+					},
+					"trust_all": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Trust all known keys of the contact instead of a specific safety number",
+					},
+				},
+				"required": []string{"action"},
+			},
+		},
+	}
+}
+
+// Execute refuses every call: signal_admin needs to know who is asking.
+func (t *AdminTool) Execute(argsJSON string) (string, error) {
+	return t.ExecuteFor(context.Background(), "", argsJSON)
+}
+
+func (t *AdminTool) ExecuteFor(ctx context.Context, chatID, argsJSON string) (string, error) {
+	if tron.RoleFromContext(ctx) != tron.RoleOperator || !strings.HasPrefix(chatID, "dm:") {
+		return "", fmt.Errorf("signal_admin is only available to the operator in their direct chat")
+	}
+
+	var args adminArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("parse args: %w", err)
+	}
+
+	sentAt := tron.SentAtFromContext(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch args.Action {
+	case "trust":
+		if args.Recipient == "" {
+			return "", fmt.Errorf("recipient is required for trust")
+		}
+		if args.SafetyNumber == "" && !args.TrustAll {
+			return "", fmt.Errorf("safety_number or trust_all is required for trust")
+		}
+		t.pending[chatID] = pendingTrust{
+			Recipient:    args.Recipient,
+			SafetyNumber: args.SafetyNumber,
+			TrustAll:     args.TrustAll,
+			StagedAt:     sentAt,
+		}
+		what := "safety number " + args.SafetyNumber
+		if args.TrustAll {
+			what = "all known keys"
+		}
+		return fmt.Sprintf("Staged: trust %s for %s. Ask the operator to confirm; confirm only works after they reply.", what, args.Recipient), nil
+
+	case "confirm":
+		p, ok := t.pending[chatID]
+		if !ok {
+			return "", fmt.Errorf("no pending trust change to confirm")
+		}
+		if sentAt.IsZero() || !sentAt.After(p.StagedAt) {
+			return "", fmt.Errorf("the operator has not replied since the change was staged; ask them to confirm first")
+		}
+		delete(t.pending, chatID)
+		if err := t.client.TrustIdentity(p.Recipient, p.SafetyNumber, p.TrustAll); err != nil {
+			return "", fmt.Errorf("trust identity: %w", err)
+		}
+		return fmt.Sprintf("Trusted identity for %s.", p.Recipient), nil
+
+	case "cancel":
+		if _, ok := t.pending[chatID]; !ok {
+			return "Nothing to cancel.", nil
+		}
+		delete(t.pending, chatID)
+		return "Pending trust change cancelled.", nil
+
+	default:
+		return "", fmt.Errorf("unknown action: %s", args.Action)
+	}
+}
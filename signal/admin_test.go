@@ -0,0 +1,131 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tron"
+)
+
+func operatorTurn(sentAt time.Time) context.Context {
+	ctx := tron.WithRole(context.Background(), tron.RoleOperator)
+	return tron.WithSentAt(ctx, sentAt)
+}
+
+func TestAdminToolRefusesNonOperators(t *testing.T) {
+	f := newFakeSignal(t)
+	tool := NewAdminTool(f.client())
+	args := `{"action":"trust","recipient":"+15550001","trust_all":true}`
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		chatID string
+	}{
+		{"user role", tron.WithRole(context.Background(), "family"), "dm:+15550002"},
+		{"no role", context.Background(), "dm:+15550002"},
+		{"operator in group", tron.WithRole(context.Background(), tron.RoleOperator), "group:abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tool.ExecuteFor(tt.ctx, tt.chatID, args); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	if _, err := tool.Execute(args); err == nil {
+		t.Fatal("Execute without context: expected an error")
+	}
+}
+
+func TestAdminToolConfirmNeedsLaterTurn(t *testing.T) {
+	f := newFakeSignal(t)
+	tool := NewAdminTool(f.client())
+	chatID := "dm:+15550000"
+	staged := time.UnixMilli(1_700_000_000_000)
+
+	if _, err := tool.ExecuteFor(operatorTurn(staged), chatID, `{"action":"trust","recipient":"+15550001","safety_number":"1234"}`); err != nil {
+		t.Fatalf("trust: %v", err)
+	}
+	if _, err := tool.ExecuteFor(operatorTurn(staged), chatID, `{"action":"confirm"}`); err == nil {
+		t.Fatal("confirm in the staging turn: expected an error")
+	}
+	if n := len(f.callsTo("trust")); n != 0 {
+		t.Fatalf("trust RPC called %d times before confirmation", n)
+	}
+
+	out, err := tool.ExecuteFor(operatorTurn(staged.Add(time.Minute)), chatID, `{"action":"confirm"}`)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if !strings.Contains(out, "+15550001") {
+		t.Errorf("confirm output = %q", out)
+	}
+
+	calls := f.callsTo("trust")
+	if len(calls) != 1 {
+		t.Fatalf("trust RPC called %d times, want 1", len(calls))
+	}
+	params := calls[0].Params
+	if params["verifiedSafetyNumber"] != "1234" {
+		t.Errorf("verifiedSafetyNumber = %v", params["verifiedSafetyNumber"])
+	}
+	if r, _ := params["recipient"].([]interface{}); len(r) != 1 || r[0] != "+15550001" {
+		t.Errorf("recipient = %v", params["recipient"])
+	}
+
+	if _, err := tool.ExecuteFor(operatorTurn(staged.Add(2*time.Minute)), chatID, `{"action":"confirm"}`); err == nil {
+		t.Fatal("second confirm: expected an error")
+	}
+}
+
+func TestAdminToolCancel(t *testing.T) {
+	f := newFakeSignal(t)
+	tool := NewAdminTool(f.client())
+	chatID := "dm:+15550000"
+	now := time.Now()
+
+	tool.ExecuteFor(operatorTurn(now), chatID, `{"action":"trust","recipient":"+15550001","trust_all":true}`)
+	if _, err := tool.ExecuteFor(operatorTurn(now.Add(time.Second)), chatID, `{"action":"cancel"}`); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if _, err := tool.ExecuteFor(operatorTurn(now.Add(2*time.Second)), chatID, `{"action":"confirm"}`); err == nil {
+		t.Fatal("confirm after cancel: expected an error")
+	}
+	if n := len(f.callsTo("trust")); n != 0 {
+		t.Fatalf("trust RPC called %d times", n)
+	}
+}
+
+func TestTrustIdentity(t *testing.T) {
+	f := newFakeSignal(t)
+	c := f.client()
+
+	if err := c.TrustIdentity("+15550001", "", false); err == nil {
+		t.Fatal("expected an error without safety number")
+	}
+	if err := c.TrustIdentity("+15550001", "", true); err != nil {
+		t.Fatal(err)
+	}
+	calls := f.callsTo("trust")
+	if len(calls) != 1 || calls[0].Params["trustAllKnownKeys"] != true {
+		t.Fatalf("calls = %+v", calls)
+	}
+}
+
+func TestSendSurfacesIdentityFailure(t *testing.T) {
+	f := newFakeSignal(t)
+	f.result("send", `{"timestamp":1,"results":[{"recipientAddress":{"uuid":"abc","number":"+15550001"},"type":"IDENTITY_FAILURE"}]}`)
+
+	err := f.client().SendMessage("+15550001", "hi")
+	var idErr *IdentityFailureError
+	if !errors.As(err, &idErr) {
+		t.Fatalf("err = %v, want IdentityFailureError", err)
+	}
+	if idErr.Recipient() != "+15550001" {
+		t.Errorf("Recipient() = %q", idErr.Recipient())
+	}
+}
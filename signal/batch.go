@@ -0,0 +1,91 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// SendBatch sends message to every recipient. Phone numbers and UUIDs share
+// a single send call; recipients of the form "group:<id>" are sent one by
+// one. It returns nil if every send succeeded, otherwise one error per
+// recipient, in order, with nil for the ones that succeeded.
+func (c *Client) SendBatch(recipients []string, message string) []error {
+	errs := make([]error, len(recipients))
+
+	var direct []int
+	for i, r := range recipients {
+		if groupID, ok := strings.CutPrefix(r, "group:"); ok {
+			errs[i] = c.SendGroupMessage(groupID, message)
+			continue
+		}
+		direct = append(direct, i)
+	}
+
+	if len(direct) > 0 {
+		params := sendParams{
+			Account: c.botAccount,
+			Message: message,
+		}
+		for _, i := range direct {
+			params.Recipient = append(params.Recipient, recipients[i])
+		}
+		if c.linkPreviews {
+			c.attachPreview(&params)
+		}
+
+		raw, err := c.call("send", params)
+		if err != nil {
+			for _, i := range direct {
+				errs[i] = err
+			}
+		} else {
+			var result sendResult
+			if len(raw) > 0 && json.Unmarshal(raw, &result) == nil {
+				for _, i := range direct {
+					errs[i] = result.recipientError(recipients[i])
+				}
+			}
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
+// recipientError reports the failure signal-cli returned for recipient, if
+// any.
+func (r sendResult) recipientError(recipient string) error {
+	for _, res := range r.Results {
+		addr := res.RecipientAddress
+		if !sameAddress(addr.Number, recipient) && !sameAddress(addr.UUID, recipient) {
+			continue
+		}
+		switch res.Type {
+		case "", "SUCCESS":
+			return nil
+		case "IDENTITY_FAILURE":
+			return &IdentityFailureError{UUID: addr.UUID, Number: addr.Number}
+		default:
+			return fmt.Errorf("send to %s: %s", recipient, strings.ToLower(res.Type))
+		}
+	}
+	return nil
+}
+
+// sameAddress reports whether a and b name the same phone number or UUID,
+// ignoring the "+" and "u:" prefixes and the case of UUIDs.
+func sameAddress(a, b string) bool {
+	a = normalizeAddress(a)
+	return a != "" && a == normalizeAddress(b)
+}
+
+func normalizeAddress(addr string) string {
+	addr = strings.TrimPrefix(addr, "+")
+	addr = strings.TrimPrefix(addr, "u:")
+	return strings.ToLower(addr)
+}
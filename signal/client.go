@@ -4,20 +4,56 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"tron"
 )
 
 type Client struct {
-	baseURL    string
-	botAccount string
-	httpClient *http.Client
-	reqID      atomic.Int64
+	baseURL       string
+	botAccount    string
+	httpClient    *http.Client
+	previewClient *http.Client
+	reqID         atomic.Int64
+	linkPreviews  bool
+	allowSelf     bool
+
+	reconnectDelay atomic.Int64
+	failures       atomic.Int32
+
+	groups    groupCache
+	contacts  contactCache
+	dedupSize int
+	dedup     *dedupWindow
+}
+
+const (
+	minReconnectDelay     = time.Second
+	maxReconnectDelay     = 60 * time.Second
+	reconnectErrorAttempt = 5
+)
+
+type Option func(*Client)
+
+func WithLinkPreviews(enabled bool) Option {
+	return func(c *Client) {
+		c.linkPreviews = enabled
+	}
+}
+
+// WithSelfMessages passes on messages sent from the bot's own account, for
+// operators who use their own number as the bot and write notes to self.
+func WithSelfMessages(allow bool) Option {
+	return func(c *Client) {
+		c.allowSelf = allow
+	}
 }
 
 type jsonRPCRequest struct {
@@ -40,10 +76,86 @@ type jsonRPCError struct {
 }
 
 type sendParams struct {
+	Account            string   `json:"account"`
+	Recipient          []string `json:"recipient,omitempty"`
+	GroupID            string   `json:"groupId,omitempty"`
+	Message            string   `json:"message"`
+	PreviewURL         string   `json:"previewUrl,omitempty"`
+	PreviewTitle       string   `json:"previewTitle,omitempty"`
+	PreviewDescription string   `json:"previewDescription,omitempty"`
+	PreviewImage       string   `json:"previewImage,omitempty"`
+	QuoteTimestamp     int64    `json:"quoteTimestamp,omitempty"`
+	QuoteAuthor        string   `json:"quoteAuthor,omitempty"`
+	QuoteMessage       string   `json:"quoteMessage,omitempty"`
+	MessageTimer       int      `json:"messageTimer,omitempty"`
+}
+
+type reactionParams struct {
+	Account         string   `json:"account"`
+	Recipient       []string `json:"recipient,omitempty"`
+	GroupID         string   `json:"groupId,omitempty"`
+	Emoji           string   `json:"emoji"`
+	TargetAuthor    string   `json:"targetAuthor"`
+	TargetTimestamp int64    `json:"targetTimestamp"`
+	Remove          bool     `json:"remove,omitempty"`
+}
+
+type typingParams struct {
 	Account   string   `json:"account"`
 	Recipient []string `json:"recipient,omitempty"`
 	GroupID   string   `json:"groupId,omitempty"`
-	Message   string   `json:"message"`
+	Stop      bool     `json:"stop,omitempty"`
+}
+
+type updateProfileParams struct {
+	Account string `json:"account"`
+	Name    string `json:"name,omitempty"`
+	About   string `json:"about,omitempty"`
+	Avatar  string `json:"avatar,omitempty"`
+}
+
+type getAttachmentParams struct {
+	Account string `json:"account"`
+	ID      string `json:"id"`
+}
+
+type receiveParams struct {
+	Account string  `json:"account"`
+	Timeout float64 `json:"timeout,omitempty"`
+}
+
+type sendResult struct {
+	Timestamp int64 `json:"timestamp"`
+	Results   []struct {
+		RecipientAddress struct {
+			UUID   string `json:"uuid"`
+			Number string `json:"number"`
+		} `json:"recipientAddress"`
+		Type string `json:"type"`
+	} `json:"results"`
+}
+
+type trustParams struct {
+	Account              string   `json:"account"`
+	Recipient            []string `json:"recipient"`
+	VerifiedSafetyNumber string   `json:"verifiedSafetyNumber,omitempty"`
+	TrustAllKnownKeys    bool     `json:"trustAllKnownKeys,omitempty"`
+}
+
+type IdentityFailureError struct {
+	UUID   string
+	Number string
+}
+
+func (e *IdentityFailureError) Error() string {
+	return fmt.Sprintf("untrusted identity for %s", e.Recipient())
+}
+
+func (e *IdentityFailureError) Recipient() string {
+	if e.Number != "" {
+		return e.Number
+	}
+	return e.UUID
 }
 
 type envelope struct {
@@ -54,10 +166,18 @@ type envelope struct {
 		SourceName   string `json:"sourceName"`
 		Account      string `json:"account"`
 		DataMessage  *struct {
-			Message          string `json:"message"`
-			Timestamp        int64  `json:"timestamp"`
-			ExpiresInSeconds int    `json:"expiresInSeconds"`
-			GroupInfo        *struct {
+			Message          string                `json:"message"`
+			Timestamp        int64                 `json:"timestamp"`
+			ExpiresInSeconds int                   `json:"expiresInSeconds"`
+			Attachments      []tron.AttachmentInfo `json:"attachments"`
+			Quote            *struct {
+				ID           int64  `json:"id"`
+				Author       string `json:"author"`
+				AuthorNumber string `json:"authorNumber"`
+				AuthorUUID   string `json:"authorUuid"`
+				Text         string `json:"text"`
+			} `json:"quote"`
+			GroupInfo *struct {
 				GroupID string `json:"groupId"`
 				Type    string `json:"type"`
 			} `json:"groupInfo"`
@@ -65,86 +185,299 @@ type envelope struct {
 	} `json:"envelope"`
 }
 
-func NewClient(baseURL, botAccount string) *Client {
-	return &Client{
-		baseURL:    strings.TrimSuffix(baseURL, "/"),
-		botAccount: botAccount,
-		httpClient: &http.Client{},
+func WithDedupWindowSize(size int) Option {
+	return func(c *Client) {
+		c.dedupSize = size
 	}
 }
 
+func NewClient(baseURL, botAccount string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		botAccount:    botAccount,
+		httpClient:    &http.Client{},
+		previewClient: newPreviewClient(),
+		dedupSize:     defaultDedupWindowSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.dedup = newDedupWindow(c.dedupSize)
+	return c
+}
+
 func (c *Client) SendMessage(recipient, message string) error {
-	params := sendParams{
+	return c.send(sendParams{
 		Account:   c.botAccount,
 		Recipient: []string{recipient},
 		Message:   message,
-	}
+	})
+}
 
-	req := jsonRPCRequest{
-		JSONRPC: "2.0",
-		Method:  "send",
-		Params:  params,
-		ID:      c.reqID.Add(1),
-	}
+func (c *Client) SendGroupMessage(groupID, message string) error {
+	return c.send(sendParams{
+		Account: c.botAccount,
+		GroupID: groupID,
+		Message: message,
+	})
+}
 
-	body, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
+func (c *Client) SendMessageWithExpiry(recipient, message string, expiresInSeconds int) error {
+	return c.send(sendParams{
+		Account:      c.botAccount,
+		Recipient:    []string{recipient},
+		Message:      message,
+		MessageTimer: expiresInSeconds,
+	})
+}
+
+func (c *Client) SendGroupMessageWithExpiry(groupID, message string, expiresInSeconds int) error {
+	return c.send(sendParams{
+		Account:      c.botAccount,
+		GroupID:      groupID,
+		Message:      message,
+		MessageTimer: expiresInSeconds,
+	})
+}
+
+func (c *Client) SendReply(recipient string, quoteTimestamp int64, quoteAuthor, quoteText, message string) error {
+	return c.send(sendParams{
+		Account:        c.botAccount,
+		Recipient:      []string{recipient},
+		Message:        message,
+		QuoteTimestamp: quoteTimestamp,
+		QuoteAuthor:    quoteAuthor,
+		QuoteMessage:   quoteText,
+	})
+}
+
+func (c *Client) SendGroupReply(groupID string, quoteTimestamp int64, quoteAuthor, quoteText, message string) error {
+	return c.send(sendParams{
+		Account:        c.botAccount,
+		GroupID:        groupID,
+		Message:        message,
+		QuoteTimestamp: quoteTimestamp,
+		QuoteAuthor:    quoteAuthor,
+		QuoteMessage:   quoteText,
+	})
+}
+
+func (c *Client) SendReaction(recipient, targetAuthor string, targetTimestamp int64, emoji string) error {
+	return c.sendReaction(reactionParams{
+		Recipient:       []string{recipient},
+		Emoji:           emoji,
+		TargetAuthor:    targetAuthor,
+		TargetTimestamp: targetTimestamp,
+	})
+}
+
+func (c *Client) SendGroupReaction(groupID, targetAuthor string, targetTimestamp int64, emoji string) error {
+	return c.sendReaction(reactionParams{
+		GroupID:         groupID,
+		Emoji:           emoji,
+		TargetAuthor:    targetAuthor,
+		TargetTimestamp: targetTimestamp,
+	})
+}
+
+func (c *Client) RemoveReaction(recipient, targetAuthor string, targetTimestamp int64, emoji string) error {
+	return c.sendReaction(reactionParams{
+		Recipient:       []string{recipient},
+		Emoji:           emoji,
+		TargetAuthor:    targetAuthor,
+		TargetTimestamp: targetTimestamp,
+		Remove:          true,
+	})
+}
+
+func (c *Client) RemoveGroupReaction(groupID, targetAuthor string, targetTimestamp int64, emoji string) error {
+	return c.sendReaction(reactionParams{
+		GroupID:         groupID,
+		Emoji:           emoji,
+		TargetAuthor:    targetAuthor,
+		TargetTimestamp: targetTimestamp,
+		Remove:          true,
+	})
+}
+
+func (c *Client) SendTyping(recipient string, stop bool) error {
+	_, err := c.call("sendTyping", typingParams{
+		Account:   c.botAccount,
+		Recipient: []string{recipient},
+		Stop:      stop,
+	})
+	return err
+}
+
+func (c *Client) SendGroupTyping(groupID string, stop bool) error {
+	_, err := c.call("sendTyping", typingParams{
+		Account: c.botAccount,
+		GroupID: groupID,
+		Stop:    stop,
+	})
+	return err
+}
+
+func (c *Client) sendReaction(params reactionParams) error {
+	params.Account = c.botAccount
+	_, err := c.call("sendReaction", params)
+	return err
+}
+
+func (c *Client) send(params sendParams) error {
+	if c.linkPreviews {
+		c.attachPreview(&params)
 	}
 
-	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/rpc", "application/json", bytes.NewReader(body))
+	raw, err := c.call("send", params)
 	if err != nil {
-		return fmt.Errorf("send request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	var rpcResp jsonRPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return fmt.Errorf("decode response: %w", err)
+	var result sendResult
+	if len(raw) == 0 || json.Unmarshal(raw, &result) != nil {
+		return nil
 	}
 
-	if rpcResp.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	for _, r := range result.Results {
+		if r.Type == "IDENTITY_FAILURE" {
+			return &IdentityFailureError{
+				UUID:   r.RecipientAddress.UUID,
+				Number: r.RecipientAddress.Number,
+			}
+		}
 	}
 
 	return nil
 }
 
-func (c *Client) SendGroupMessage(groupID, message string) error {
-	params := sendParams{
+func (c *Client) attachPreview(params *sendParams) {
+	u := firstURL(params.Message)
+	if u == "" {
+		return
+	}
+
+	preview, err := c.fetchPreview(u)
+	if err != nil {
+		return
+	}
+
+	params.PreviewURL = preview.URL
+	params.PreviewTitle = preview.Title
+	params.PreviewDescription = preview.Description
+	params.PreviewImage = preview.Image
+}
+
+func (c *Client) TrustIdentity(recipient, safetyNumber string, trustAll bool) error {
+	params := trustParams{
+		Account:   c.botAccount,
+		Recipient: []string{recipient},
+	}
+	if trustAll {
+		params.TrustAllKnownKeys = true
+	} else {
+		if safetyNumber == "" {
+			return fmt.Errorf("safety number is required unless trusting all known keys")
+		}
+		params.VerifiedSafetyNumber = safetyNumber
+	}
+
+	_, err := c.call("trust", params)
+	return err
+}
+
+func (c *Client) UpdateProfile(name, about, avatarPath string) error {
+	_, err := c.call("updateProfile", updateProfileParams{
 		Account: c.botAccount,
-		GroupID: groupID,
-		Message: message,
+		Name:    name,
+		About:   about,
+		Avatar:  avatarPath,
+	})
+	return err
+}
+
+func (c *Client) DownloadAttachment(id string) ([]byte, error) {
+	raw, err := c.call("getAttachment", getAttachmentParams{
+		Account: c.botAccount,
+		ID:      id,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode attachment: %w", err)
 	}
 
+	data, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decode attachment data: %w", err)
+	}
+
+	return data, nil
+}
+
+func (c *Client) call(method string, params interface{}) (json.RawMessage, error) {
 	req := jsonRPCRequest{
 		JSONRPC: "2.0",
-		Method:  "send",
+		Method:  method,
 		Params:  params,
 		ID:      c.reqID.Add(1),
 	}
 
 	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshal request: %w", err)
+		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
 	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/rpc", "application/json", bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("send request: %w", err)
+		return nil, fmt.Errorf("send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var rpcResp jsonRPCResponse
 	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return fmt.Errorf("decode response: %w", err)
+		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
 	if rpcResp.Error != nil {
-		return fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
 	}
 
-	return nil
+	return rpcResp.Result, nil
+}
+
+// ListMessages fetches the messages signal-cli has queued for the bot
+// account but not yet delivered, such as those that arrived while the event
+// stream was down. Messages that would not be passed on to subscribers are
+// left out.
+func (c *Client) ListMessages() ([]tron.IncomingMessage, error) {
+	raw, err := c.call("receive", receiveParams{
+		Account: c.botAccount,
+		Timeout: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var envs []envelope
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &envs); err != nil {
+			return nil, fmt.Errorf("decode received messages: %w", err)
+		}
+	}
+
+	var messages []tron.IncomingMessage
+	for _, env := range envs {
+		if msg, ok := c.incoming(env); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
 }
 
 func (c *Client) SubscribeMessages(ctx context.Context) <-chan tron.IncomingMessage {
@@ -153,6 +486,7 @@ func (c *Client) SubscribeMessages(ctx context.Context) <-chan tron.IncomingMess
 	go func() {
 		defer close(ch)
 
+		reconnect := false
 		for {
 			select {
 			case <-ctx.Done():
@@ -160,12 +494,13 @@ func (c *Client) SubscribeMessages(ctx context.Context) <-chan tron.IncomingMess
 			default:
 			}
 
-			if err := c.streamEvents(ctx, ch); err != nil {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
+			err := c.streamEvents(ctx, ch, reconnect)
+			reconnect = true
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.nextReconnectDelay(err)):
 			}
 		}
 	}()
@@ -173,7 +508,55 @@ func (c *Client) SubscribeMessages(ctx context.Context) <-chan tron.IncomingMess
 	return ch
 }
 
-func (c *Client) streamEvents(ctx context.Context, ch chan<- tron.IncomingMessage) error {
+func (c *Client) nextReconnectDelay(err error) time.Duration {
+	if err == nil {
+		err = fmt.Errorf("event stream closed")
+	}
+
+	delay := time.Duration(c.reconnectDelay.Load())
+	if delay == 0 {
+		delay = minReconnectDelay
+	}
+	c.reconnectDelay.Store(int64(min(delay*2, maxReconnectDelay)))
+
+	failures := c.failures.Add(1)
+	switch {
+	case failures == 1:
+		log.Printf("[signal] event stream disconnected: %v (reconnecting in %s)", err, delay)
+	case failures >= reconnectErrorAttempt:
+		log.Printf("[signal] ERROR: event stream failed %d consecutive times: %v (reconnecting in %s)", failures, err, delay)
+	}
+
+	return delay
+}
+
+func (c *Client) resetBackoff() {
+	c.reconnectDelay.Store(0)
+	c.failures.Store(0)
+}
+
+// catchUp passes on the messages queued while the event stream was down.
+// Messages already passed on are dropped by deliver; timestamps are set by
+// the senders' devices, so they cannot be compared across senders.
+func (c *Client) catchUp(ctx context.Context, ch chan<- tron.IncomingMessage) {
+	messages, err := c.ListMessages()
+	if err != nil {
+		log.Printf("[signal] fetching missed messages: %v", err)
+		return
+	}
+
+	passed := 0
+	for _, msg := range messages {
+		if c.deliver(ctx, ch, msg) {
+			passed++
+		}
+	}
+	if passed > 0 {
+		log.Printf("[signal] passed on %d messages missed while disconnected", passed)
+	}
+}
+
+func (c *Client) streamEvents(ctx context.Context, ch chan<- tron.IncomingMessage, reconnect bool) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/v1/events", nil)
 	if err != nil {
 		return err
@@ -186,6 +569,11 @@ func (c *Client) streamEvents(ctx context.Context, ch chan<- tron.IncomingMessag
 	}
 	defer resp.Body.Close()
 
+	// The stream does not replay what was sent while it was down.
+	if reconnect {
+		c.catchUp(ctx, ch)
+	}
+
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -203,41 +591,83 @@ func (c *Client) streamEvents(ctx context.Context, ch chan<- tron.IncomingMessag
 		if err := json.Unmarshal([]byte(data), &env); err != nil {
 			continue
 		}
+		c.resetBackoff()
 
-		if env.Envelope.DataMessage == nil || env.Envelope.DataMessage.Message == "" {
-			continue
+		if msg, ok := c.incoming(env); ok {
+			if !c.deliver(ctx, ch, msg) && ctx.Err() != nil {
+				return nil
+			}
 		}
+	}
 
-		if c.isSelfMessage(env) {
-			continue
-		}
+	return scanner.Err()
+}
 
-		msg := tron.IncomingMessage{
-			Source:           env.Envelope.Source,
-			SourceUUID:       env.Envelope.SourceUUID,
-			SourceNumber:     env.Envelope.SourceNumber,
-			SourceName:       env.Envelope.SourceName,
-			Message:          env.Envelope.DataMessage.Message,
-			Timestamp:        env.Envelope.DataMessage.Timestamp,
-			ExpiresInSeconds: env.Envelope.DataMessage.ExpiresInSeconds,
-		}
+// deliver passes msg on unless it was already seen or ctx is done, and
+// reports whether it did.
+func (c *Client) deliver(ctx context.Context, ch chan<- tron.IncomingMessage, msg tron.IncomingMessage) bool {
+	source := msg.SourceUUID
+	if source == "" {
+		source = msg.Source
+	}
+	if c.dedup.seen(dedupKey{source: source, timestamp: msg.Timestamp}) {
+		return false
+	}
+
+	select {
+	case ch <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
 
-		if env.Envelope.DataMessage.GroupInfo != nil {
-			msg.GroupID = env.Envelope.DataMessage.GroupInfo.GroupID
-			msg.IsGroup = true
+// incoming converts env to a message, and reports false for envelopes that
+// are not passed on: receipts, empty messages and, unless allowed, the bot's
+// own messages.
+func (c *Client) incoming(env envelope) (tron.IncomingMessage, bool) {
+	if env.Envelope.DataMessage == nil {
+		return tron.IncomingMessage{}, false
+	}
+	if env.Envelope.DataMessage.Message == "" && len(env.Envelope.DataMessage.Attachments) == 0 {
+		return tron.IncomingMessage{}, false
+	}
+	if !c.allowSelf && c.isSelfMessage(env) {
+		return tron.IncomingMessage{}, false
+	}
+
+	msg := tron.IncomingMessage{
+		Source:           env.Envelope.Source,
+		SourceUUID:       env.Envelope.SourceUUID,
+		SourceNumber:     env.Envelope.SourceNumber,
+		SourceName:       env.Envelope.SourceName,
+		Message:          env.Envelope.DataMessage.Message,
+		Timestamp:        env.Envelope.DataMessage.Timestamp,
+		ExpiresInSeconds: env.Envelope.DataMessage.ExpiresInSeconds,
+		Attachments:      env.Envelope.DataMessage.Attachments,
+	}
+
+	if q := env.Envelope.DataMessage.Quote; q != nil {
+		msg.ReplyToTimestamp = q.ID
+		msg.ReplyToAuthor = q.Author
+		if msg.ReplyToAuthor == "" {
+			msg.ReplyToAuthor = q.AuthorNumber
+		}
+		if msg.ReplyToAuthor == "" {
+			msg.ReplyToAuthor = q.AuthorUUID
 		}
+		msg.ReplyToText = q.Text
+	}
 
-		ch <- msg
+	if env.Envelope.DataMessage.GroupInfo != nil {
+		msg.GroupID = env.Envelope.DataMessage.GroupInfo.GroupID
+		msg.IsGroup = true
 	}
 
-	return scanner.Err()
+	return msg, true
 }
 
 func (c *Client) isSelfMessage(env envelope) bool {
-	botAccount := strings.TrimPrefix(c.botAccount, "+")
-	botAccount = strings.TrimPrefix(botAccount, "u:")
-	botAccount = strings.ToLower(botAccount)
-
 	sources := []string{
 		env.Envelope.Source,
 		env.Envelope.SourceUUID,
@@ -245,9 +675,7 @@ func (c *Client) isSelfMessage(env envelope) bool {
 	}
 
 	for _, src := range sources {
-		src = strings.TrimPrefix(src, "+")
-		src = strings.TrimPrefix(src, "u:")
-		if strings.ToLower(src) == botAccount {
+		if sameAddress(src, c.botAccount) {
 			return true
 		}
 	}
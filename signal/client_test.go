@@ -0,0 +1,149 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSignal is a signal-cli JSON-RPC daemon that records the calls it gets
+// and answers each method with a canned result.
+type fakeSignal struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	calls   []rpcCall
+	results map[string]string
+	events  []string
+	// respond, when set, answers every RPC call instead of results.
+	respond func(call rpcCall) string
+}
+
+type rpcCall struct {
+	Method string
+	Params map[string]interface{}
+}
+
+func newFakeSignal(t *testing.T) *fakeSignal {
+	t.Helper()
+	f := &fakeSignal{results: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/rpc", f.serveRPC)
+	mux.HandleFunc("/api/v1/events", f.serveEvents)
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeSignal) client(opts ...Option) *Client {
+	return NewClient(f.srv.URL, "+10000000000", opts...)
+}
+
+// result makes method answer with the raw JSON result.
+func (f *fakeSignal) result(method, result string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[method] = result
+}
+
+// event queues an SSE data line for the next connection to the event stream.
+func (f *fakeSignal) event(data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, data)
+}
+
+// serveEvents writes the queued events and closes the stream.
+func (f *fakeSignal) serveEvents(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	events := f.events
+	f.events = nil
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, data := range events {
+		fmt.Fprintf(w, "event:receive\ndata:%s\n\n", data)
+	}
+}
+
+func (f *fakeSignal) callsTo(method string) []rpcCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []rpcCall
+	for _, c := range f.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (f *fakeSignal) serveRPC(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string                 `json:"method"`
+		Params map[string]interface{} `json:"params"`
+		ID     int64                  `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	call := rpcCall{Method: req.Method, Params: req.Params}
+	f.calls = append(f.calls, call)
+	result, ok := f.results[req.Method]
+	if f.respond != nil {
+		result, ok = f.respond(call), true
+	}
+	f.mu.Unlock()
+	if !ok {
+		result = "{}"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      req.ID,
+		"result":  json.RawMessage(result),
+	})
+}
+
+func TestSelfMessages(t *testing.T) {
+	const uuid = "0d3a5d7e-1b2c-4e5f-8a9b-0c1d2e3f4a5b"
+	tests := []struct {
+		name     string
+		account  string
+		envelope string
+		self     bool
+	}{
+		{"own number", "+10000000000", `{"source":"+10000000000","sourceNumber":"+10000000000"}`, true},
+		{"own number without plus", "+10000000000", `{"source":"10000000000"}`, true},
+		{"own uuid", "u:" + uuid, `{"source":"` + uuid + `","sourceUuid":"` + uuid + `"}`, true},
+		{"own uuid in other case", "u:" + uuid, `{"sourceUuid":"` + strings.ToUpper(uuid) + `"}`, true},
+		{"someone else", "+10000000000", `{"source":"+200","sourceNumber":"+200"}`, false},
+	}
+	for _, tt := range tests {
+		for _, allow := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/allow=%v", tt.name, allow), func(t *testing.T) {
+				f := newFakeSignal(t)
+				c := NewClient(f.srv.URL, tt.account, WithSelfMessages(allow))
+
+				var env envelope
+				data := strings.Replace(tt.envelope, "}", `,"dataMessage":{"message":"note to self","timestamp":1}}`, 1)
+				if err := json.Unmarshal([]byte(`{"envelope":`+data+`}`), &env); err != nil {
+					t.Fatal(err)
+				}
+
+				_, passed := c.incoming(env)
+				if want := !tt.self || allow; passed != want {
+					t.Errorf("passed on = %v, want %v", passed, want)
+				}
+			})
+		}
+	}
+}
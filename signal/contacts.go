@@ -0,0 +1,129 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contactCacheTTL = 10 * time.Minute
+
+// ContactInfo is what the bot's account knows about a Signal user. Name is
+// the name saved in the contact list, ProfileName the one the user set.
+type ContactInfo struct {
+	Name        string
+	ProfileName string
+	Number      string
+	UUID        string
+}
+
+// DisplayName returns the best name for c: the contact name, the profile
+// name, or the number or UUID if neither is set.
+func (c *ContactInfo) DisplayName() string {
+	for _, s := range []string{c.Name, c.ProfileName, c.Number, c.UUID} {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+type contactCache struct {
+	mu       sync.Mutex
+	contacts map[string]cachedContact
+}
+
+type cachedContact struct {
+	info      *ContactInfo
+	fetchedAt time.Time
+}
+
+type listContactsParams struct {
+	Account       string   `json:"account"`
+	Recipient     []string `json:"recipient"`
+	AllRecipients bool     `json:"allRecipients"`
+}
+
+// GetProfile looks up the names signal-cli knows for address, a phone
+// number or UUID (optionally prefixed with "u:"). Results are cached for
+// ten minutes. An address signal-cli has never seen yields a ContactInfo
+// with only the address set.
+func (c *Client) GetProfile(address string) (*ContactInfo, error) {
+	address = strings.TrimPrefix(address, "u:")
+	if address == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+
+	c.contacts.mu.Lock()
+	cached, ok := c.contacts.contacts[address]
+	c.contacts.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < contactCacheTTL {
+		return cached.info, nil
+	}
+
+	raw, err := c.call("listContacts", listContactsParams{
+		Account:       c.botAccount,
+		Recipient:     []string{address},
+		AllRecipients: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		Number     string `json:"number"`
+		UUID       string `json:"uuid"`
+		Name       string `json:"name"`
+		GivenName  string `json:"givenName"`
+		FamilyName string `json:"familyName"`
+		Profile    *struct {
+			GivenName  string `json:"givenName"`
+			FamilyName string `json:"familyName"`
+		} `json:"profile"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode contacts: %w", err)
+	}
+
+	info := &ContactInfo{}
+	if strings.HasPrefix(address, "+") {
+		info.Number = address
+	} else {
+		info.UUID = address
+	}
+	for _, r := range result {
+		if r.Number != address && r.UUID != address {
+			continue
+		}
+		info.Number, info.UUID = r.Number, r.UUID
+		info.Name = r.Name
+		if info.Name == "" {
+			info.Name = strings.TrimSpace(r.GivenName + " " + r.FamilyName)
+		}
+		if r.Profile != nil {
+			info.ProfileName = strings.TrimSpace(r.Profile.GivenName + " " + r.Profile.FamilyName)
+		}
+		break
+	}
+
+	c.contacts.mu.Lock()
+	if c.contacts.contacts == nil {
+		c.contacts.contacts = make(map[string]cachedContact)
+	}
+	c.contacts.contacts[address] = cachedContact{info: info, fetchedAt: time.Now()}
+	c.contacts.mu.Unlock()
+
+	return info, nil
+}
+
+// DisplayName returns the display name of address, or address itself if it
+// cannot be looked up.
+func (c *Client) DisplayName(address string) string {
+	info, err := c.GetProfile(address)
+	if err != nil {
+		return address
+	}
+	return info.DisplayName()
+}
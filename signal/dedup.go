@@ -0,0 +1,51 @@
+package signal
+
+import "sync"
+
+const defaultDedupWindowSize = 100
+
+type dedupKey struct {
+	source    string
+	timestamp int64
+}
+
+type dedupWindow struct {
+	mu      sync.Mutex
+	entries []dedupKey
+	index   map[dedupKey]struct{}
+	next    int
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	if size <= 0 {
+		return nil
+	}
+	return &dedupWindow{
+		entries: make([]dedupKey, 0, size),
+		index:   make(map[dedupKey]struct{}, size),
+	}
+}
+
+func (w *dedupWindow) seen(key dedupKey) bool {
+	if w == nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.index[key]; ok {
+		return true
+	}
+
+	if len(w.entries) < cap(w.entries) {
+		w.entries = append(w.entries, key)
+	} else {
+		delete(w.index, w.entries[w.next])
+		w.entries[w.next] = key
+		w.next = (w.next + 1) % len(w.entries)
+	}
+	w.index[key] = struct{}{}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const groupCacheTTL = 5 * time.Minute
+
+type GroupInfo struct {
+	ID          string
+	Name        string
+	Members     []string
+	Description string
+}
+
+type groupCache struct {
+	mu        sync.Mutex
+	groups    []GroupInfo
+	fetchedAt time.Time
+}
+
+type listGroupsParams struct {
+	Account string `json:"account"`
+}
+
+type groupMember struct {
+	Number string `json:"number"`
+	UUID   string `json:"uuid"`
+}
+
+func (m *groupMember) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		m.Number = s
+		return nil
+	}
+
+	type plain groupMember
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+func (c *Client) ListGroups() ([]GroupInfo, error) {
+	c.groups.mu.Lock()
+	defer c.groups.mu.Unlock()
+
+	if c.groups.groups != nil && time.Since(c.groups.fetchedAt) < groupCacheTTL {
+		return c.groups.groups, nil
+	}
+
+	raw, err := c.call("listGroups", listGroupsParams{Account: c.botAccount})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		ID          string        `json:"id"`
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Members     []groupMember `json:"members"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("decode groups: %w", err)
+	}
+
+	groups := make([]GroupInfo, 0, len(result))
+	for _, g := range result {
+		info := GroupInfo{
+			ID:          g.ID,
+			Name:        g.Name,
+			Description: g.Description,
+		}
+		for _, m := range g.Members {
+			if m.Number != "" {
+				info.Members = append(info.Members, m.Number)
+			} else if m.UUID != "" {
+				info.Members = append(info.Members, m.UUID)
+			}
+		}
+		groups = append(groups, info)
+	}
+
+	c.groups.groups = groups
+	c.groups.fetchedAt = time.Now()
+
+	return groups, nil
+}
+
+func (c *Client) GroupName(groupID string) string {
+	groups, err := c.ListGroups()
+	if err != nil {
+		return ""
+	}
+	for _, g := range groups {
+		if g.ID == groupID {
+			return g.Name
+		}
+	}
+	return ""
+}
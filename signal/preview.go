@@ -0,0 +1,217 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	previewTimeout      = 2 * time.Second
+	previewMaxHTMLBytes = 256 * 1024
+	previewMaxImageSize = 512 * 1024
+	previewMaxRedirects = 3
+)
+
+var errPreviewAddress = errors.New("preview address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range, which like the private
+// ranges is not reachable from the internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+var (
+	urlPattern       = regexp.MustCompile(`https?://[^\s<>"']+`)
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s+[^>]*>`)
+	metaAttrPattern  = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("([^"]*)"|'([^']*)')`)
+	titleTagPattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	trailingURLChars = ".,;:!?)]}"
+)
+
+type linkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	Image       string
+}
+
+func firstURL(text string) string {
+	u := urlPattern.FindString(text)
+	return strings.TrimRight(u, trailingURLChars)
+}
+
+// newPreviewClient returns the client link previews are fetched with. The
+// URLs come from model output that chat members can steer, so it only
+// connects to public addresses, checked on every dial including redirects,
+// and ignores proxy settings, which would hide the real destination.
+func newPreviewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: previewTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !publicAddr(addr) {
+				return fmt.Errorf("%w: %s", errPreviewAddress, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: previewTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= previewMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", previewMaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddr reports whether addr is routable on the internet: not
+// loopback, private, link-local (which includes cloud metadata services),
+// multicast or unspecified.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+func (c *Client) fetchPreview(rawURL string) (*linkPreview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+
+	body, contentType, err := c.fetchLimited(ctx, rawURL, previewMaxHTMLBytes, true)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" {
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+
+	meta := parseMeta(string(body))
+	preview := &linkPreview{
+		URL:         rawURL,
+		Title:       meta["og:title"],
+		Description: meta["og:description"],
+	}
+	if preview.Title == "" {
+		if m := titleTagPattern.FindStringSubmatch(string(body)); m != nil {
+			preview.Title = strings.TrimSpace(html.UnescapeString(m[1]))
+		}
+	}
+	if preview.Title == "" {
+		return nil, fmt.Errorf("no title found")
+	}
+
+	if imageURL := meta["og:image"]; imageURL != "" {
+		if image, err := c.fetchPreviewImage(ctx, rawURL, imageURL); err == nil {
+			preview.Image = image
+		}
+	}
+
+	return preview, nil
+}
+
+func (c *Client) fetchPreviewImage(ctx context.Context, pageURL, imageURL string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	data, contentType, err := c.fetchLimited(ctx, base.ResolveReference(ref).String(), previewMaxImageSize, false)
+	if err != nil {
+		return "", err
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("unsupported image type: %s", contentType)
+	}
+
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (c *Client) fetchLimited(ctx context.Context, rawURL string, maxBytes int64, truncate bool) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.previewClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxBytes {
+		if !truncate {
+			return nil, "", fmt.Errorf("response exceeds %d bytes", maxBytes)
+		}
+		data = data[:maxBytes]
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func parseMeta(page string) map[string]string {
+	meta := make(map[string]string)
+
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := m[3]
+			if value == "" {
+				value = m[4]
+			}
+			attrs[strings.ToLower(m[1])] = html.UnescapeString(value)
+		}
+
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if key == "" || attrs["content"] == "" {
+			continue
+		}
+		if _, exists := meta[key]; !exists {
+			meta[key] = strings.TrimSpace(attrs["content"])
+		}
+	}
+
+	return meta
+}
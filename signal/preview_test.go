@@ -0,0 +1,83 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestPreviewRefusesLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("preview fetched a loopback address")
+	}))
+	defer srv.Close()
+
+	c := NewClient("http://127.0.0.1:1", "+10000000000")
+	if _, err := c.fetchPreview(srv.URL); !errors.Is(err, errPreviewAddress) {
+		t.Fatalf("err = %v, want errPreviewAddress", err)
+	}
+}
+
+func TestPreviewRedirectLimit(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+"/next", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient("http://127.0.0.1:1", "+10000000000")
+	// Let the test server through the address check, keeping the redirect
+	// policy.
+	c.previewClient.Transport = srv.Client().Transport
+	if _, err := c.fetchPreview(srv.URL); err == nil {
+		t.Fatal("expected redirect loop to fail")
+	}
+}
+
+func TestPreviewParsesPage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><meta property="og:title" content="Weather &amp; more"><meta name="og:description" content="Sunny"></head></html>`)
+	}))
+	defer srv.Close()
+
+	c := NewClient("http://127.0.0.1:1", "+10000000000")
+	c.previewClient.Transport = srv.Client().Transport
+	p, err := c.fetchPreview(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Weather & more" || p.Description != "Sunny" {
+		t.Errorf("preview = %+v", p)
+	}
+}
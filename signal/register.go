@@ -0,0 +1,74 @@
+package signal
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type registerParams struct {
+	Account string `json:"account"`
+	Captcha string `json:"captcha,omitempty"`
+	Voice   bool   `json:"voice,omitempty"`
+}
+
+type verifyParams struct {
+	Account          string `json:"account"`
+	VerificationCode string `json:"verificationCode"`
+}
+
+type finishLinkParams struct {
+	DeviceLinkURI string `json:"deviceLinkUri"`
+	DeviceName    string `json:"deviceName"`
+}
+
+func (c *Client) Register(phoneNumber, captchaToken string) error {
+	_, err := c.call("register", registerParams{
+		Account: phoneNumber,
+		Captcha: captchaToken,
+	})
+	return err
+}
+
+func (c *Client) Verify(phoneNumber, code string) error {
+	_, err := c.call("verify", verifyParams{
+		Account:          phoneNumber,
+		VerificationCode: code,
+	})
+	return err
+}
+
+func (c *Client) Link(deviceName string) (string, error) {
+	raw, err := c.call("startLink", struct{}{})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		DeviceLinkURI string `json:"deviceLinkUri"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return "", fmt.Errorf("decode link response: %w", err)
+	}
+	if result.DeviceLinkURI == "" {
+		return "", fmt.Errorf("no device link uri in response")
+	}
+
+	return result.DeviceLinkURI, nil
+}
+
+func (c *Client) FinishLink(linkURI, deviceName string) (string, error) {
+	raw, err := c.call("finishLink", finishLinkParams{
+		DeviceLinkURI: linkURI,
+		DeviceName:    deviceName,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Number string `json:"number"`
+	}
+	json.Unmarshal(raw, &result)
+
+	return result.Number, nil
+}
@@ -0,0 +1,208 @@
+package signal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"tron"
+)
+
+func envelopeJSON(source string, timestamp int64, text string) string {
+	return fmt.Sprintf(`{"envelope":{"source":%q,"sourceNumber":%q,"dataMessage":{"message":%q,"timestamp":%d}}}`,
+		source, source, text, timestamp)
+}
+
+func drain(ch chan tron.IncomingMessage) []string {
+	var got []string
+	for {
+		select {
+		case msg := <-ch:
+			got = append(got, fmt.Sprintf("%s@%d", msg.Source, msg.Timestamp))
+		default:
+			return got
+		}
+	}
+}
+
+func TestStreamEventsCatchUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []string
+		missed []string
+		want   []string
+	}{{
+		name:   "replayed messages are dropped",
+		stream: []string{envelopeJSON("+200", 10, "a"), envelopeJSON("+300", 20, "b")},
+		missed: []string{envelopeJSON("+200", 10, "a"), envelopeJSON("+300", 20, "b")},
+		want:   []string{"+200@10", "+300@20"},
+	}, {
+		// The timestamps come from the sender's clock, so a message from a
+		// sender whose clock is behind must not be taken for a replay.
+		name:   "older timestamp from another sender",
+		stream: []string{envelopeJSON("+200", 100, "a")},
+		missed: []string{envelopeJSON("+300", 50, "b")},
+		want:   []string{"+200@100", "+300@50"},
+	}, {
+		name:   "same timestamp from another sender",
+		stream: []string{envelopeJSON("+200", 100, "a")},
+		missed: []string{envelopeJSON("+300", 100, "b")},
+		want:   []string{"+200@100", "+300@100"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeSignal(t)
+			c := f.client()
+			ch := make(chan tron.IncomingMessage, 10)
+
+			for _, e := range tt.stream {
+				f.event(e)
+			}
+			if err := c.streamEvents(context.Background(), ch, false); err != nil {
+				t.Fatalf("stream: %v", err)
+			}
+
+			missed := "[" + strings.Join(tt.missed, ",") + "]"
+			f.result("receive", missed)
+			if err := c.streamEvents(context.Background(), ch, true); err != nil {
+				t.Fatalf("reconnect: %v", err)
+			}
+
+			got := drain(ch)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupWindowSize(t *testing.T) {
+	f := newFakeSignal(t)
+	c := f.client(WithDedupWindowSize(2))
+	ch := make(chan tron.IncomingMessage, 10)
+
+	for _, ts := range []int64{1, 2, 3} {
+		f.event(envelopeJSON("+200", ts, "x"))
+	}
+	if err := c.streamEvents(context.Background(), ch, false); err != nil {
+		t.Fatal(err)
+	}
+	drain(ch)
+
+	// Timestamp 1 has left the window of two, 3 has not.
+	f.result("receive", "["+envelopeJSON("+200", 1, "x")+","+envelopeJSON("+200", 3, "x")+"]")
+	if err := c.streamEvents(context.Background(), ch, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := drain(ch), []string{"+200@1"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDeliverStopsOnCancel(t *testing.T) {
+	c := NewClient("http://127.0.0.1:0", "+10000000000")
+	ch := make(chan tron.IncomingMessage) // nobody reads
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan bool)
+	go func() {
+		done <- c.deliver(ctx, ch, tron.IncomingMessage{Source: "+200", Timestamp: 1})
+	}()
+	cancel()
+
+	select {
+	case delivered := <-done:
+		if delivered {
+			t.Error("deliver reported a message nobody received")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("deliver blocked after ctx was cancelled")
+	}
+}
+
+func TestSendBatch(t *testing.T) {
+	const failureUUID = "0A1B2C3D-0000-4000-8000-000000000001"
+
+	tests := []struct {
+		name           string
+		recipients     []string
+		results        string
+		wantErrs       []string
+		wantDirect     []string
+		wantGroupCalls int
+	}{{
+		name:       "all delivered",
+		recipients: []string{"+200", "u:abc", "group:g1"},
+		results:    `{"results":[{"recipientAddress":{"number":"+200"},"type":"SUCCESS"},{"recipientAddress":{"uuid":"abc"},"type":"SUCCESS"}]}`,
+		wantDirect: []string{"+200", "u:abc"}, wantGroupCalls: 1,
+	}, {
+		name:       "identity failure by number",
+		recipients: []string{"+200", "+300"},
+		results:    `{"results":[{"recipientAddress":{"number":"+200"},"type":"SUCCESS"},{"recipientAddress":{"number":"+300"},"type":"IDENTITY_FAILURE"}]}`,
+		wantErrs:   []string{"<nil>", "untrusted identity for +300"},
+		wantDirect: []string{"+200", "+300"},
+	}, {
+		name:       "identity failure for a u: recipient",
+		recipients: []string{"+200", "u:" + strings.ToLower(failureUUID)},
+		results:    `{"results":[{"recipientAddress":{"number":"+200"},"type":"SUCCESS"},{"recipientAddress":{"uuid":"` + failureUUID + `"},"type":"IDENTITY_FAILURE"}]}`,
+		wantErrs:   []string{"<nil>", "untrusted identity for " + failureUUID},
+		wantDirect: []string{"+200", "u:" + strings.ToLower(failureUUID)},
+	}, {
+		name:       "number without plus",
+		recipients: []string{"200"},
+		results:    `{"results":[{"recipientAddress":{"number":"+200"},"type":"UNREGISTERED_FAILURE"}]}`,
+		wantErrs:   []string{"send to 200: unregistered_failure"},
+		wantDirect: []string{"200"},
+	}, {
+		name:           "groups only",
+		recipients:     []string{"group:g1", "group:g2"},
+		wantGroupCalls: 2,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeSignal(t)
+			f.respond = func(call rpcCall) string {
+				if _, ok := call.Params["groupId"]; ok || tt.results == "" {
+					return `{"results":[]}`
+				}
+				return tt.results
+			}
+
+			errs := f.client().SendBatch(tt.recipients, "hello")
+
+			var got []string
+			for _, err := range errs {
+				got = append(got, fmt.Sprint(err))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantErrs) {
+				t.Errorf("SendBatch() = %v, want %v", got, tt.wantErrs)
+			}
+
+			var direct []string
+			groupCalls := 0
+			for _, call := range f.callsTo("send") {
+				if _, ok := call.Params["groupId"]; ok {
+					groupCalls++
+					continue
+				}
+				if direct != nil {
+					t.Errorf("more than one send call for direct recipients")
+				}
+				direct = []string{}
+				for _, r := range call.Params["recipient"].([]interface{}) {
+					direct = append(direct, r.(string))
+				}
+			}
+			if fmt.Sprint(direct) != fmt.Sprint(tt.wantDirect) {
+				t.Errorf("direct recipients = %v, want %v", direct, tt.wantDirect)
+			}
+			if groupCalls != tt.wantGroupCalls {
+				t.Errorf("group send calls = %d, want %d", groupCalls, tt.wantGroupCalls)
+			}
+		})
+	}
+}
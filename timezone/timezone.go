@@ -0,0 +1,67 @@
+package timezone
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// zones are IANA names of commonly used zones. Not every zone in the
+// database is listed, but all listed ones load with time.LoadLocation on a
+// system with tzdata.
+var zones = []string{
+	"UTC",
+	"Africa/Abidjan", "Africa/Accra", "Africa/Addis_Ababa", "Africa/Algiers", "Africa/Cairo",
+	"Africa/Casablanca", "Africa/Dar_es_Salaam", "Africa/Johannesburg", "Africa/Kampala",
+	"Africa/Khartoum", "Africa/Kinshasa", "Africa/Lagos", "Africa/Luanda", "Africa/Maputo",
+	"Africa/Nairobi", "Africa/Tripoli", "Africa/Tunis", "Africa/Windhoek",
+	"America/Adak", "America/Anchorage", "America/Argentina/Buenos_Aires", "America/Asuncion",
+	"America/Bogota", "America/Caracas", "America/Chicago", "America/Costa_Rica", "America/Denver",
+	"America/Edmonton", "America/El_Salvador", "America/Guatemala", "America/Guayaquil",
+	"America/Halifax", "America/Havana", "America/Lima", "America/Los_Angeles", "America/Managua",
+	"America/Mexico_City", "America/Montevideo", "America/New_York", "America/Panama",
+	"America/Phoenix", "America/Puerto_Rico", "America/Regina", "America/Santiago",
+	"America/Santo_Domingo", "America/Sao_Paulo", "America/St_Johns", "America/Tegucigalpa",
+	"America/Tijuana", "America/Toronto", "America/Vancouver", "America/Winnipeg",
+	"Antarctica/McMurdo",
+	"Asia/Almaty", "Asia/Amman", "Asia/Baghdad", "Asia/Baku", "Asia/Bangkok", "Asia/Beirut",
+	"Asia/Colombo", "Asia/Damascus", "Asia/Dhaka", "Asia/Dubai", "Asia/Ho_Chi_Minh",
+	"Asia/Hong_Kong", "Asia/Jakarta", "Asia/Jerusalem", "Asia/Kabul", "Asia/Karachi",
+	"Asia/Kathmandu", "Asia/Kolkata", "Asia/Kuala_Lumpur", "Asia/Kuwait", "Asia/Manila",
+	"Asia/Qatar", "Asia/Riyadh", "Asia/Seoul", "Asia/Shanghai", "Asia/Singapore", "Asia/Taipei",
+	"Asia/Tashkent", "Asia/Tbilisi", "Asia/Tehran", "Asia/Tokyo", "Asia/Ulaanbaatar",
+	"Asia/Yangon", "Asia/Yerevan",
+	"Atlantic/Azores", "Atlantic/Canary", "Atlantic/Cape_Verde", "Atlantic/Reykjavik",
+	"Australia/Adelaide", "Australia/Brisbane", "Australia/Darwin", "Australia/Hobart",
+	"Australia/Melbourne", "Australia/Perth", "Australia/Sydney",
+	"Europe/Amsterdam", "Europe/Athens", "Europe/Belgrade", "Europe/Berlin", "Europe/Brussels",
+	"Europe/Bucharest", "Europe/Budapest", "Europe/Copenhagen", "Europe/Dublin",
+	"Europe/Helsinki", "Europe/Istanbul", "Europe/Kyiv", "Europe/Lisbon", "Europe/London",
+	"Europe/Luxembourg", "Europe/Madrid", "Europe/Minsk", "Europe/Moscow", "Europe/Oslo",
+	"Europe/Paris", "Europe/Prague", "Europe/Riga", "Europe/Rome", "Europe/Sofia",
+	"Europe/Stockholm", "Europe/Tallinn", "Europe/Vienna", "Europe/Vilnius", "Europe/Warsaw",
+	"Europe/Zurich",
+	"Indian/Maldives", "Indian/Mauritius",
+	"Pacific/Auckland", "Pacific/Fiji", "Pacific/Guam", "Pacific/Honolulu", "Pacific/Port_Moresby",
+	"Pacific/Tongatapu",
+}
+
+// ListTimezones returns the known zone names containing filter, compared
+// case-insensitively with spaces read as underscores, in alphabetical order.
+// Zones that do not load on this system are left out.
+func ListTimezones(filter string) ([]string, error) {
+	filter = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(filter), " ", "_"))
+
+	var result []string
+	for _, name := range zones {
+		if filter != "" && !strings.Contains(strings.ToLower(name), filter) {
+			continue
+		}
+		if _, err := time.LoadLocation(name); err != nil {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package timezone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"tron"
+)
+
+type Tool struct{}
+
+type toolArgs struct {
+	Filter string `json:"filter"`
+}
+
+func NewTool() *Tool {
+	return &Tool{}
+}
+
+func (t *Tool) Definition() tron.Tool {
+	return tron.Tool{
+		Type: "function",
+		Function: tron.ToolFunction{
+			Name: "timezones",
+			Description: "Search IANA timezone names such as Europe/Berlin, with their current UTC offset. " +
+				"Use to find a valid timezone for a city or region.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"filter": map[string]interface{}{
+						"type":        "string",
+						"description": "Part of the name to look for, e.g. a region (Europe) or city (New York)",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (t *Tool) Commands() []tron.Command {
+	return []tron.Command{{
+		Name:        "/timezone",
+		Description: "Find timezone names (e.g. /timezone Europe)",
+		Run: func(ctx context.Context, chatID, args string) (string, error) {
+			return search(args)
+		},
+	}}
+}
+
+func (t *Tool) Execute(argsJSON string) (string, error) {
+	var args toolArgs
+	if argsJSON != "" {
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("parse args: %w", err)
+		}
+	}
+	return search(args.Filter)
+}
+
+func search(filter string) (string, error) {
+	names, err := ListTimezones(filter)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("No timezones match %q.", filter), nil
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	for _, name := range names {
+		loc, _ := time.LoadLocation(name)
+		fmt.Fprintf(&sb, "%s (UTC%s)\n", name, now.In(loc).Format("-07:00"))
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
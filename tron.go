@@ -1,12 +1,58 @@
 package tron
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
 
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content,omitempty"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// ID is the memory store's ID of a stored message, 0 otherwise.
+	ID         int64         `json:"-"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content,omitempty"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+	Timestamp  time.Time     `json:"-"`
+	// Sender names who wrote a user message in a group chat.
+	Sender string `json:"-"`
+	// Pinned messages are kept in the history regardless of its limits.
+	Pinned bool `json:"-"`
+	// ContentType is the format of Content, one of the ContentType
+	// constants; empty means ContentTypeText.
+	ContentType string `json:"-"`
+}
+
+const (
+	ContentTypeText     = "text"
+	ContentTypeJSON     = "json"
+	ContentTypeMarkdown = "markdown"
+)
+
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	return json.Marshal(struct {
+		plain
+		Content []ContentPart `json:"content"`
+	}{
+		plain:   plain(m),
+		Content: m.Parts,
+	})
 }
 
 type ToolCall struct {
@@ -31,9 +77,77 @@ type ToolFunction struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+type ChatOptions struct {
+	Temperature      *float64        `json:"temperature,omitempty"`
+	MaxTokens        *int            `json:"max_tokens,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	ResponseFormat   *ResponseFormat `json:"response_format,omitempty"`
+	ToolChoice       *ToolChoice     `json:"tool_choice,omitempty"`
+}
+
+func (o ChatOptions) IsZero() bool {
+	return o.Temperature == nil && o.MaxTokens == nil && o.TopP == nil && o.Stop == nil &&
+		o.FrequencyPenalty == nil && o.PresencePenalty == nil && o.ResponseFormat == nil && o.ToolChoice == nil
+}
+
+// ToolChoice is "auto", "none" or "required" in Mode, or a specific tool in
+// Function.
+type ToolChoice struct {
+	Mode     string
+	Function string
+}
+
+var (
+	ToolChoiceAuto     = &ToolChoice{Mode: "auto"}
+	ToolChoiceNone     = &ToolChoice{Mode: "none"}
+	ToolChoiceRequired = &ToolChoice{Mode: "required"}
+)
+
+func ToolChoiceFunction(name string) *ToolChoice {
+	return &ToolChoice{Function: name}
+}
+
+func (t ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Function != "" {
+		return json.Marshal(map[string]interface{}{
+			"type":     "function",
+			"function": map[string]string{"name": t.Function},
+		})
+	}
+	return json.Marshal(t.Mode)
+}
+
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type JSONSchemaFormat struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
 type LLMResponse struct {
 	Content   string
 	ToolCalls []ToolCall
+	Usage     *Usage
+	Reasoning string
+}
+
+type AttachmentInfo struct {
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ID          string `json:"id"`
 }
 
 type IncomingMessage struct {
@@ -46,29 +160,197 @@ type IncomingMessage struct {
 	GroupID          string
 	IsGroup          bool
 	ExpiresInSeconds int
+	Attachments      []AttachmentInfo
+	ReplyToTimestamp int64
+	ReplyToAuthor    string
+	ReplyToText      string
 }
 
 type LLMClient interface {
-	Chat(messages []Message, tools []Tool) (*LLMResponse, error)
+	Chat(ctx context.Context, messages []Message, tools []Tool) (*LLMResponse, error)
+	Models(ctx context.Context) ([]string, error)
+}
+
+type StreamingLLMClient interface {
+	LLMClient
+	ChatStream(ctx context.Context, messages []Message, tools []Tool, onDelta func(string)) (*LLMResponse, error)
+}
+
+type OptionsLLMClient interface {
+	LLMClient
+	ChatWithOptions(ctx context.Context, messages []Message, tools []Tool, opts ChatOptions) (*LLMResponse, error)
+}
+
+type CommandFunc func(ctx context.Context, chatID, args string) (string, error)
+
+// Command is answered directly by the bot, without calling the LLM.
+type Command struct {
+	Name        string
+	Description string
+	Run         CommandFunc
+}
+
+type CommandProvider interface {
+	Commands() []Command
 }
 
 type MemoryStore interface {
 	AddMessage(chatID, role, content string, expiresInSeconds int) error
 	GetHistory(chatID string) ([]Message, error)
+	GetHistoryRange(chatID string, from, to time.Time, limit int) ([]Message, error)
 	ClearHistory(chatID string) error
 	Close() error
 }
 
+// ToolHistoryStore is a MemoryStore that can also keep tool calls, tool
+// results and message senders.
+type ToolHistoryStore interface {
+	MemoryStore
+	AddMessages(chatID string, messages []Message, expiresInSeconds int) error
+}
+
+// SummaryStore is a MemoryStore that keeps a rolling summary of the history
+// that no longer fits the context, covering messages up to throughID.
+type SummaryStore interface {
+	MemoryStore
+	GetSummary(chatID string) (summary string, throughID int64, err error)
+	SetSummary(chatID, summary string, throughID int64) error
+}
+
+// PinStore is a MemoryStore that can pin messages so they stay in the
+// history.
+type PinStore interface {
+	MemoryStore
+	PinLastMessage(chatID string) (Message, bool, error)
+	UnpinMessages(chatID string) (int, error)
+}
+
+// RedactStore is a MemoryStore that can delete particular messages, e.g. a
+// password pasted by mistake.
+type RedactStore interface {
+	MemoryStore
+	DeleteLastUserMessage(chatID string) (Message, bool, error)
+	DeleteByContentMatch(chatID, text string) (int, error)
+}
+
+// ChatConfigStore keeps settings that individual chats override.
+type ChatConfigStore interface {
+	ChatSystemPrompts() (map[string]string, error)
+	SetChatSystemPrompt(chatID, prompt string) error
+}
+
+// Snippet is an archived message found by similarity search.
+type Snippet struct {
+	Role      string
+	Content   string
+	Timestamp time.Time
+	Score     float32
+}
+
+// VectorStore archives messages with their embeddings and finds the ones
+// most similar to a query.
+type VectorStore interface {
+	AddVector(chatID, role, content string, vec []float32) error
+	SearchVectors(chatID string, query []float32, k int, minScore float32) ([]Snippet, error)
+}
+
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type UsageRecorder interface {
+	RecordUsage(chatID string, usage Usage) error
+}
+
 type PluginManager interface {
-	Execute(name, argsJSON string) (string, error)
-	ExecuteWithContext(name, argsJSON, chatID string) (string, error)
+	Execute(ctx context.Context, name, argsJSON string) (string, error)
+	ExecuteWithContext(ctx context.Context, name, argsJSON, chatID string) (string, error)
 	GetTools() []Tool
+	GetToolsForChat(chatID string) []Tool
 	HasPlugin(name string) bool
 	PluginCount() int
+	Enable(name string) error
+	Disable(name string) error
 }
 
 type SignalClient interface {
 	SendMessage(recipient, message string) error
 	SendGroupMessage(groupID, message string) error
+	SendMessageWithExpiry(recipient, message string, expiresInSeconds int) error
+	SendGroupMessageWithExpiry(groupID, message string, expiresInSeconds int) error
+	SendReply(recipient string, quoteTimestamp int64, quoteAuthor, quoteText, message string) error
+	SendGroupReply(groupID string, quoteTimestamp int64, quoteAuthor, quoteText, message string) error
+	SendReaction(recipient, targetAuthor string, targetTimestamp int64, emoji string) error
+	SendGroupReaction(groupID, targetAuthor string, targetTimestamp int64, emoji string) error
+	RemoveReaction(recipient, targetAuthor string, targetTimestamp int64, emoji string) error
+	RemoveGroupReaction(groupID, targetAuthor string, targetTimestamp int64, emoji string) error
+	SendTyping(recipient string, stop bool) error
+	SendGroupTyping(groupID string, stop bool) error
 	SubscribeMessages(ctx context.Context) <-chan IncomingMessage
 }
+
+type chatIDKey struct{}
+
+func WithChatID(ctx context.Context, chatID string) context.Context {
+	return context.WithValue(ctx, chatIDKey{}, chatID)
+}
+
+func ChatIDFromContext(ctx context.Context) string {
+	chatID, _ := ctx.Value(chatIDKey{}).(string)
+	return chatID
+}
+
+type senderKey struct{}
+
+// WithSender records the name of the group member whose message ctx is
+// handling.
+func WithSender(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, senderKey{}, name)
+}
+
+func SenderFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(senderKey{}).(string)
+	return name
+}
+
+type sentAtKey struct{}
+
+// WithSentAt records when the message ctx is handling was sent, by the
+// sender's clock.
+func WithSentAt(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, sentAtKey{}, t)
+}
+
+// SentAtFromContext returns the time set by WithSentAt, or the zero time.
+func SentAtFromContext(ctx context.Context) time.Time {
+	t, _ := ctx.Value(sentAtKey{}).(time.Time)
+	return t
+}
+
+// Sender roles. Other roles are defined in the config and limit the tools a
+// sender can use.
+const (
+	RoleOperator = "operator"
+	RoleIgnored  = "ignored"
+)
+
+type roleKey struct{}
+
+// WithRole records the role of the sender whose message ctx is handling.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the sender's role, or "" when ctx is not handling a
+// message, e.g. for scheduled summaries.
+func RoleFromContext(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+// RolePluginManager is a PluginManager that can limit tools by the sender's
+// role.
+type RolePluginManager interface {
+	PluginManager
+	GetToolsForRole(chatID, role string) []Tool
+}
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"fmt"
+	"math"
+)
+
+// ValidateSchema checks a decoded JSON value against the subset of JSON Schema
+// used by tool definitions: type, properties, required, items and enum.
+func ValidateSchema(value interface{}, schema map[string]interface{}) error {
+	return validateSchema("$", value, schema)
+}
+
+func validateSchema(path string, value interface{}, schema map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+	}
+	if enum, ok := schema["enum"].([]string); ok {
+		s, isString := value.(string)
+		if !isString || !containsString(enum, s) {
+			return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+		}
+	}
+
+	switch t := schema["type"].(type) {
+	case string:
+		if !matchesType(value, t) {
+			return fmt.Errorf("%s: expected %s, got %s", path, t, jsonType(value))
+		}
+	case []interface{}:
+		matched := false
+		for _, name := range t {
+			if s, ok := name.(string); ok && matchesType(value, s) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected one of %v, got %s", path, t, jsonType(value))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range requiredFields(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range props {
+			field, ok := v[name]
+			if !ok {
+				continue
+			}
+			propSchema, _ := prop.(map[string]interface{})
+			if err := validateSchema(path+"."+name, field, propSchema); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range v {
+			if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchesType(value interface{}, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func requiredFields(v interface{}) []string {
+	switch r := v.(type) {
+	case []string:
+		return r
+	case []interface{}:
+		var names []string
+		for _, name := range r {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  map[string]interface{}
+		value   string
+		wantErr string
+	}{
+		{"nil schema", nil, `"anything"`, ""},
+		{"string", map[string]interface{}{"type": "string"}, `"hi"`, ""},
+		{"string mismatch", map[string]interface{}{"type": "string"}, `1`, "$: expected string, got number"},
+		{"integer", map[string]interface{}{"type": "integer"}, `2`, ""},
+		{"integer fraction", map[string]interface{}{"type": "integer"}, `2.5`, "$: expected integer, got number"},
+		{"number", map[string]interface{}{"type": "number"}, `2.5`, ""},
+		{"boolean", map[string]interface{}{"type": "boolean"}, `true`, ""},
+		{"null", map[string]interface{}{"type": "null"}, `null`, ""},
+		{"type list", map[string]interface{}{"type": []interface{}{"string", "null"}}, `null`, ""},
+		{"type list mismatch", map[string]interface{}{"type": []interface{}{"string", "null"}}, `1`, "expected one of [string null], got number"},
+		{"enum", map[string]interface{}{"enum": []interface{}{"a", "b"}}, `"b"`, ""},
+		{"enum mismatch", map[string]interface{}{"enum": []interface{}{"a", "b"}}, `"c"`, "$: value c is not one of [a b]"},
+		{"string enum", map[string]interface{}{"enum": []string{"a", "b"}}, `"a"`, ""},
+		{"string enum mismatch", map[string]interface{}{"enum": []string{"a", "b"}}, `1`, "$: value 1 is not one of [a b]"},
+		{
+			"required",
+			map[string]interface{}{"type": "object", "required": []interface{}{"id"}},
+			`{"id": 1}`,
+			"",
+		},
+		{
+			"required missing",
+			map[string]interface{}{"type": "object", "required": []string{"id"}},
+			`{}`,
+			`$: missing required field "id"`,
+		},
+		{
+			"properties",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+				},
+			},
+			`{"name": 3}`,
+			"$.name: expected string, got number",
+		},
+		{
+			"undeclared property",
+			map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+			},
+			`{"other": 3}`,
+			"",
+		},
+		{
+			"items",
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			`[1, 2, 3]`,
+			"",
+		},
+		{
+			"items mismatch",
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			`[1, "two"]`,
+			"$[1]: expected integer, got string",
+		},
+		{
+			"nested",
+			map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tags": map[string]interface{}{
+						"type":  "array",
+						"items": map[string]interface{}{"enum": []interface{}{"x", "y"}},
+					},
+				},
+			},
+			`{"tags": ["x", "z"]}`,
+			"$.tags[1]: value z is not one of [x y]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateSchema(value, tt.schema)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateSchema() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateSchema() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const codeFence = "```"
+
+func SplitMessage(text string, maxLen int) []string {
+	text = strings.TrimSpace(text)
+	if maxLen <= len(codeFence)*2+2 || len(text) <= maxLen {
+		return []string{text}
+	}
+
+	var chunks []string
+	// fence is the opening line of a code block cut at the end of the
+	// previous chunk, such as "```go", to be repeated at the next one.
+	fence := ""
+
+	for len(text) > 0 {
+		if fence != "" && strings.HasPrefix(text, codeFence) {
+			text = strings.TrimLeft(text[len(codeFence):], " \n")
+			fence = ""
+			continue
+		}
+
+		prefix := ""
+		if fence != "" {
+			prefix = fence + "\n"
+		}
+		limit := maxLen - len(prefix)
+
+		if len(text) <= limit {
+			chunks = append(chunks, prefix+text)
+			break
+		}
+
+		cut := findCut(text, limit)
+		chunk := strings.TrimRight(text[:cut], " \n")
+		text = strings.TrimLeft(text[cut:], " \n")
+
+		fence = openFence(prefix+chunk, maxLen)
+		if fence != "" {
+			if len(prefix)+len(chunk)+len(codeFence)+1 > maxLen {
+				cut = findCut(chunk, limit-len(codeFence)-1)
+				text = strings.TrimLeft(chunk[cut:], " \n") + "\n" + text
+				chunk = strings.TrimRight(chunk[:cut], " \n")
+			}
+			chunk += "\n" + codeFence
+		}
+
+		chunks = append(chunks, prefix+chunk)
+	}
+
+	return chunks
+}
+
+func findCut(text string, limit int) int {
+	window := text[:limit]
+
+	if i := lastOutsideFence(text, window, "\n\n"); i > 0 {
+		return i
+	}
+
+	for _, sep := range []string{".\n", ". ", "!\n", "! ", "?\n", "? ", "\n"} {
+		if i := lastOutsideFence(text, window, sep); i > 0 {
+			return i + len(sep) - 1
+		}
+	}
+
+	if i := strings.LastIndex(window, "\n"); i > 0 {
+		return i
+	}
+	if i := strings.LastIndex(window, " "); i > 0 {
+		return i
+	}
+
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return limit
+}
+
+func lastOutsideFence(text, window, sep string) int {
+	end := len(window)
+	for {
+		i := strings.LastIndex(window[:end], sep)
+		if i <= 0 {
+			return -1
+		}
+		if !insideFence(text[:i]) {
+			return i
+		}
+		end = i
+	}
+}
+
+// openFence returns the opening line of the code block left open at the end
+// of text, or "" if none is. An info string that would leave no room for
+// content in a chunk of maxLen is dropped.
+func openFence(text string, maxLen int) string {
+	if !insideFence(text) {
+		return ""
+	}
+	line := text[strings.LastIndex(text, codeFence):]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimRight(line, " ")
+	if len(line)+len(codeFence)+2 >= maxLen/2 {
+		return codeFence
+	}
+	return line
+}
+
+func insideFence(text string) bool {
+	return strings.Count(text, codeFence)%2 == 1
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		maxLen int
+		want   []string
+	}{
+		{
+			name:   "fits",
+			text:   "short",
+			maxLen: 20,
+			want:   []string{"short"},
+		},
+		{
+			name:   "paragraph",
+			text:   "First paragraph here.\n\nSecond one.",
+			maxLen: 30,
+			want:   []string{"First paragraph here.", "Second one."},
+		},
+		{
+			name:   "sentence",
+			text:   "One sentence here. Another one follows. And a third.",
+			maxLen: 30,
+			want:   []string{"One sentence here.", "Another one follows.", "And a third."},
+		},
+		{
+			name:   "hard cut",
+			text:   strings.Repeat("a", 25),
+			maxLen: 10,
+			want:   []string{"aaaaaaaaaa", "aaaaaaaaaa", "aaaaa"},
+		},
+		{
+			name:   "multi-byte rune at limit",
+			text:   "aaaaaaaaaébbbb",
+			maxLen: 10,
+			want:   []string{"aaaaaaaaa", "ébbbb"},
+		},
+		{
+			name:   "long code block",
+			text:   "Code:\n\n```go\nline one\nline two\nline three\n```\n\nDone.",
+			maxLen: 30,
+			want:   []string{"Code:", "```go\nline one\nline two\n```", "```go\nline three\n```\n\nDone."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.text, tt.maxLen)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SplitMessage() = %q, want %q", got, tt.want)
+			}
+			for _, chunk := range got {
+				if len(chunk) > tt.maxLen {
+					t.Errorf("chunk %q is %d bytes, want at most %d", chunk, len(chunk), tt.maxLen)
+				}
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %q is not valid UTF-8", chunk)
+				}
+			}
+		})
+	}
+}